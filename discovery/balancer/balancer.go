@@ -0,0 +1,141 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package balancer is a gRPC balancer routing over the instances by the metadata they registered
+// with discovery.MetadataRegistrar: smooth weighted round-robin by weight, preferring the instances
+// of the zone of the client, and pinning the calls carrying a version to the instances of that
+// version, for canary releases.
+//
+// Importing the package registers the balancer, which a connection selects with DialOption or
+// ServiceConfig:
+//
+//	conn, err := registry.GetConn(ctx, "user", balancer.DialOption(balancer.Config{Zone: "az1"}))
+//	resp, err := client.GetUser(balancer.WithVersion(ctx, "v2"), req)
+package balancer
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/errs"
+	"google.golang.org/grpc"
+	gbalancer "google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// Name is the name of the balancer in the service config.
+const Name = "smartim_weighted"
+
+// VersionHeader is the outgoing metadata key pinning a call to a version, for the callers which
+// can't use WithVersion.
+const VersionHeader = "x-smartim-version"
+
+// DefaultWeight is the weight of the instances registered without a valid weight.
+const DefaultWeight = 100
+
+// Config is the load balancing config of the balancer.
+type Config struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	// Zone is the zone of the client, the calls go to the instances of this zone while one is ready.
+	Zone string `json:"zone,omitempty"`
+	// StrictVersion fails the calls pinned to a version without a ready instance, rather than
+	// sending them to any instance.
+	StrictVersion bool `json:"strictVersion,omitempty"`
+}
+
+// ServiceConfig returns the service config selecting the balancer with cfg.
+func ServiceConfig(cfg Config) string {
+	data, _ := json.Marshal(map[string]any{
+		"loadBalancingConfig": []map[string]Config{{Name: cfg}},
+	})
+	return string(data)
+}
+
+// DialOption returns the dial option selecting the balancer with cfg.
+func DialOption(cfg Config) grpc.DialOption {
+	return grpc.WithDefaultServiceConfig(ServiceConfig(cfg))
+}
+
+type versionKey struct{}
+
+// WithVersion pins the calls made with ctx to the instances of version.
+func WithVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// versionFromContext returns the version set by WithVersion, or else by VersionHeader.
+func versionFromContext(ctx context.Context) string {
+	if version, _ := ctx.Value(versionKey{}).(string); version != "" {
+		return version
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(VersionHeader); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func init() {
+	gbalancer.Register(builder{})
+}
+
+type builder struct{}
+
+func (builder) Name() string {
+	return Name
+}
+
+func (builder) Build(cc gbalancer.ClientConn, opts gbalancer.BuildOptions) gbalancer.Balancer {
+	b := &weightedBalancer{}
+	b.config.Store(&Config{})
+	b.metadata.Store(map[string]discovery.Metadata{})
+	b.Balancer = base.NewBalancerBuilder(Name, &pickerBuilder{balancer: b}, base.Config{HealthCheck: true}).Build(cc, opts)
+	return b
+}
+
+func (builder) ParseConfig(data json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, errs.WrapMsg(err, "invalid balancer config", "config", string(data))
+	}
+	return cfg, nil
+}
+
+// weightedBalancer is the base balancer, keeping the config and the metadata of the addresses
+// for its picker builder. The base balancer only keeps the first address of each subconn, so the
+// metadata is taken from the latest resolver state instead.
+type weightedBalancer struct {
+	gbalancer.Balancer
+	config   atomic.Pointer[Config]
+	metadata atomic.Value // map[string]discovery.Metadata
+}
+
+func (b *weightedBalancer) UpdateClientConnState(state gbalancer.ClientConnState) error {
+	if cfg, ok := state.BalancerConfig.(*Config); ok {
+		b.config.Store(cfg)
+	}
+	md := make(map[string]discovery.Metadata, len(state.ResolverState.Addresses))
+	for _, addr := range state.ResolverState.Addresses {
+		md[addr.Addr] = discovery.AddressMetadata(addr)
+	}
+	b.metadata.Store(md)
+	// The base balancer builds a new picker from every state, so the changes of metadata apply.
+	return b.Balancer.UpdateClientConnState(state)
+}
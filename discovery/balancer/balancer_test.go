@@ -0,0 +1,118 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/smartim/tools/discovery"
+	gbalancer "google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeSubConn struct {
+	gbalancer.SubConn
+	addr string
+}
+
+func pickN(t *testing.T, p gbalancer.Picker, ctx context.Context, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		res, err := p.Pick(gbalancer.PickInfo{Ctx: ctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[res.SubConn.(*fakeSubConn).addr]++
+	}
+	return counts
+}
+
+func TestPicker(t *testing.T) {
+	mds := map[string]discovery.Metadata{
+		"a:1": {discovery.MetadataZone: "az1", discovery.MetadataWeight: "300"},
+		"b:1": {discovery.MetadataZone: "az1"},
+		"c:1": {discovery.MetadataZone: "az2", discovery.MetadataVersion: "v2", discovery.MetadataWeight: "x"},
+	}
+	instances := func() []instance {
+		var insts []instance
+		for addr, md := range mds {
+			insts = append(insts, newInstance(&fakeSubConn{addr: addr}, addr, md))
+		}
+		return insts
+	}
+	ctx := context.Background()
+
+	// Weighted round-robin: a is picked 3 times for each pick of b and c.
+	counts := pickN(t, newPicker(Config{}, instances()), ctx, 500)
+	if counts["a:1"] != 300 || counts["b:1"] != 100 || counts["c:1"] != 100 {
+		t.Fatalf("weighted picks %v", counts)
+	}
+
+	// Zone-preferred: only az1 while it has an instance, else any.
+	counts = pickN(t, newPicker(Config{Zone: "az1"}, instances()), ctx, 400)
+	if counts["a:1"] != 300 || counts["b:1"] != 100 {
+		t.Fatalf("zone picks %v", counts)
+	}
+	counts = pickN(t, newPicker(Config{Zone: "az3"}, instances()), ctx, 500)
+	if len(counts) != 3 {
+		t.Fatalf("zone fallback picks %v", counts)
+	}
+
+	// Version-pinned: the calls of v2 go to c, even out of the zone, by context or header.
+	p := newPicker(Config{Zone: "az1"}, instances())
+	if counts = pickN(t, p, WithVersion(ctx, "v2"), 10); counts["c:1"] != 10 {
+		t.Fatalf("version picks %v", counts)
+	}
+	if counts = pickN(t, p, metadata.AppendToOutgoingContext(ctx, VersionHeader, "v2"), 10); counts["c:1"] != 10 {
+		t.Fatalf("version header picks %v", counts)
+	}
+	if counts = pickN(t, p, WithVersion(ctx, "v3"), 10); counts["c:1"] != 0 {
+		t.Fatalf("unknown version picks %v", counts)
+	}
+	p = newPicker(Config{StrictVersion: true}, instances())
+	if _, err := p.Pick(gbalancer.PickInfo{Ctx: WithVersion(ctx, "v3")}); err == nil {
+		t.Fatal("strict version picked an instance of another version")
+	}
+}
+
+func TestConfig(t *testing.T) {
+	var sc struct {
+		LoadBalancingConfig []map[string]json.RawMessage `json:"loadBalancingConfig"`
+	}
+	if err := json.Unmarshal([]byte(ServiceConfig(Config{Zone: "az1", StrictVersion: true})), &sc); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := builder{}.ParseConfig(sc.LoadBalancingConfig[0][Name])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cfg.(*Config); c.Zone != "az1" || !c.StrictVersion {
+		t.Fatalf("parsed config %+v", c)
+	}
+
+	// The etcd resolver decodes the endpoint metadata from JSON.
+	addr := resolver.Address{Addr: "a:1", Metadata: map[string]any{discovery.MetadataWeight: "5"}}
+	if md := discovery.AddressMetadata(addr); md[discovery.MetadataWeight] != "5" {
+		t.Fatalf("metadata %v", md)
+	}
+	addr = discovery.SetAddressMetadata(resolver.Address{Addr: "a:1"}, discovery.Metadata{discovery.MetadataZone: "az1"})
+	if md := discovery.AddressMetadata(addr); md[discovery.MetadataZone] != "az1" {
+		t.Fatalf("metadata %v", md)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/smartim/tools/discovery"
+	gbalancer "google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type pickerBuilder struct {
+	balancer *weightedBalancer
+}
+
+func (p *pickerBuilder) Build(info base.PickerBuildInfo) gbalancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(gbalancer.ErrNoSubConnAvailable)
+	}
+	metadata, _ := p.balancer.metadata.Load().(map[string]discovery.Metadata)
+	instances := make([]instance, 0, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		instances = append(instances, newInstance(sc, sci.Address.Addr, metadata[sci.Address.Addr]))
+	}
+	return newPicker(*p.balancer.config.Load(), instances)
+}
+
+// instance is a ready subconn with its metadata.
+type instance struct {
+	sc      gbalancer.SubConn
+	addr    string
+	zone    string
+	version string
+	weight  int
+}
+
+func newInstance(sc gbalancer.SubConn, addr string, md discovery.Metadata) instance {
+	weight, err := strconv.Atoi(md[discovery.MetadataWeight])
+	if err != nil || weight <= 0 {
+		weight = DefaultWeight
+	}
+	return instance{
+		sc:      sc,
+		addr:    addr,
+		zone:    md[discovery.MetadataZone],
+		version: md[discovery.MetadataVersion],
+		weight:  weight,
+	}
+}
+
+// picker picks with a weighted round-robin over the instances of the version of the call, or over
+// all of them, preferring the instances of the zone of the config in each case.
+type picker struct {
+	strictVersion bool
+	all           *group
+	versions      map[string]*group
+}
+
+func newPicker(cfg Config, instances []instance) *picker {
+	// The order of ReadySCs is random, sort for the rounds to be the same on every build.
+	sort.Slice(instances, func(i, j int) bool { return instances[i].addr < instances[j].addr })
+	byVersion := make(map[string][]instance)
+	for _, inst := range instances {
+		if inst.version != "" {
+			byVersion[inst.version] = append(byVersion[inst.version], inst)
+		}
+	}
+	p := &picker{
+		strictVersion: cfg.StrictVersion,
+		all:           newGroup(cfg.Zone, instances),
+		versions:      make(map[string]*group, len(byVersion)),
+	}
+	for version, insts := range byVersion {
+		p.versions[version] = newGroup(cfg.Zone, insts)
+	}
+	return p
+}
+
+func (p *picker) Pick(info gbalancer.PickInfo) (gbalancer.PickResult, error) {
+	g := p.all
+	if version := versionFromContext(info.Ctx); version != "" {
+		if vg, ok := p.versions[version]; ok {
+			g = vg
+		} else if p.strictVersion {
+			return gbalancer.PickResult{}, status.Errorf(codes.Unavailable, "no ready instance of version %q", version)
+		}
+	}
+	return gbalancer.PickResult{SubConn: g.next()}, nil
+}
+
+// group is a smooth weighted round-robin, as in nginx: each instance is picked in proportion to
+// its weight, and the picks of an instance are spread over the round.
+type group struct {
+	lock    sync.Mutex
+	items   []instance
+	current []int
+}
+
+// newGroup returns the group of the instances of zone, or of all the instances if none is in zone.
+func newGroup(zone string, instances []instance) *group {
+	items := instances
+	if zone != "" {
+		var local []instance
+		for _, inst := range instances {
+			if inst.zone == zone {
+				local = append(local, inst)
+			}
+		}
+		if len(local) > 0 {
+			items = local
+		}
+	}
+	return &group{items: items, current: make([]int, len(items))}
+}
+
+func (g *group) next() gbalancer.SubConn {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	best, total := 0, 0
+	for i, inst := range g.items {
+		g.current[i] += inst.weight
+		total += inst.weight
+		if g.current[i] > g.current[best] {
+			best = i
+		}
+	}
+	g.current[best] -= total
+	return g.items[best].sc
+}
@@ -0,0 +1,441 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consul implements discovery.SvcDiscoveryRegistry on Consul. Services are registered
+// on the local agent with a TTL health check and tagged with the root directory, and are
+// resolved from the passing instances through blocking queries.
+package consul
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/utils/datautil"
+	"google.golang.org/grpc"
+)
+
+const (
+	scheme = "consul"
+
+	defaultCheckTTL        = 15 * time.Second
+	defaultDeregisterAfter = time.Minute
+	defaultWaitTime        = 30 * time.Second
+	defaultRegisterTimeout = 5 * time.Second
+	retryDelay             = time.Second
+)
+
+var (
+	_ discovery.SvcDiscoveryRegistry = (*SvcDiscoveryRegistryImpl)(nil)
+	_ discovery.MetadataRegistrar    = (*SvcDiscoveryRegistryImpl)(nil)
+)
+
+var errClosed = errs.New("consul registry closed")
+
+type config struct {
+	api             *api.Config
+	checkTTL        time.Duration
+	deregisterAfter time.Duration
+	waitTime        time.Duration
+}
+
+// CfgOption configures the Consul client and the registry.
+type CfgOption func(*config)
+
+// WithToken sets the ACL token of the requests.
+func WithToken(token string) CfgOption {
+	return func(c *config) {
+		c.api.Token = token
+	}
+}
+
+// WithDatacenter sets the datacenter of the requests, the one of the agent by default.
+func WithDatacenter(dc string) CfgOption {
+	return func(c *config) {
+		c.api.Datacenter = dc
+	}
+}
+
+// WithCheckTTL sets the TTL of the health check of registered services, 15s by default.
+// The check is passed every third of it.
+func WithCheckTTL(ttl time.Duration) CfgOption {
+	return func(c *config) {
+		if ttl > 0 {
+			c.checkTTL = ttl
+		}
+	}
+}
+
+// WithDeregisterCriticalAfter sets how long Consul keeps a service whose check is critical, 1m by default.
+func WithDeregisterCriticalAfter(d time.Duration) CfgOption {
+	return func(c *config) {
+		if d > 0 {
+			c.deregisterAfter = d
+		}
+	}
+}
+
+// WithWaitTime sets the maximum duration of the blocking queries, 30s by default.
+func WithWaitTime(d time.Duration) CfgOption {
+	return func(c *config) {
+		if d > 0 {
+			c.waitTime = d
+		}
+	}
+}
+
+type addrConn struct {
+	conn *grpc.ClientConn
+	addr string
+}
+
+// SvcDiscoveryRegistryImpl is a discovery.SvcDiscoveryRegistry on Consul.
+type SvcDiscoveryRegistryImpl struct {
+	client        *api.Client
+	conf          config
+	rootDirectory string
+	resolver      *resolverBuilder
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu                 sync.RWMutex
+	dialOptions        []grpc.DialOption
+	connMap            map[string][]*addrConn
+	serviceDialOptions map[string][]grpc.DialOption
+
+	watchMu  sync.Mutex
+	watchers map[string]*serviceWatcher
+
+	regMu             sync.Mutex
+	serviceID         string
+	checkID           string
+	registration      *api.AgentServiceRegistration
+	ttlCancel         context.CancelFunc
+	rpcRegisterTarget string
+
+	sessionMu sync.Mutex
+	sessions  map[string]*leaseSession
+
+	gateway atomic.Pointer[hashring.Gateway]
+}
+
+// NewSvcDiscoveryRegistry connects to the Consul agent at address. The services of watchNames
+// are watched right away, the others on their first GetConn or GetConns.
+func NewSvcDiscoveryRegistry(rootDirectory string, address string, watchNames []string, options ...CfgOption) (*SvcDiscoveryRegistryImpl, error) {
+	conf := config{
+		api:             api.DefaultConfig(),
+		checkTTL:        defaultCheckTTL,
+		deregisterAfter: defaultDeregisterAfter,
+		waitTime:        defaultWaitTime,
+	}
+	conf.api.Address = address
+	for _, opt := range options {
+		opt(&conf)
+	}
+	client, err := api.NewClient(conf.api)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "create consul client failed", "address", address)
+	}
+	r := &SvcDiscoveryRegistryImpl{
+		client:             client,
+		conf:               conf,
+		rootDirectory:      rootDirectory,
+		connMap:            make(map[string][]*addrConn),
+		serviceDialOptions: make(map[string][]grpc.DialOption),
+		watchers:           make(map[string]*serviceWatcher),
+		sessions:           make(map[string]*leaseSession),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.resolver = &resolverBuilder{registry: r}
+	for _, name := range watchNames {
+		r.getWatcher(name)
+	}
+	return r, nil
+}
+
+// GetClient returns the Consul client.
+func (r *SvcDiscoveryRegistryImpl) GetClient() *api.Client {
+	return r.client
+}
+
+// GetConn returns a connection balanced over the passing instances of serviceName, which
+// follows them as they come and go.
+func (r *SvcDiscoveryRegistryImpl) GetConn(ctx context.Context, serviceName string, opts ...grpc.DialOption) (grpc.ClientConnInterface, error) {
+	r.mu.RLock()
+	dialOpts := append(append(append([]grpc.DialOption(nil), r.dialOptions...), opts...), grpc.WithResolvers(r.resolver))
+	r.mu.RUnlock()
+	conn, err := grpc.DialContext(ctx, fmt.Sprintf("%s:///%s", scheme, serviceName), dialOpts...)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "dial service failed", "serviceName", serviceName)
+	}
+	return conn, nil
+}
+
+// GetConns returns a connection per passing instance of serviceName.
+func (r *SvcDiscoveryRegistryImpl) GetConns(ctx context.Context, serviceName string, opts ...grpc.DialOption) ([]grpc.ClientConnInterface, error) {
+	if len(opts) > 0 {
+		r.mu.Lock()
+		r.serviceDialOptions[serviceName] = append([]grpc.DialOption(nil), opts...)
+		r.mu.Unlock()
+	}
+	w := r.getWatcher(serviceName)
+	if w == nil {
+		return nil, errClosed.Wrap()
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-w.ready:
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conns, ok := r.connMap[serviceName]
+	if !ok {
+		// The addresses are read under the lock, so that the watcher either updated them
+		// before, or sees the service in connMap and dials its changes itself.
+		r.updateConnsLocked(serviceName, w.addresses())
+		conns = r.connMap[serviceName]
+	}
+	return datautil.Batch(func(c *addrConn) grpc.ClientConnInterface { return c.conn }, conns), nil
+}
+
+// updateConns dials the new addresses of service and closes the connections to the gone ones,
+// if GetConns was called for it.
+func (r *SvcDiscoveryRegistryImpl) updateConns(service string, addrs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.connMap[service]; ok {
+		r.updateConnsLocked(service, addrs)
+	}
+}
+
+func (r *SvcDiscoveryRegistryImpl) updateConnsLocked(service string, addrs []string) {
+	old := make(map[string]*addrConn, len(r.connMap[service]))
+	for _, c := range r.connMap[service] {
+		old[c.addr] = c
+	}
+	dialOpts := append([]grpc.DialOption(nil), r.dialOptions...)
+	dialOpts = append(dialOpts, r.serviceDialOptions[service]...)
+	conns := make([]*addrConn, 0, len(addrs))
+	for _, addr := range addrs {
+		if c, ok := old[addr]; ok {
+			conns = append(conns, c)
+			delete(old, addr)
+			continue
+		}
+		conn, err := grpc.DialContext(context.Background(), addr, dialOpts...)
+		if err != nil {
+			log.ZWarn(context.Background(), "dial service instance failed", err, "service", service, "addr", addr)
+			continue
+		}
+		conns = append(conns, &addrConn{conn: conn, addr: addr})
+	}
+	for _, c := range old {
+		if err := c.conn.Close(); err != nil {
+			log.ZWarn(context.Background(), "close conn err", err, "service", service, "addr", c.addr)
+		}
+	}
+	r.connMap[service] = conns
+}
+
+func (r *SvcDiscoveryRegistryImpl) IsSelfNode(cc grpc.ClientConnInterface) bool {
+	cli, ok := cc.(*grpc.ClientConn)
+	if !ok {
+		return false
+	}
+	return r.GetSelfConnTarget() == cli.Target()
+}
+
+// GetSelfConnTarget returns the address registered by Register.
+func (r *SvcDiscoveryRegistryImpl) GetSelfConnTarget() string {
+	r.regMu.Lock()
+	defer r.regMu.Unlock()
+	return r.rpcRegisterTarget
+}
+
+// AddOption appends gRPC dial options, the connections of GetConns are dialed again.
+func (r *SvcDiscoveryRegistryImpl) AddOption(opts ...grpc.DialOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resetConnMap()
+	r.dialOptions = append(r.dialOptions, opts...)
+}
+
+// Register registers the service on the local agent with a TTL check, which is passed in
+// the background until UnRegister or Close. The service is registered again if the agent
+// lost it.
+func (r *SvcDiscoveryRegistryImpl) Register(ctx context.Context, serviceName, host string, port int, opts ...grpc.DialOption) error {
+	return r.RegisterWithMetadata(ctx, serviceName, host, port, nil, opts...)
+}
+
+// RegisterWithMetadata is Register with md as the service metadata.
+func (r *SvcDiscoveryRegistryImpl) RegisterWithMetadata(ctx context.Context, serviceName, host string, port int, md discovery.Metadata, opts ...grpc.DialOption) error {
+	r.regMu.Lock()
+	defer r.regMu.Unlock()
+	if r.ttlCancel != nil {
+		r.ttlCancel()
+		r.ttlCancel = nil
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	serviceID := fmt.Sprintf("%s-%s-%s", r.rootDirectory, serviceName, addr)
+	checkID := "service:" + serviceID
+	registration := &api.AgentServiceRegistration{
+		ID:      serviceID,
+		Name:    serviceName,
+		Tags:    []string{r.rootDirectory},
+		Address: host,
+		Port:    port,
+		Meta:    md,
+		Check: &api.AgentServiceCheck{
+			CheckID:                        checkID,
+			TTL:                            r.conf.checkTTL.String(),
+			DeregisterCriticalServiceAfter: r.conf.deregisterAfter.String(),
+		},
+	}
+	registerCtx, cancel := withTimeout(ctx, defaultRegisterTimeout)
+	defer cancel()
+	if err := r.register(registerCtx, registration); err != nil {
+		return err
+	}
+	r.serviceID = serviceID
+	r.checkID = checkID
+	r.registration = registration
+	r.rpcRegisterTarget = addr
+
+	ttlCtx, ttlCancel := context.WithCancel(r.ctx)
+	r.ttlCancel = ttlCancel
+	r.wg.Add(1)
+	go r.passCheckLoop(ttlCtx, registration, checkID)
+	return nil
+}
+
+func (r *SvcDiscoveryRegistryImpl) register(ctx context.Context, registration *api.AgentServiceRegistration) error {
+	agent := r.client.Agent()
+	if err := agent.ServiceRegisterOpts(registration, api.ServiceRegisterOpts{}.WithContext(ctx)); err != nil {
+		return errs.WrapMsg(err, "consul register service failed", "serviceID", registration.ID)
+	}
+	checkID := registration.Check.CheckID
+	if err := agent.UpdateTTLOpts(checkID, "", api.HealthPassing, (&api.QueryOptions{}).WithContext(ctx)); err != nil {
+		return errs.WrapMsg(err, "consul pass check failed", "checkID", checkID)
+	}
+	return nil
+}
+
+// passCheckLoop passes the TTL check every third of its TTL, registering the service again
+// when the agent does not know the check anymore, after a restart for instance.
+func (r *SvcDiscoveryRegistryImpl) passCheckLoop(ctx context.Context, registration *api.AgentServiceRegistration, checkID string) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.conf.checkTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := r.client.Agent().UpdateTTLOpts(checkID, "", api.HealthPassing, (&api.QueryOptions{}).WithContext(ctx))
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		log.ZWarn(ctx, "consul pass check failed, registering again", err, "checkID", checkID)
+		registerCtx, cancel := context.WithTimeout(ctx, defaultRegisterTimeout)
+		if err := r.register(registerCtx, registration); err != nil {
+			log.ZWarn(ctx, "consul register service again failed", err, "serviceID", registration.ID)
+		}
+		cancel()
+	}
+}
+
+// UnRegister deregisters the service registered by Register.
+func (r *SvcDiscoveryRegistryImpl) UnRegister() error {
+	r.regMu.Lock()
+	defer r.regMu.Unlock()
+	if r.ttlCancel != nil {
+		r.ttlCancel()
+		r.ttlCancel = nil
+	}
+	if r.serviceID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRegisterTimeout)
+	defer cancel()
+	if err := r.client.Agent().ServiceDeregisterOpts(r.serviceID, (&api.QueryOptions{}).WithContext(ctx)); err != nil {
+		return errs.WrapMsg(err, "consul deregister service failed", "serviceID", r.serviceID)
+	}
+	r.serviceID = ""
+	r.checkID = ""
+	r.registration = nil
+	r.rpcRegisterTarget = ""
+	return nil
+}
+
+// Close deregisters the service, destroys the sessions of SetWithLease, stops the watches
+// and closes the connections of GetConns.
+func (r *SvcDiscoveryRegistryImpl) Close() {
+	if err := r.UnRegister(); err != nil {
+		log.ZWarn(context.Background(), "failed to unregister on close", err)
+	}
+	r.destroySessions()
+	r.cancel()
+	r.wg.Wait()
+
+	r.watchMu.Lock()
+	r.watchers = make(map[string]*serviceWatcher)
+	r.watchMu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resetConnMap()
+	r.serviceDialOptions = make(map[string][]grpc.DialOption)
+}
+
+func (r *SvcDiscoveryRegistryImpl) resetConnMap() {
+	for _, conns := range r.connMap {
+		for _, c := range conns {
+			if err := c.conn.Close(); err != nil {
+				log.ZWarn(context.Background(), "failed to close conn", err)
+			}
+		}
+	}
+	r.connMap = make(map[string][]*addrConn)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,425 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/smartim/tools/discovery"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakeConsul serves the part of the Consul HTTP API used by the registry, with blocking queries
+// answered as soon as the index moves.
+type fakeConsul struct {
+	mu       sync.Mutex
+	index    uint64
+	changed  chan struct{} // closed and replaced on every change
+	blocked  int           // blocking queries waiting
+	services map[string]*api.AgentServiceRegistration
+	checks   map[string]string // check ID to status
+	kv       map[string]*api.KVPair
+	sessions map[string]string // session ID to behavior
+}
+
+func newFakeConsul(t *testing.T) (*fakeConsul, string) {
+	f := &fakeConsul{
+		index:    1,
+		changed:  make(chan struct{}),
+		services: make(map[string]*api.AgentServiceRegistration),
+		checks:   make(map[string]string),
+		kv:       make(map[string]*api.KVPair),
+		sessions: make(map[string]string),
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, strings.TrimPrefix(srv.URL, "http://")
+}
+
+// bump records a change, f.mu held.
+func (f *fakeConsul) bump() {
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// block waits for the index to move past the one of the query, and returns the current index
+// with f.mu held.
+func (f *fakeConsul) block(req *http.Request) uint64 {
+	index, _ := strconv.ParseUint(req.URL.Query().Get("index"), 10, 64)
+	wait, err := time.ParseDuration(req.URL.Query().Get("wait"))
+	if err != nil {
+		wait = time.Second
+	}
+	deadline := time.After(wait)
+	f.mu.Lock()
+	for index > 0 && f.index <= index {
+		changed := f.changed
+		f.blocked++
+		f.mu.Unlock()
+		timedOut := false
+		select {
+		case <-changed:
+		case <-deadline:
+			timedOut = true
+		case <-req.Context().Done():
+			timedOut = true
+		}
+		f.mu.Lock()
+		f.blocked--
+		if timedOut {
+			break
+		}
+	}
+	return f.index
+}
+
+func (f *fakeConsul) blockedQueries() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.blocked
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+	body, _ := io.ReadAll(req.Body)
+	var out any = true
+	switch {
+	case path == "/v1/agent/service/register":
+		var reg api.AgentServiceRegistration
+		if err := json.Unmarshal(body, &reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.services[reg.ID] = &reg
+		f.checks[reg.Check.CheckID] = api.HealthCritical
+		f.bump()
+		f.mu.Unlock()
+	case strings.HasPrefix(path, "/v1/agent/service/deregister/"):
+		id := strings.TrimPrefix(path, "/v1/agent/service/deregister/")
+		f.mu.Lock()
+		if reg, ok := f.services[id]; ok {
+			delete(f.checks, reg.Check.CheckID)
+			delete(f.services, id)
+			f.bump()
+		}
+		f.mu.Unlock()
+	case strings.HasPrefix(path, "/v1/agent/check/update/"):
+		id := strings.TrimPrefix(path, "/v1/agent/check/update/")
+		var update struct{ Status string }
+		_ = json.Unmarshal(body, &update)
+		f.mu.Lock()
+		status, ok := f.checks[id]
+		if ok && status != update.Status {
+			f.checks[id] = update.Status
+			f.bump()
+		}
+		f.mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown check", http.StatusNotFound)
+			return
+		}
+	case strings.HasPrefix(path, "/v1/health/service/"):
+		name := strings.TrimPrefix(path, "/v1/health/service/")
+		index := f.block(req)
+		var entries []*api.ServiceEntry
+		for _, reg := range f.services {
+			if reg.Name != name || !slices.Contains(reg.Tags, req.URL.Query().Get("tag")) {
+				continue
+			}
+			if req.URL.Query().Get("passing") != "" && f.checks[reg.Check.CheckID] != api.HealthPassing {
+				continue
+			}
+			entries = append(entries, &api.ServiceEntry{
+				Node:    &api.Node{Address: "127.0.0.1"},
+				Service: &api.AgentService{ID: reg.ID, Service: reg.Name, Address: reg.Address, Port: reg.Port, Tags: reg.Tags},
+			})
+		}
+		f.mu.Unlock()
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+		out = entries
+	case strings.HasPrefix(path, "/v1/kv/"):
+		key := strings.TrimPrefix(path, "/v1/kv/")
+		switch req.Method {
+		case http.MethodGet:
+			index := f.block(req)
+			var pairs []*api.KVPair
+			for k, pair := range f.kv {
+				if k == key || (req.URL.Query().Has("recurse") && strings.HasPrefix(k, key)) {
+					pairs = append(pairs, pair)
+				}
+			}
+			f.mu.Unlock()
+			w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+			if len(pairs) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			out = pairs
+		case http.MethodPut:
+			session := req.URL.Query().Get("acquire")
+			f.mu.Lock()
+			if old, ok := f.kv[key]; ok && old.Session != "" && old.Session != session {
+				out = false
+			} else if _, ok := f.sessions[session]; session != "" && !ok {
+				out = false
+			} else {
+				f.bump()
+				f.kv[key] = &api.KVPair{Key: key, Value: body, Session: session, ModifyIndex: f.index}
+			}
+			f.mu.Unlock()
+		case http.MethodDelete:
+			f.mu.Lock()
+			if _, ok := f.kv[key]; ok {
+				delete(f.kv, key)
+				f.bump()
+			}
+			f.mu.Unlock()
+		}
+	case path == "/v1/session/create":
+		var entry struct{ Behavior string }
+		_ = json.Unmarshal(body, &entry)
+		f.mu.Lock()
+		id := fmt.Sprintf("session-%d", f.index)
+		f.sessions[id] = entry.Behavior
+		f.bump()
+		f.mu.Unlock()
+		out = map[string]string{"ID": id}
+	case strings.HasPrefix(path, "/v1/session/renew/"):
+		id := strings.TrimPrefix(path, "/v1/session/renew/")
+		f.mu.Lock()
+		_, ok := f.sessions[id]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		out = []*api.SessionEntry{{ID: id, TTL: "10s"}}
+	case strings.HasPrefix(path, "/v1/session/destroy/"):
+		id := strings.TrimPrefix(path, "/v1/session/destroy/")
+		f.mu.Lock()
+		if behavior, ok := f.sessions[id]; ok {
+			delete(f.sessions, id)
+			for key, pair := range f.kv {
+				if pair.Session != id {
+					continue
+				}
+				if behavior == api.SessionBehaviorDelete {
+					delete(f.kv, key)
+				} else {
+					pair.Session = ""
+				}
+			}
+			f.bump()
+		}
+		f.mu.Unlock()
+	default:
+		http.Error(w, "unexpected request "+req.Method+" "+path, http.StatusNotImplemented)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func startHealthServer(t *testing.T) int {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServiceDiscovery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	fake, addr := newFakeConsul(t)
+	opts := []CfgOption{WithCheckTTL(time.Second), WithWaitTime(time.Second)}
+	r1, err := NewSvcDiscoveryRegistry("smartim", addr, []string{"msg"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r1.Close()
+	r1.AddOption(grpc.WithTransportCredentials(insecure.NewCredentials()))
+	r2, err := NewSvcDiscoveryRegistry("smartim", addr, nil, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port1, port2 := startHealthServer(t), startHealthServer(t)
+	if err := r1.Register(ctx, "msg", "127.0.0.1", port1); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := r1.GetConn(ctx, "msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health check through GetConn: %v %v", resp, err)
+	}
+	if !r1.IsSelfNode(mustDial(t, r1.GetSelfConnTarget())) {
+		t.Fatal("IsSelfNode false for the registered address")
+	}
+
+	connCount := func(want int) func() bool {
+		return func() bool {
+			conns, err := r1.GetConns(ctx, "msg")
+			return err == nil && len(conns) == want
+		}
+	}
+	waitFor(t, "one conn", connCount(1))
+	if err := r2.Register(ctx, "msg", "127.0.0.1", port2); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "two conns", connCount(2))
+	r2.Close()
+	waitFor(t, "one conn after close", connCount(1))
+
+	r1.Close()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.services) != 0 || len(fake.checks) != 0 {
+		t.Fatalf("services left after Close: %v", fake.services)
+	}
+}
+
+func mustDial(t *testing.T, target string) grpc.ClientConnInterface {
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestKeyValue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	fake, addr := newFakeConsul(t)
+	r, err := NewSvcDiscoveryRegistry("smartim", addr, nil, WithWaitTime(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	events := make(chan *discovery.WatchKey, 8)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- r.WatchKey(ctx, "conf", func(data *discovery.WatchKey) error {
+			events <- data
+			return nil
+		})
+	}()
+	waitFor(t, "the watch to block", func() bool { return fake.blockedQueries() == 1 })
+
+	if err := r.SetKey(ctx, "smartim/conf/a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetKey(ctx, "smartim/conf/b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.GetKey(ctx, "smartim/conf/a"); err != nil || string(v) != "1" {
+		t.Fatalf("GetKey: %q %v", v, err)
+	}
+	if v, err := r.GetKey(ctx, "smartim/conf/missing"); err != nil || v != nil {
+		t.Fatalf("GetKey missing: %q %v", v, err)
+	}
+	values, err := r.GetKeyWithPrefix(ctx, "smartim/conf/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(values, func(a, b []byte) int { return strings.Compare(string(a), string(b)) })
+	if len(values) != 2 || string(values[0]) != "1" || string(values[1]) != "2" {
+		t.Fatalf("GetKeyWithPrefix: %q", values)
+	}
+	if err := r.DelData(ctx, "smartim/conf/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]discovery.WatchType{"smartim/conf/a": discovery.WatchTypeDelete, "smartim/conf/b": discovery.WatchTypePut}
+	got := make(map[string]discovery.WatchType)
+	for len(got) < len(want) || got["smartim/conf/a"] != discovery.WatchTypeDelete {
+		select {
+		case event := <-events:
+			got[string(event.Key)] = event.Type
+		case <-ctx.Done():
+			t.Fatalf("missing watch events, got %v", got)
+		}
+	}
+	for key, typ := range want {
+		if got[key] != typ {
+			t.Fatalf("watch event of %s is %s, want %s", key, got[key], typ)
+		}
+	}
+
+	if err := r.SetWithLease(ctx, "smartim/lease", []byte("held"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.GetKey(ctx, "smartim/lease"); err != nil || string(v) != "held" {
+		t.Fatalf("GetKey lease: %q %v", v, err)
+	}
+	// Setting the key again replaces the session.
+	if err := r.SetWithLease(ctx, "smartim/lease", []byte("again"), 1); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	sessions := len(fake.sessions)
+	fake.mu.Unlock()
+	if sessions != 1 {
+		t.Fatalf("%d sessions, want 1", sessions)
+	}
+
+	r.Close()
+	if err := <-watchErr; err != nil {
+		t.Fatalf("WatchKey after Close: %v", err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if _, ok := fake.kv["smartim/lease"]; ok || len(fake.sessions) != 0 {
+		t.Fatalf("lease left after Close: %v %v", fake.kv, fake.sessions)
+	}
+}
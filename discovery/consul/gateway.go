@@ -0,0 +1,51 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+)
+
+var _ discovery.GatewayRouter = (*SvcDiscoveryRegistryImpl)(nil)
+
+// SetGatewayRing watches the passing instances of the gateway service and keeps gateway up to date
+// with them. It returns once the first instances are known.
+func (r *SvcDiscoveryRegistryImpl) SetGatewayRing(ctx context.Context, gateway *hashring.Gateway) error {
+	w := r.getWatcher(gateway.Service())
+	if w == nil {
+		return errClosed.Wrap()
+	}
+	r.gateway.Store(gateway)
+	w.setGateway(gateway)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.ready:
+		return nil
+	}
+}
+
+// GetUserIdHashGatewayHost returns the gateway instance of userId on the ring set by SetGatewayRing,
+// or "" without it.
+func (r *SvcDiscoveryRegistryImpl) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
+	gateway := r.gateway.Load()
+	if gateway == nil {
+		return "", nil
+	}
+	return gateway.Host(userId)
+}
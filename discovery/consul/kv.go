@@ -0,0 +1,232 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/utils/datautil"
+)
+
+const (
+	// Consul bounds the TTL of the sessions.
+	minSessionTTL = 10 * time.Second
+	maxSessionTTL = 24 * time.Hour
+)
+
+// leaseSession is the session holding a key of SetWithLease, renewed until destroyed.
+type leaseSession struct {
+	id     string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (r *SvcDiscoveryRegistryImpl) SetKey(ctx context.Context, key string, data []byte) error {
+	if _, err := r.client.KV().Put(&api.KVPair{Key: key, Value: data}, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return errs.WrapMsg(err, "consul put err", "key", key)
+	}
+	return nil
+}
+
+// SetWithLease sets key under a session with a TTL of ttl seconds, between 10s and 24h as Consul
+// requires. The session is renewed until Close, and the key is deleted once it is destroyed or
+// expires. Setting the key again replaces its session.
+func (r *SvcDiscoveryRegistryImpl) SetWithLease(ctx context.Context, key string, val []byte, ttl int64) error {
+	sessionTTL := min(max(time.Duration(ttl)*time.Second, minSessionTTL), maxSessionTTL)
+	r.sessionMu.Lock()
+	defer r.sessionMu.Unlock()
+	if r.ctx.Err() != nil {
+		return errClosed.Wrap()
+	}
+	if old, ok := r.sessions[key]; ok {
+		delete(r.sessions, key)
+		r.destroySession(ctx, old)
+	}
+	id, _, err := r.client.Session().Create(&api.SessionEntry{
+		Name:      fmt.Sprintf("%s-lease-%s", r.rootDirectory, key),
+		TTL:       sessionTTL.String(),
+		Behavior:  api.SessionBehaviorDelete,
+		LockDelay: time.Millisecond,
+	}, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return errs.WrapMsg(err, "consul create session err", "key", key)
+	}
+	session := &leaseSession{id: id, done: make(chan struct{})}
+	acquired, _, err := r.client.KV().Acquire(&api.KVPair{Key: key, Value: val, Session: id}, (&api.WriteOptions{}).WithContext(ctx))
+	if err == nil && !acquired {
+		err = errs.New("key is held by another session", "key", key).Wrap()
+	}
+	if err != nil {
+		r.destroySession(ctx, session)
+		return errs.WrapMsg(err, "consul acquire err", "key", key)
+	}
+	var renewCtx context.Context
+	renewCtx, session.cancel = context.WithCancel(r.ctx)
+	r.sessions[key] = session
+	go r.renewSession(renewCtx, session, key, sessionTTL)
+	return nil
+}
+
+// renewSession renews the session every half of its TTL.
+func (r *SvcDiscoveryRegistryImpl) renewSession(ctx context.Context, session *leaseSession, key string, ttl time.Duration) {
+	defer close(session.done)
+	wait := ttl / 2
+	for sleepWithContext(ctx, wait) {
+		entry, _, err := r.client.Session().Renew(session.id, (&api.WriteOptions{}).WithContext(ctx))
+		if err != nil {
+			if ctx.Err() == nil {
+				log.ZWarn(ctx, "consul renew session err", err, "key", key, "session", session.id)
+			}
+			wait = retryDelay
+			continue
+		}
+		if entry == nil {
+			log.ZWarn(ctx, "consul session expired", nil, "key", key, "session", session.id)
+			return
+		}
+		wait = ttl / 2
+	}
+}
+
+// destroySession stops renewing the session and destroys it, which deletes its key.
+func (r *SvcDiscoveryRegistryImpl) destroySession(ctx context.Context, session *leaseSession) {
+	if session.cancel != nil {
+		session.cancel()
+		<-session.done
+	}
+	ctx, cancel := withTimeout(context.WithoutCancel(ctx), defaultRegisterTimeout)
+	defer cancel()
+	if _, err := r.client.Session().Destroy(session.id, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		log.ZWarn(ctx, "consul destroy session err", err, "session", session.id)
+	}
+}
+
+func (r *SvcDiscoveryRegistryImpl) destroySessions() {
+	r.sessionMu.Lock()
+	defer r.sessionMu.Unlock()
+	for key, session := range r.sessions {
+		r.destroySession(context.Background(), session)
+		delete(r.sessions, key)
+	}
+}
+
+func (r *SvcDiscoveryRegistryImpl) GetKey(ctx context.Context, key string) ([]byte, error) {
+	pair, _, err := r.client.KV().Get(key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, errs.WrapMsg(err, "consul get err", "key", key)
+	}
+	if pair == nil {
+		return nil, nil
+	}
+	return pair.Value, nil
+}
+
+func (r *SvcDiscoveryRegistryImpl) GetKeyWithPrefix(ctx context.Context, key string) ([][]byte, error) {
+	pairs, _, err := r.client.KV().List(key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, errs.WrapMsg(err, "consul list err", "key", key)
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	return datautil.Batch(func(pair *api.KVPair) []byte { return pair.Value }, pairs), nil
+}
+
+func (r *SvcDiscoveryRegistryImpl) DelData(ctx context.Context, key string) error {
+	if _, err := r.client.KV().Delete(key, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return errs.WrapMsg(err, "consul delete err", "key", key)
+	}
+	return nil
+}
+
+// WatchKey calls fn for the keys under rootDirectory/key put or deleted after it started, which it
+// finds by comparing the results of blocking queries. It returns when ctx is done, fn fails or the
+// registry is closed.
+func (r *SvcDiscoveryRegistryImpl) WatchKey(ctx context.Context, key string, fn discovery.WatchKeyHandler) error {
+	if ctx == nil {
+		return fmt.Errorf("context is nil")
+	}
+	if fn == nil {
+		return fmt.Errorf("watch handler is nil")
+	}
+	prefix := fmt.Sprintf("%s/%s", r.rootDirectory, key)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(r.ctx, cancel)
+	defer stop()
+
+	var (
+		index    uint64
+		snapshot map[string]*api.KVPair
+	)
+	for {
+		opts := (&api.QueryOptions{WaitIndex: index, WaitTime: r.conf.waitTime}).WithContext(watchCtx)
+		pairs, meta, err := r.client.KV().List(prefix, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if watchCtx.Err() != nil {
+				return nil
+			}
+			log.ZWarn(ctx, "consul watch key err", err, "key", prefix)
+			sleepWithContext(watchCtx, retryDelay)
+			continue
+		}
+		if meta.LastIndex < index {
+			index = 0
+			continue
+		}
+		if meta.LastIndex == index && snapshot != nil {
+			continue
+		}
+		index = meta.LastIndex
+		current := make(map[string]*api.KVPair, len(pairs))
+		for _, pair := range pairs {
+			current[pair.Key] = pair
+		}
+		if snapshot != nil {
+			for _, event := range diffPairs(snapshot, current) {
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+		}
+		snapshot = current
+	}
+}
+
+// diffPairs returns the events turning the old pairs into the current ones.
+func diffPairs(old, current map[string]*api.KVPair) []*discovery.WatchKey {
+	var events []*discovery.WatchKey
+	for key, pair := range current {
+		if prev, ok := old[key]; ok && prev.ModifyIndex == pair.ModifyIndex {
+			continue
+		}
+		events = append(events, &discovery.WatchKey{Key: []byte(key), Value: pair.Value, Type: discovery.WatchTypePut})
+	}
+	for key := range old {
+		if _, ok := current[key]; !ok {
+			events = append(events, &discovery.WatchKey{Key: []byte(key), Type: discovery.WatchTypeDelete})
+		}
+	}
+	return events
+}
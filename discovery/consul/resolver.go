@@ -0,0 +1,208 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+	"github.com/smartim/tools/log"
+	"google.golang.org/grpc/resolver"
+)
+
+// serviceWatcher follows the passing instances of a service with blocking queries, and pushes
+// them to the resolvers of GetConn, the connections of GetConns and the gateway ring.
+type serviceWatcher struct {
+	service string
+	ready   chan struct{} // closed once the first query answered
+
+	mu        sync.Mutex
+	addrs     []string
+	metadata  map[string]discovery.Metadata // of the addresses registered with metadata
+	resolvers map[*consulResolver]struct{}
+	gateway   *hashring.Gateway
+}
+
+// getWatcher returns the watcher of service, started on first use. It returns nil once closed.
+func (r *SvcDiscoveryRegistryImpl) getWatcher(service string) *serviceWatcher {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	if r.ctx.Err() != nil {
+		return nil
+	}
+	w, ok := r.watchers[service]
+	if !ok {
+		w = &serviceWatcher{
+			service:   service,
+			ready:     make(chan struct{}),
+			resolvers: make(map[*consulResolver]struct{}),
+		}
+		r.watchers[service] = w
+		r.wg.Add(1)
+		go r.watchService(w)
+	}
+	return w
+}
+
+func (r *SvcDiscoveryRegistryImpl) watchService(w *serviceWatcher) {
+	defer r.wg.Done()
+	var index uint64
+	first := true
+	for {
+		opts := (&api.QueryOptions{WaitIndex: index, WaitTime: r.conf.waitTime}).WithContext(r.ctx)
+		entries, meta, err := r.client.Health().Service(w.service, r.rootDirectory, true, opts)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+			log.ZWarn(r.ctx, "consul health query failed", err, "service", w.service)
+			if !sleepWithContext(r.ctx, retryDelay) {
+				return
+			}
+			continue
+		}
+		// The index going backwards means the state was reset, start over.
+		if meta.LastIndex < index {
+			index = 0
+			continue
+		}
+		if meta.LastIndex == index && !first {
+			continue
+		}
+		index = meta.LastIndex
+		addrs := make([]string, 0, len(entries))
+		metadata := make(map[string]discovery.Metadata)
+		for _, entry := range entries {
+			host := entry.Service.Address
+			if host == "" {
+				host = entry.Node.Address
+			}
+			addr := net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))
+			addrs = append(addrs, addr)
+			if len(entry.Service.Meta) > 0 {
+				metadata[addr] = entry.Service.Meta
+			}
+		}
+		slices.Sort(addrs)
+		if w.update(addrs, metadata) {
+			r.updateConns(w.service, addrs)
+		}
+		if first {
+			first = false
+			close(w.ready)
+		}
+	}
+}
+
+// update stores addrs and their metadata and pushes them to the resolvers, it returns whether the
+// addresses changed.
+func (w *serviceWatcher) update(addrs []string, metadata map[string]discovery.Metadata) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := w.addrs == nil || !slices.Equal(w.addrs, addrs)
+	if !changed && maps.EqualFunc(w.metadata, metadata, maps.Equal[discovery.Metadata]) {
+		return false
+	}
+	w.addrs = addrs
+	w.metadata = metadata
+	for res := range w.resolvers {
+		res.push(addrs, metadata)
+	}
+	if changed && w.gateway != nil {
+		w.gateway.Update(addrs)
+	}
+	return changed
+}
+
+// setGateway makes the updates of the addresses update gateway, from the current ones.
+func (w *serviceWatcher) setGateway(gateway *hashring.Gateway) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.gateway = gateway
+	if w.addrs != nil {
+		gateway.Update(w.addrs)
+	}
+}
+
+func (w *serviceWatcher) addresses() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.addrs
+}
+
+func (w *serviceWatcher) addResolver(res *consulResolver) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.resolvers[res] = struct{}{}
+	if w.addrs != nil {
+		res.push(w.addrs, w.metadata)
+	}
+}
+
+func (w *serviceWatcher) removeResolver(res *consulResolver) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.resolvers, res)
+}
+
+// resolverBuilder builds the resolvers of the consul:///serviceName targets of GetConn.
+type resolverBuilder struct {
+	registry *SvcDiscoveryRegistryImpl
+}
+
+func (b *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	service := strings.TrimLeft(target.URL.Path, "/")
+	w := b.registry.getWatcher(service)
+	if w == nil {
+		return nil, errClosed.Wrap()
+	}
+	res := &consulResolver{watcher: w, cc: cc}
+	w.addResolver(res)
+	return res, nil
+}
+
+func (b *resolverBuilder) Scheme() string {
+	return scheme
+}
+
+type consulResolver struct {
+	watcher *serviceWatcher
+	cc      resolver.ClientConn
+}
+
+func (r *consulResolver) push(addrs []string, metadata map[string]discovery.Metadata) {
+	state := resolver.State{Addresses: make([]resolver.Address, 0, len(addrs))}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, discovery.SetAddressMetadata(resolver.Address{Addr: addr}, metadata[addr]))
+	}
+	if err := r.cc.UpdateState(state); err != nil {
+		log.ZDebug(context.Background(), "consul resolver update state", "err", err, "service", r.watcher.service, "addrs", addrs)
+	}
+}
+
+// ResolveNow does nothing, the addresses are pushed as soon as Consul reports a change.
+func (r *consulResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *consulResolver) Close() {
+	r.watcher.removeResolver(r)
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"strconv"
 
+	"github.com/smartim/tools/discovery/hashring"
 	"google.golang.org/grpc"
 )
 
@@ -72,3 +73,11 @@ type SvcDiscoveryRegistry interface {
 	Close()
 	GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error)
 }
+
+// GatewayRouter is implemented by the registries whose GetUserIdHashGatewayHost routes the users
+// over a consistent-hash ring of the instances of a gateway service.
+type GatewayRouter interface {
+	// SetGatewayRing makes the registry keep gateway up to date with the instances of
+	// gateway.Service(), and answer GetUserIdHashGatewayHost from it.
+	SetGatewayRing(ctx context.Context, gateway *hashring.Gateway) error
+}
@@ -22,9 +22,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
 	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/log"
 	"github.com/smartim/tools/utils/datautil"
@@ -79,6 +81,10 @@ type SvcDiscoveryRegistryImpl struct {
 	registeredService string
 	registeredHost    string
 	registeredPort    int
+	registeredMeta    discovery.Metadata
+
+	gatewayMu sync.Mutex
+	gateway   atomic.Pointer[hashring.Gateway]
 }
 
 type watchKeyEntry struct {
@@ -354,11 +360,6 @@ func WithUsernameAndPassword(username, password string) CfgOption {
 	}
 }
 
-// GetUserIdHashGatewayHost returns the gateway host for a given user ID hash
-func (r *SvcDiscoveryRegistryImpl) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
-	return "", nil
-}
-
 // GetConns returns gRPC client connections for a given service name
 func (r *SvcDiscoveryRegistryImpl) GetConns(ctx context.Context, serviceName string, opts ...grpc.DialOption) ([]grpc.ClientConnInterface, error) {
 	if err := r.ensureServiceWatch(serviceName); err != nil {
@@ -423,8 +424,15 @@ func (r *SvcDiscoveryRegistryImpl) AddOption(opts ...grpc.DialOption) {
 	r.dialOptions = append(r.dialOptions, opts...)
 }
 
+var _ discovery.MetadataRegistrar = (*SvcDiscoveryRegistryImpl)(nil)
+
 // Register registers a new service endpoint with etcd
 func (r *SvcDiscoveryRegistryImpl) Register(ctx context.Context, serviceName, host string, port int, opts ...grpc.DialOption) error {
+	return r.RegisterWithMetadata(ctx, serviceName, host, port, nil, opts...)
+}
+
+// RegisterWithMetadata registers a new service endpoint with etcd, storing md as the metadata of the endpoint.
+func (r *SvcDiscoveryRegistryImpl) RegisterWithMetadata(ctx context.Context, serviceName, host string, port int, md discovery.Metadata, opts ...grpc.DialOption) error {
 	r.regMu.Lock()
 	defer r.regMu.Unlock()
 
@@ -447,7 +455,7 @@ func (r *SvcDiscoveryRegistryImpl) Register(ctx context.Context, serviceName, ho
 	registerCtx, cancel := withTimeout(ctx, defaultRegisterTimeout)
 	defer cancel()
 
-	if err := r.registerLocked(registerCtx, serviceName, host, port); err != nil {
+	if err := r.registerLocked(registerCtx, serviceName, host, port, md); err != nil {
 		return err
 	}
 
@@ -458,7 +466,7 @@ func (r *SvcDiscoveryRegistryImpl) Register(ctx context.Context, serviceName, ho
 	return nil
 }
 
-func (r *SvcDiscoveryRegistryImpl) registerLocked(ctx context.Context, serviceName, host string, port int) error {
+func (r *SvcDiscoveryRegistryImpl) registerLocked(ctx context.Context, serviceName, host string, port int, md discovery.Metadata) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -478,6 +486,9 @@ func (r *SvcDiscoveryRegistryImpl) registerLocked(ctx context.Context, serviceNa
 
 	endpointAddr := net.JoinHostPort(host, strconv.Itoa(port))
 	endpoint := endpoints.Endpoint{Addr: endpointAddr}
+	if len(md) > 0 {
+		endpoint.Metadata = md
+	}
 
 	if err := manager.AddEndpoint(ctx, serviceKey, endpoint, clientv3.WithLease(leaseResp.ID)); err != nil {
 		return err
@@ -490,6 +501,7 @@ func (r *SvcDiscoveryRegistryImpl) registerLocked(ctx context.Context, serviceNa
 	r.registeredService = serviceName
 	r.registeredHost = host
 	r.registeredPort = port
+	r.registeredMeta = md
 
 	return nil
 }
@@ -567,7 +579,7 @@ func (r *SvcDiscoveryRegistryImpl) reRegister(ctx context.Context, cause error)
 	retryCtx, cancel := withTimeout(ctx, defaultRegisterTimeout)
 	defer cancel()
 
-	if err := r.registerLocked(retryCtx, r.registeredService, r.registeredHost, r.registeredPort); err != nil {
+	if err := r.registerLocked(retryCtx, r.registeredService, r.registeredHost, r.registeredPort, r.registeredMeta); err != nil {
 		log.ZWarn(context.Background(), "re-register endpoint failed", err, zap.String("service", r.registeredService), zap.String("addr", addr))
 		return false
 	}
@@ -654,6 +666,11 @@ func (r *SvcDiscoveryRegistryImpl) runServiceWatch(ctx context.Context, service
 			if err := r.initializeConnMap(service); err != nil {
 				log.ZWarn(context.Background(), "initializeConnMap in watch err", err, zap.String("service", service))
 			}
+			if gateway := r.gateway.Load(); gateway != nil && gateway.Service() == service {
+				if err := r.updateGateway(ctx, gateway); err != nil {
+					log.ZWarn(context.Background(), "update gateway ring in watch err", err, zap.String("service", service))
+				}
+			}
 		}
 	}
 }
@@ -774,6 +791,7 @@ func (r *SvcDiscoveryRegistryImpl) UnRegister() error {
 	r.registeredService = ""
 	r.registeredHost = ""
 	r.registeredPort = 0
+	r.registeredMeta = nil
 	r.regMu.Unlock()
 
 	if mgr == nil || serviceKey == "" {
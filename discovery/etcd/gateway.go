@@ -0,0 +1,72 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+	"github.com/smartim/tools/errs"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var _ discovery.GatewayRouter = (*SvcDiscoveryRegistryImpl)(nil)
+
+// SetGatewayRing watches the instances of the gateway service and keeps gateway up to date with them.
+func (r *SvcDiscoveryRegistryImpl) SetGatewayRing(ctx context.Context, gateway *hashring.Gateway) error {
+	r.gateway.Store(gateway)
+	if err := r.ensureServiceWatch(gateway.Service()); err != nil {
+		return err
+	}
+	return r.updateGateway(ctx, gateway)
+}
+
+// updateGateway sets the registered instances of the gateway service as the members of gateway.
+func (r *SvcDiscoveryRegistryImpl) updateGateway(ctx context.Context, gateway *hashring.Gateway) error {
+	// The updates are serialized, so that the last one read the latest instances.
+	r.gatewayMu.Lock()
+	defer r.gatewayMu.Unlock()
+	fullPrefix := fmt.Sprintf("%s/%s", r.rootDirectory, gateway.Service())
+	resp, err := r.client.Get(ctx, fullPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return errs.WrapMsg(err, "etcd get gateway instances err", "service", gateway.Service())
+	}
+	addrs := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		prefix, addr := r.splitEndpoint(string(kv.Key))
+		if prefix != fullPrefix {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	gateway.Update(addrs)
+	return nil
+}
+
+// GetUserIdHashGatewayHost returns the gateway instance of userId on the ring set by SetGatewayRing,
+// or "" without it.
+func (r *SvcDiscoveryRegistryImpl) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
+	gateway := r.gateway.Load()
+	if gateway == nil {
+		return "", nil
+	}
+	return gateway.Host(userId)
+}
@@ -0,0 +1,144 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashring
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/smartim/tools/errs"
+)
+
+// ErrNoGateway is returned by Gateway.Host while the gateway service has no instance.
+var ErrNoGateway = errs.New("no gateway instance")
+
+// Change is a membership change of a Gateway.
+type Change struct {
+	Old     *Ring
+	New     *Ring
+	Added   []string
+	Removed []string
+}
+
+// Moved reports whether the owner of key changed, and returns the old and new owners.
+func (c *Change) Moved(key string) (from, to string, moved bool) {
+	from, to = c.Old.Get(key), c.New.Get(key)
+	return from, to, from != to
+}
+
+// ChangeHandler is called on each membership change, the callers holding keys use Change.Moved to
+// find those whose owner moved. It is called from the watch of the discovery backend, and must
+// not block.
+type ChangeHandler func(change *Change)
+
+// Option configures a Gateway.
+type Option func(*Gateway)
+
+// WithReplicas sets the number of virtual nodes of each instance, DefaultReplicas by default.
+func WithReplicas(replicas int) Option {
+	return func(g *Gateway) {
+		if replicas > 0 {
+			g.replicas = replicas
+		}
+	}
+}
+
+// WithChangeHandler adds a handler of the membership changes.
+func WithChangeHandler(fn ChangeHandler) Option {
+	return func(g *Gateway) {
+		g.handlers = append(g.handlers, fn)
+	}
+}
+
+// Gateway routes keys over the instances of a gateway service. The discovery backends keep its
+// members up to date with Update, see discovery.GatewayRouter.
+type Gateway struct {
+	service  string
+	replicas int
+
+	updateLock sync.Mutex // serializes Update, so that the handlers see the changes in order
+	lock       sync.RWMutex
+	ring       *Ring
+	handlers   []ChangeHandler
+}
+
+// NewGateway returns an empty Gateway routing over the instances of service.
+func NewGateway(service string, opts ...Option) *Gateway {
+	g := &Gateway{service: service, replicas: DefaultReplicas}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.ring = New(nil, g.replicas)
+	return g
+}
+
+// Service returns the name of the gateway service.
+func (g *Gateway) Service() string {
+	return g.service
+}
+
+// OnChange adds a handler of the membership changes.
+func (g *Gateway) OnChange(fn ChangeHandler) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.handlers = append(g.handlers, fn)
+}
+
+// Ring returns the current ring.
+func (g *Gateway) Ring() *Ring {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.ring
+}
+
+// Host returns the instance owning key.
+func (g *Gateway) Host(key string) (string, error) {
+	host := g.Ring().Get(key)
+	if host == "" {
+		return "", ErrNoGateway.WrapMsg("gateway service has no instance", "service", g.service)
+	}
+	return host, nil
+}
+
+// Update sets the instances of the gateway service, and calls the change handlers if they changed.
+func (g *Gateway) Update(members []string) {
+	ring := New(members, g.replicas)
+	g.updateLock.Lock()
+	defer g.updateLock.Unlock()
+	g.lock.Lock()
+	old := g.ring
+	if slices.Equal(old.members, ring.members) {
+		g.lock.Unlock()
+		return
+	}
+	g.ring = ring
+	handlers := slices.Clone(g.handlers)
+	g.lock.Unlock()
+
+	change := &Change{Old: old, New: ring}
+	for _, member := range ring.members {
+		if _, found := slices.BinarySearch(old.members, member); !found {
+			change.Added = append(change.Added, member)
+		}
+	}
+	for _, member := range old.members {
+		if _, found := slices.BinarySearch(ring.members, member); !found {
+			change.Removed = append(change.Removed, member)
+		}
+	}
+	for _, fn := range handlers {
+		fn(change)
+	}
+}
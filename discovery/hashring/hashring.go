@@ -0,0 +1,114 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hashring routes keys, such as user IDs, to the instances of a service with a
+// consistent-hash ring of virtual nodes, so that a membership change only moves the keys
+// of the instances that came or went.
+package hashring
+
+import (
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the number of virtual nodes of each member.
+const DefaultReplicas = 160
+
+// Ring is an immutable consistent-hash ring.
+type Ring struct {
+	hashes  []uint64 // sorted
+	owners  []string // member of hashes[i]
+	members []string // sorted
+}
+
+// New builds a ring of the distinct members, each with replicas virtual nodes.
+func New(members []string, replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	members = slices.Clone(members)
+	slices.Sort(members)
+	members = slices.Compact(members)
+	r := &Ring{
+		hashes:  make([]uint64, 0, len(members)*replicas),
+		owners:  make([]string, 0, len(members)*replicas),
+		members: members,
+	}
+	type vnode struct {
+		hash  uint64
+		owner string
+	}
+	vnodes := make([]vnode, 0, len(members)*replicas)
+	for _, member := range members {
+		for i := 0; i < replicas; i++ {
+			vnodes = append(vnodes, vnode{hash: hash(member + "#" + strconv.Itoa(i)), owner: member})
+		}
+	}
+	// Ties between members are broken by name, so that every process builds the same ring.
+	sort.Slice(vnodes, func(i, j int) bool {
+		if vnodes[i].hash != vnodes[j].hash {
+			return vnodes[i].hash < vnodes[j].hash
+		}
+		return vnodes[i].owner < vnodes[j].owner
+	})
+	for _, v := range vnodes {
+		r.hashes = append(r.hashes, v.hash)
+		r.owners = append(r.owners, v.owner)
+	}
+	return r
+}
+
+// Get returns the member owning key, or "" if the ring is empty.
+func (r *Ring) Get(key string) string {
+	if r == nil || len(r.hashes) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[i]
+}
+
+// Members returns the sorted members of the ring.
+func (r *Ring) Members() []string {
+	if r == nil {
+		return nil
+	}
+	return slices.Clone(r.members)
+}
+
+// Len returns the number of members.
+func (r *Ring) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.members)
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	// FNV spreads close strings poorly, the splitmix64 finalizer mixes its bits.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,84 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	members := []string{"10.0.0.1:10001", "10.0.0.2:10001", "10.0.0.3:10001", "10.0.0.4:10001"}
+	ring := New(members, 0)
+	const keys = 40000
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		counts[ring.Get(fmt.Sprintf("user%d", i))]++
+	}
+	for _, member := range members {
+		// Each member owns a quarter of the keys, give or take 20%.
+		if n := counts[member]; n < keys/4*8/10 || n > keys/4*12/10 {
+			t.Fatalf("member %s owns %d keys of %d: %v", member, n, keys, counts)
+		}
+	}
+	if New([]string{members[3], members[1], members[0], members[2], members[0]}, 0).Get("user1") != ring.Get("user1") {
+		t.Fatal("ring depends on the order of the members")
+	}
+	if New(nil, 0).Get("user1") != "" {
+		t.Fatal("empty ring returned a member")
+	}
+}
+
+func TestGateway(t *testing.T) {
+	var changes []*Change
+	g := NewGateway("msg_gateway", WithChangeHandler(func(change *Change) {
+		changes = append(changes, change)
+	}))
+	if _, err := g.Host("user1"); !errors.Is(err, ErrNoGateway) {
+		t.Fatalf("Host on an empty gateway returned %v", err)
+	}
+	g.Update([]string{"a:1", "b:1", "c:1"})
+	g.Update([]string{"c:1", "b:1", "a:1"}) // unchanged
+	g.Update([]string{"a:1", "b:1", "c:1", "d:1"})
+	if len(changes) != 2 {
+		t.Fatalf("%d changes, want 2", len(changes))
+	}
+	change := changes[1]
+	if !slices.Equal(change.Added, []string{"d:1"}) || len(change.Removed) != 0 {
+		t.Fatalf("added %v removed %v", change.Added, change.Removed)
+	}
+	// Adding a member only moves keys to it.
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("user%d", i)
+		from, to, ok := change.Moved(key)
+		if !ok {
+			continue
+		}
+		moved++
+		if to != "d:1" {
+			t.Fatalf("key %s moved from %s to %s", key, from, to)
+		}
+	}
+	if moved == 0 || moved > 10000/4*12/10 {
+		t.Fatalf("%d keys moved", moved)
+	}
+	host, err := g.Host("user1")
+	if err != nil || host != change.New.Get("user1") {
+		t.Fatalf("Host: %s %v", host, err)
+	}
+}
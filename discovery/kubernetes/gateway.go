@@ -0,0 +1,86 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+	"github.com/smartim/tools/errs"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ discovery.GatewayRouter = (*ConnManager)(nil)
+
+// SetGatewayRing keeps gateway up to date with the ready pods of the Endpoints of the gateway service.
+func (k *ConnManager) SetGatewayRing(ctx context.Context, gateway *hashring.Gateway) error {
+	k.gatewayMu.Lock()
+	defer k.gatewayMu.Unlock()
+	k.gateway.Store(gateway)
+	eps, err := k.clientset.CoreV1().Endpoints(k.namespace).Get(ctx, gateway.Service(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		gateway.Update(nil)
+		return nil
+	}
+	if err != nil {
+		return errs.WrapMsg(err, "failed to get endpoints", "serviceName", gateway.Service())
+	}
+	gateway.Update(endpointAddrs(eps))
+	return nil
+}
+
+// updateGateway updates the gateway ring from a change of the Endpoints of its service.
+func (k *ConnManager) updateGateway(endpoint *v1.Endpoints, deleted bool) {
+	gateway := k.gateway.Load()
+	if gateway == nil || gateway.Service() != endpoint.Name {
+		return
+	}
+	k.gatewayMu.Lock()
+	defer k.gatewayMu.Unlock()
+	if deleted {
+		gateway.Update(nil)
+		return
+	}
+	gateway.Update(endpointAddrs(endpoint))
+}
+
+// endpointAddrs returns the addresses of the grpc port of the ready pods.
+func endpointAddrs(eps *v1.Endpoints) []string {
+	var addrs []string
+	for _, subset := range eps.Subsets {
+		for _, address := range subset.Addresses {
+			for _, port := range subset.Ports {
+				if port.Name == GRPCName {
+					addrs = append(addrs, fmt.Sprintf("%s:%d", address.IP, port.Port))
+				}
+			}
+		}
+	}
+	return addrs
+}
+
+// GetUserIdHashGatewayHost returns the gateway pod of userId on the ring set by SetGatewayRing,
+// or "" without it.
+func (k *ConnManager) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
+	gateway := k.gateway.Load()
+	if gateway == nil {
+		return "", nil
+	}
+	return gateway.Host(userId)
+}
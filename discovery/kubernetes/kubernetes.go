@@ -19,10 +19,11 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sercand/kuberesolver/v6"
-	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
 	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/log"
 	"github.com/smartim/tools/utils/datautil"
@@ -48,7 +49,7 @@ type addrConn struct {
 }
 
 type ConnManager struct {
-	clientset   *kubernetes.Clientset
+	clientset   kubernetes.Interface
 	namespace   string
 	dialOptions []grpc.DialOption
 
@@ -59,6 +60,17 @@ type ConnManager struct {
 	watchNames []string
 	connsMu    sync.RWMutex
 	connsMap   map[string][]*addrConn
+
+	// The KeyValue methods are backed by ConfigMaps, and the keys set with a lease by Lease objects.
+	ctx        context.Context
+	cancel     context.CancelFunc
+	kvOnce     sync.Once
+	kvInformer cache.SharedIndexInformer
+	leaseMu    sync.Mutex
+	leases     map[string]*kvLease
+
+	gatewayMu sync.Mutex
+	gateway   atomic.Pointer[hashring.Gateway]
 }
 
 // NewConnManager creates a new connection manager that uses Kubernetes services for service discovery.
@@ -76,17 +88,24 @@ func NewConnManager(namespace string, watchNames []string, options ...grpc.DialO
 
 	kuberesolver.RegisterInCluster()
 
+	k := newConnManager(clientset, namespace, watchNames, options...)
+
+	go k.watchEndpoints()
+
+	return k, nil
+}
+
+func newConnManager(clientset kubernetes.Interface, namespace string, watchNames []string, options ...grpc.DialOption) *ConnManager {
 	k := &ConnManager{
 		clientset:   clientset,
 		namespace:   namespace,
 		dialOptions: options,
 		watchNames:  watchNames,
 		connsMap:    make(map[string][]*addrConn),
+		leases:      make(map[string]*kvLease),
 	}
-
-	go k.watchEndpoints()
-
-	return k, nil
+	k.ctx, k.cancel = context.WithCancel(context.Background())
+	return k
 }
 
 func (k *ConnManager) buildTarget(serviceName string, svcPort int32) string {
@@ -271,8 +290,11 @@ func (k *ConnManager) AddOption(opts ...grpc.DialOption) {
 //	conn.Close()
 //}
 
-// Close closes all gRPC connections managed by ConnManager.
+// Close releases the leases of SetWithLease, deleting their keys, and closes all gRPC connections
+// managed by ConnManager.
 func (k *ConnManager) Close() {
+	k.releaseLeases()
+	k.cancel()
 	k.connsMu.Lock()
 	defer k.connsMu.Unlock()
 	k.resetConnMap()
@@ -286,10 +308,6 @@ func (k *ConnManager) UnRegister() error {
 	return nil
 }
 
-func (k *ConnManager) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
-	return "", nil
-}
-
 func (k *ConnManager) getServicePort(serviceName string) (int32, error) {
 	var svcPort int32
 
@@ -321,13 +339,16 @@ func (k *ConnManager) watchEndpoints() {
 	// Watch for Endpoints changes (add, update, delete)
 	_, _ = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			k.handleEndpointChange(obj)
+			k.handleEndpointChange(obj, false)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			k.handleEndpointChange(newObj)
+			k.handleEndpointChange(newObj, false)
 		},
 		DeleteFunc: func(obj interface{}) {
-			k.handleEndpointChange(obj)
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			k.handleEndpointChange(obj, true)
 		},
 	})
 
@@ -335,12 +356,13 @@ func (k *ConnManager) watchEndpoints() {
 	<-context.Background().Done() // Block forever
 }
 
-func (k *ConnManager) handleEndpointChange(obj interface{}) {
+func (k *ConnManager) handleEndpointChange(obj interface{}, deleted bool) {
 	endpoint, ok := obj.(*v1.Endpoints)
 	if !ok {
 		return
 	}
 	serviceName := endpoint.Name
+	k.updateGateway(endpoint, deleted)
 	if datautil.Contain(serviceName, k.watchNames...) {
 		if err := k.initializeConns(serviceName); err != nil {
 			log.ZWarn(context.Background(), "Error initializing connections", err, "serviceName", serviceName)
@@ -352,30 +374,6 @@ func (k *ConnManager) checkOpts(opts ...grpc.DialOption) error {
 	return nil
 }
 
-func (k *ConnManager) SetKey(ctx context.Context, key string, data []byte) error {
-	return discovery.ErrNotSupported
-}
-
-func (k *ConnManager) SetWithLease(ctx context.Context, key string, val []byte, ttl int64) error {
-	return discovery.ErrNotSupported
-}
-
-func (k *ConnManager) GetKey(ctx context.Context, key string) ([]byte, error) {
-	return nil, discovery.ErrNotSupported
-}
-
-func (k *ConnManager) GetKeyWithPrefix(ctx context.Context, key string) ([][]byte, error) {
-	return nil, discovery.ErrNotSupported
-}
-
-func (k *ConnManager) DelData(ctx context.Context, key string) error {
-	return discovery.ErrNotSupported
-}
-
-func (k *ConnManager) WatchKey(ctx context.Context, key string, fn discovery.WatchKeyHandler) error {
-	return discovery.ErrNotSupported
-}
-
 func (k *ConnManager) resetConnMap() {
 	ctx := context.Background()
 	for _, conn := range k.connsMap {
@@ -0,0 +1,525 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// Each key is stored in a ConfigMap of the namespace, named after the hash of the key since the keys
+// are not valid object names. The key is kept in an annotation and the value in the binary data.
+// A key set with a lease also has a Lease object of the same name, renewed by its holder, and
+// owning the ConfigMap. The expired leases are deleted with their ConfigMap by the ConnManagers
+// using the KeyValue methods.
+const (
+	kvLabel           = "smartim.io/kv"
+	kvKeyAnnotation   = "smartim.io/key"
+	kvLeaseAnnotation = "smartim.io/lease"
+	kvValue           = "value"
+
+	leaseReapInterval = 5 * time.Second
+)
+
+var (
+	kvSelector   = kvLabel + "=true"
+	errLeaseLost = errs.New("lease taken over by another holder")
+)
+
+type kvLease struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func kvName(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return "kv-" + hex.EncodeToString(sum[:])
+}
+
+func leaseHolder() string {
+	if name := os.Getenv("HOSTNAME"); name != "" {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	spec := lease.Spec
+	if spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
+// startKV starts deleting the expired leases on the first use of the KeyValue methods.
+func (k *ConnManager) startKV() {
+	k.kvOnce.Do(func() {
+		factory := informers.NewSharedInformerFactoryWithOptions(k.clientset, time.Minute*10,
+			informers.WithNamespace(k.namespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = kvSelector
+			}))
+		k.kvInformer = factory.Core().V1().ConfigMaps().Informer()
+		factory.Start(k.ctx.Done())
+		go k.reapLeases()
+	})
+}
+
+func (k *ConnManager) SetKey(ctx context.Context, key string, data []byte) error {
+	k.startKV()
+	if err := k.putConfigMap(ctx, key, data, nil); err != nil {
+		return err
+	}
+	// The ConfigMap is not owned by the lease anymore, so deleting it leaves the key.
+	k.releaseLease(ctx, key, false)
+	return nil
+}
+
+// SetWithLease sets key with a Lease of ttl seconds, renewed every third of it until Close. The key
+// is deleted once the lease expires or is released. Setting a key leased by another holder fails.
+func (k *ConnManager) SetWithLease(ctx context.Context, key string, val []byte, ttl int64) error {
+	if ttl <= 0 {
+		return errs.ErrArgs.WrapMsg("invalid lease ttl", "ttl", ttl)
+	}
+	k.startKV()
+	k.leaseMu.Lock()
+	defer k.leaseMu.Unlock()
+	if k.ctx.Err() != nil {
+		return errs.New("kubernetes conn manager closed").Wrap()
+	}
+	if old, ok := k.leases[key]; ok {
+		old.cancel()
+		<-old.done
+		delete(k.leases, key)
+	}
+	lease, err := k.acquireLease(ctx, kvName(key), key, int32(ttl))
+	if err != nil {
+		return err
+	}
+	if err := k.putConfigMap(ctx, key, val, lease); err != nil {
+		return err
+	}
+	renewCtx, cancel := context.WithCancel(k.ctx)
+	l := &kvLease{name: lease.Name, cancel: cancel, done: make(chan struct{})}
+	k.leases[key] = l
+	go k.renewLease(renewCtx, l, time.Duration(ttl)*time.Second/3)
+	return nil
+}
+
+// acquireLease creates the lease of key, or takes it over if it expired.
+func (k *ConnManager) acquireLease(ctx context.Context, name, key string, ttl int32) (*coordinationv1.Lease, error) {
+	leases := k.clientset.CoordinationV1().Leases(k.namespace)
+	holder := leaseHolder()
+	var lease *coordinationv1.Lease
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		now := metav1.NewMicroTime(time.Now())
+		spec := coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &ttl,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		}
+		current, err := leases.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			lease, err = leases.Create(ctx, &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Labels:      map[string]string{kvLabel: "true"},
+					Annotations: map[string]string{kvKeyAnnotation: key},
+				},
+				Spec: spec,
+			}, metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				return errors.NewConflict(coordinationv1.Resource("leases"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if current.Spec.HolderIdentity != nil && *current.Spec.HolderIdentity != holder && !leaseExpired(current, time.Now()) {
+			return errs.New("key is leased by another holder", "key", key, "holder", *current.Spec.HolderIdentity).Wrap()
+		}
+		current.Spec = spec
+		lease, err = leases.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, errs.WrapMsg(err, "acquire lease failed", "key", key)
+	}
+	return lease, nil
+}
+
+func (k *ConnManager) renewLease(ctx context.Context, l *kvLease, interval time.Duration) {
+	defer close(l.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	leases := k.clientset.CoordinationV1().Leases(k.namespace)
+	holder := leaseHolder()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			lease, err := leases.Get(ctx, l.name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+				return errLeaseLost
+			}
+			now := metav1.NewMicroTime(time.Now())
+			lease.Spec.RenewTime = &now
+			_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+			return err
+		})
+		if errors.IsNotFound(err) || errLeaseLost.Is(err) {
+			log.ZWarn(ctx, "lease lost, stop renewing", err, "lease", l.name)
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			log.ZWarn(ctx, "renew lease failed", err, "lease", l.name)
+		}
+	}
+}
+
+// releaseLease stops renewing the lease of key and deletes it, with the key if deleteKey.
+func (k *ConnManager) releaseLease(ctx context.Context, key string, deleteKey bool) {
+	k.leaseMu.Lock()
+	l, ok := k.leases[key]
+	delete(k.leases, key)
+	k.leaseMu.Unlock()
+	if !ok {
+		return
+	}
+	l.cancel()
+	<-l.done
+	if deleteKey {
+		k.deleteConfigMap(ctx, l.name)
+	}
+	if err := k.clientset.CoordinationV1().Leases(k.namespace).Delete(ctx, l.name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		log.ZWarn(ctx, "delete lease failed", err, "key", key)
+	}
+}
+
+// releaseLeases releases all the leases of SetWithLease, deleting their keys.
+func (k *ConnManager) releaseLeases() {
+	k.leaseMu.Lock()
+	keys := make([]string, 0, len(k.leases))
+	for key := range k.leases {
+		keys = append(keys, key)
+	}
+	k.leaseMu.Unlock()
+	for _, key := range keys {
+		k.releaseLease(context.Background(), key, true)
+	}
+}
+
+// reapLeases deletes the expired leases and their keys, left by crashed holders.
+func (k *ConnManager) reapLeases() {
+	ticker := time.NewTicker(leaseReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-k.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		k.reapExpiredLeases(k.ctx)
+	}
+}
+
+func (k *ConnManager) reapExpiredLeases(ctx context.Context) {
+	leases := k.clientset.CoordinationV1().Leases(k.namespace)
+	list, err := leases.List(ctx, metav1.ListOptions{LabelSelector: kvSelector})
+	if err != nil {
+		log.ZWarn(ctx, "list leases failed", err)
+		return
+	}
+	now := time.Now()
+	for i := range list.Items {
+		lease := &list.Items[i]
+		if !leaseExpired(lease, now) {
+			continue
+		}
+		k.deleteLeasedConfigMap(ctx, lease.Name)
+		err := leases.Delete(ctx, lease.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+		})
+		if err != nil && !errors.IsNotFound(err) && !errors.IsConflict(err) {
+			log.ZWarn(ctx, "delete expired lease failed", err, "key", lease.Annotations[kvKeyAnnotation])
+		}
+	}
+}
+
+// putConfigMap creates or updates the ConfigMap of key, owned by lease if not nil.
+func (k *ConnManager) putConfigMap(ctx context.Context, key string, data []byte, lease *coordinationv1.Lease) error {
+	configMaps := k.clientset.CoreV1().ConfigMaps(k.namespace)
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        kvName(key),
+			Labels:      map[string]string{kvLabel: "true"},
+			Annotations: map[string]string{kvKeyAnnotation: key},
+		},
+		BinaryData: map[string][]byte{kvValue: data},
+	}
+	if lease != nil {
+		cm.Annotations[kvLeaseAnnotation] = lease.Name
+		cm.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: coordinationv1.SchemeGroupVersion.String(),
+			Kind:       "Lease",
+			Name:       lease.Name,
+			UID:        lease.UID,
+		}}
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, err := configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		if !errors.IsAlreadyExists(err) {
+			return err
+		}
+		current, err := configMaps.Get(ctx, cm.Name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return errors.NewConflict(v1.Resource("configmaps"), cm.Name, err)
+			}
+			return err
+		}
+		cm.ResourceVersion = current.ResourceVersion
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return errs.WrapMsg(err, "put configmap failed", "key", key)
+	}
+	return nil
+}
+
+func (k *ConnManager) deleteConfigMap(ctx context.Context, name string) {
+	if err := k.clientset.CoreV1().ConfigMaps(k.namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		log.ZWarn(ctx, "delete configmap failed", err, "name", name)
+	}
+}
+
+// deleteLeasedConfigMap deletes the ConfigMap of an expired lease, unless it was set without
+// lease since.
+func (k *ConnManager) deleteLeasedConfigMap(ctx context.Context, name string) {
+	configMaps := k.clientset.CoreV1().ConfigMaps(k.namespace)
+	cm, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.ZWarn(ctx, "get configmap failed", err, "name", name)
+		}
+		return
+	}
+	if _, ok := cm.Annotations[kvLeaseAnnotation]; !ok {
+		return
+	}
+	err = configMaps.Delete(ctx, name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &cm.ResourceVersion},
+	})
+	if err != nil && !errors.IsNotFound(err) && !errors.IsConflict(err) {
+		log.ZWarn(ctx, "delete configmap failed", err, "name", name)
+	}
+}
+
+// liveKey returns the value of cm, or false if it is not a key or its lease expired.
+func liveKey(cm *v1.ConfigMap, leases map[string]*coordinationv1.Lease, now time.Time) ([]byte, bool) {
+	if _, ok := cm.Annotations[kvKeyAnnotation]; !ok {
+		return nil, false
+	}
+	if name, ok := cm.Annotations[kvLeaseAnnotation]; ok {
+		lease, ok := leases[name]
+		if !ok || leaseExpired(lease, now) {
+			return nil, false
+		}
+	}
+	return cm.BinaryData[kvValue], true
+}
+
+func (k *ConnManager) GetKey(ctx context.Context, key string) ([]byte, error) {
+	k.startKV()
+	cm, err := k.clientset.CoreV1().ConfigMaps(k.namespace).Get(ctx, kvName(key), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errs.WrapMsg(err, "get configmap failed", "key", key)
+	}
+	leases := make(map[string]*coordinationv1.Lease)
+	if name, ok := cm.Annotations[kvLeaseAnnotation]; ok {
+		lease, err := k.clientset.CoordinationV1().Leases(k.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, errs.WrapMsg(err, "get lease failed", "key", key)
+		}
+		if err == nil {
+			leases[name] = lease
+		}
+	}
+	value, ok := liveKey(cm, leases, time.Now())
+	if !ok || cm.Annotations[kvKeyAnnotation] != key {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// GetKeyWithPrefix returns the values of the keys starting with key, in the order of the keys.
+func (k *ConnManager) GetKeyWithPrefix(ctx context.Context, key string) ([][]byte, error) {
+	k.startKV()
+	configMaps, err := k.clientset.CoreV1().ConfigMaps(k.namespace).List(ctx, metav1.ListOptions{LabelSelector: kvSelector})
+	if err != nil {
+		return nil, errs.WrapMsg(err, "list configmaps failed", "key", key)
+	}
+	leaseList, err := k.clientset.CoordinationV1().Leases(k.namespace).List(ctx, metav1.ListOptions{LabelSelector: kvSelector})
+	if err != nil {
+		return nil, errs.WrapMsg(err, "list leases failed", "key", key)
+	}
+	leases := make(map[string]*coordinationv1.Lease, len(leaseList.Items))
+	for i := range leaseList.Items {
+		leases[leaseList.Items[i].Name] = &leaseList.Items[i]
+	}
+	items := configMaps.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Annotations[kvKeyAnnotation] < items[j].Annotations[kvKeyAnnotation]
+	})
+	now := time.Now()
+	var values [][]byte
+	for i := range items {
+		if !strings.HasPrefix(items[i].Annotations[kvKeyAnnotation], key) {
+			continue
+		}
+		if value, ok := liveKey(&items[i], leases, now); ok {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+func (k *ConnManager) DelData(ctx context.Context, key string) error {
+	k.startKV()
+	k.releaseLease(ctx, key, false)
+	err := k.clientset.CoreV1().ConfigMaps(k.namespace).Delete(ctx, kvName(key), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return errs.WrapMsg(err, "delete configmap failed", "key", key)
+	}
+	return nil
+}
+
+// WatchKey calls fn for the keys starting with key put or deleted after it started, through an
+// informer on the ConfigMaps of the keys. It returns when ctx is done, fn fails or on Close.
+func (k *ConnManager) WatchKey(ctx context.Context, key string, fn discovery.WatchKeyHandler) error {
+	if ctx == nil {
+		return fmt.Errorf("context is nil")
+	}
+	if fn == nil {
+		return fmt.Errorf("watch handler is nil")
+	}
+	k.startKV()
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(k.ctx, cancel)
+	defer stop()
+	if !cache.WaitForCacheSync(watchCtx.Done(), k.kvInformer.HasSynced) {
+		return ctx.Err()
+	}
+	// The watch starts at the version the informer reached: the objects it holds then are replayed
+	// to a new handler and are no changes, unlike those it receives while the handler is added.
+	start, versioned := parseResourceVersion(k.kvInformer.LastSyncResourceVersion())
+	changed := func(obj any, isInInitialList bool) bool {
+		if cm, ok := obj.(*v1.ConfigMap); ok && versioned {
+			if version, ok := parseResourceVersion(cm.ResourceVersion); ok {
+				return version > start
+			}
+		}
+		return !isInInitialList
+	}
+	queue := discovery.NewWatchQueue()
+	push := func(obj any, typ discovery.WatchType) {
+		cm, ok := obj.(*v1.ConfigMap)
+		if !ok {
+			return
+		}
+		name, ok := cm.Annotations[kvKeyAnnotation]
+		if !ok || !strings.HasPrefix(name, key) {
+			return
+		}
+		event := &discovery.WatchKey{Key: []byte(name), Type: typ}
+		if typ == discovery.WatchTypePut {
+			event.Value = cm.BinaryData[kvValue]
+		}
+		queue.Push(event)
+	}
+	registration, err := k.kvInformer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj any, isInInitialList bool) {
+			if changed(obj, isInInitialList) {
+				push(obj, discovery.WatchTypePut)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			// The periodic resyncs send the same object again.
+			if oldObj.(*v1.ConfigMap).ResourceVersion != newObj.(*v1.ConfigMap).ResourceVersion && changed(newObj, false) {
+				push(newObj, discovery.WatchTypePut)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			push(obj, discovery.WatchTypeDelete)
+		},
+	})
+	if err != nil {
+		return errs.WrapMsg(err, "add configmap event handler failed", "key", key)
+	}
+	defer func() {
+		if err := k.kvInformer.RemoveEventHandler(registration); err != nil {
+			log.ZWarn(ctx, "remove configmap event handler failed", err, "key", key)
+		}
+	}()
+	err = queue.Run(watchCtx, fn)
+	if watchCtx.Err() != nil {
+		// Close only stops the watch, it is not an error.
+		return ctx.Err()
+	}
+	return err
+}
+
+// parseResourceVersion parses a resource version, which the API server sets from an increasing
+// number although clients should treat it as opaque. ok is false when it is not a number.
+func parseResourceVersion(version string) (v uint64, ok bool) {
+	v, err := strconv.ParseUint(version, 10, 64)
+	return v, err == nil
+}
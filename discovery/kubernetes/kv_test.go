@@ -0,0 +1,181 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/discovery"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestKeyValue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	clientset := fake.NewSimpleClientset()
+	// The fake clientset leaves the resource versions empty, which the API server increments.
+	var version atomic.Int64
+	clientset.PrependReactor("*", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action, ok := action.(interface{ GetObject() runtime.Object }); ok {
+			if cm, ok := action.GetObject().(*v1.ConfigMap); ok {
+				cm.ResourceVersion = strconv.FormatInt(version.Add(1), 10)
+			}
+		}
+		return false, nil, nil
+	})
+	k := newConnManager(clientset, "default", nil)
+	defer k.Close()
+
+	if err := k.SetKey(ctx, "conf/before", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	// The key is set before the watch starts once the informer holds it.
+	for {
+		if _, ok, _ := k.kvInformer.GetStore().GetByKey("default/" + kvName("conf/before")); ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("conf/before not received by the informer")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	events := make(chan *discovery.WatchKey, 8)
+	started := make(chan struct{})
+	var once sync.Once
+	go k.WatchKey(ctx, "conf/", func(data *discovery.WatchKey) error {
+		if string(data.Key) == "conf/started" {
+			once.Do(func() { close(started) })
+			return nil
+		}
+		events <- data
+		return nil
+	})
+	// The watch has started once it reports a change.
+	for i := 0; ; i++ {
+		if err := k.SetKey(ctx, "conf/started", []byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("watch not started")
+		case <-time.After(50 * time.Millisecond):
+			continue
+		case <-started:
+		}
+		break
+	}
+	if err := k.DelData(ctx, "conf/started"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := k.SetKey(ctx, "conf/b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetKey(ctx, "conf/a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetKey(ctx, "other", []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.DelData(ctx, "conf/before"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := k.GetKey(ctx, "conf/a"); err != nil || string(v) != "1" {
+		t.Fatalf("GetKey: %q %v", v, err)
+	}
+	if v, err := k.GetKey(ctx, "conf/before"); err != nil || v != nil {
+		t.Fatalf("GetKey deleted: %q %v", v, err)
+	}
+	values, err := k.GetKeyWithPrefix(ctx, "conf/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || string(values[0]) != "1" || string(values[1]) != "2" {
+		t.Fatalf("GetKeyWithPrefix: %q", values)
+	}
+	want := []struct {
+		key string
+		typ discovery.WatchType
+	}{
+		{"conf/b", discovery.WatchTypePut},
+		{"conf/a", discovery.WatchTypePut},
+		{"conf/before", discovery.WatchTypeDelete},
+	}
+	for _, w := range want {
+		select {
+		case event := <-events:
+			if string(event.Key) != w.key || event.Type != w.typ {
+				t.Fatalf("got event %s %s, want %s %s", event.Type, event.Key, w.typ, w.key)
+			}
+		case <-ctx.Done():
+			t.Fatalf("missing event %s %s", w.typ, w.key)
+		}
+	}
+
+	if err := k.SetWithLease(ctx, "lease/held", []byte("v"), 30); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := k.GetKey(ctx, "lease/held"); err != nil || string(v) != "v" {
+		t.Fatalf("GetKey leased: %q %v", v, err)
+	}
+
+	// A key left by a crashed holder is hidden once its lease expired, then reaped.
+	holder, ttl := "crashed", int32(1)
+	expired := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+	lease, err := clientset.CoordinationV1().Leases("default").Create(ctx, &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        kvName("lease/crashed"),
+			Labels:      map[string]string{kvLabel: "true"},
+			Annotations: map[string]string{kvKeyAnnotation: "lease/crashed"},
+		},
+		Spec: coordinationv1.LeaseSpec{HolderIdentity: &holder, LeaseDurationSeconds: &ttl, RenewTime: &expired},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.putConfigMap(ctx, "lease/crashed", []byte("v"), lease); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := k.GetKey(ctx, "lease/crashed"); err != nil || v != nil {
+		t.Fatalf("GetKey expired: %q %v", v, err)
+	}
+	if values, err := k.GetKeyWithPrefix(ctx, "lease/"); err != nil || len(values) != 1 {
+		t.Fatalf("GetKeyWithPrefix leased: %q %v", values, err)
+	}
+	k.reapExpiredLeases(ctx)
+	if _, err := clientset.CoreV1().ConfigMaps("default").Get(ctx, kvName("lease/crashed"), metav1.GetOptions{}); err == nil {
+		t.Fatal("expired key not reaped")
+	}
+
+	// Close releases the leases held.
+	k.Close()
+	if _, err := clientset.CoreV1().ConfigMaps("default").Get(ctx, kvName("lease/held"), metav1.GetOptions{}); err == nil {
+		t.Fatal("leased key left after Close")
+	}
+	if _, err := clientset.CoordinationV1().Leases("default").Get(ctx, kvName("lease/held"), metav1.GetOptions{}); err == nil {
+		t.Fatal("lease left after Close")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+// The metadata keys used by the balancer of discovery/balancer.
+const (
+	MetadataZone    = "zone"
+	MetadataVersion = "version"
+	MetadataWeight  = "weight"
+)
+
+// Metadata describes an instance, such as its zone, version and weight. It is advertised with the
+// address of the instance by RegisterWithMetadata.
+type Metadata map[string]string
+
+// Equal reports whether o is the same Metadata, as required by the address attributes.
+func (m Metadata) Equal(o any) bool {
+	other, ok := o.(Metadata)
+	return ok && maps.Equal(m, other)
+}
+
+// MetadataRegistrar is implemented by the registries storing the metadata of the instances with
+// their address, and passing it to the balancer with the resolved addresses.
+type MetadataRegistrar interface {
+	// RegisterWithMetadata is Register advertising md with the address.
+	RegisterWithMetadata(ctx context.Context, serviceName, host string, port int, md Metadata, opts ...grpc.DialOption) error
+}
+
+type metadataKey struct{}
+
+// SetAddressMetadata returns addr carrying md for the balancer.
+func SetAddressMetadata(addr resolver.Address, md Metadata) resolver.Address {
+	if len(md) == 0 {
+		return addr
+	}
+	addr.BalancerAttributes = addr.BalancerAttributes.WithValue(metadataKey{}, md)
+	return addr
+}
+
+// AddressMetadata returns the metadata of a resolved address, set by SetAddressMetadata or, for the
+// resolver of etcd, decoded from the endpoint.
+func AddressMetadata(addr resolver.Address) Metadata {
+	if md, ok := addr.BalancerAttributes.Value(metadataKey{}).(Metadata); ok {
+		return md
+	}
+	//nolint:staticcheck // The etcd resolver only sets the deprecated Metadata field.
+	switch md := addr.Metadata.(type) {
+	case Metadata:
+		return md
+	case map[string]string:
+		return md
+	case map[string]any:
+		out := make(Metadata, len(md))
+		for k, v := range md {
+			if s, ok := v.(string); ok {
+				out[k] = s
+			} else {
+				out[k] = fmt.Sprint(v)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/smartim/tools/discovery"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+	"google.golang.org/grpc/resolver"
+)
+
+func TestAddressMetadata(t *testing.T) {
+	// The etcd resolver passes the metadata of the endpoint as decoded from its JSON value.
+	data, err := json.Marshal(endpoints.Endpoint{Addr: "a:1", Metadata: discovery.Metadata{
+		discovery.MetadataZone:   "az1",
+		discovery.MetadataWeight: "5",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var endpoint endpoints.Endpoint
+	if err := json.Unmarshal(data, &endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := endpoint.Metadata.(map[string]any); !ok {
+		t.Fatalf("endpoint metadata decoded as %T", endpoint.Metadata)
+	}
+	md := discovery.AddressMetadata(resolver.Address{Addr: endpoint.Addr, Metadata: endpoint.Metadata})
+	if md[discovery.MetadataZone] != "az1" || md[discovery.MetadataWeight] != "5" {
+		t.Fatalf("metadata %v", md)
+	}
+
+	// Values written by other clients may not be strings.
+	md = discovery.AddressMetadata(resolver.Address{Addr: "a:1", Metadata: map[string]any{discovery.MetadataWeight: float64(5)}})
+	if md[discovery.MetadataWeight] != "5" {
+		t.Fatalf("metadata %v", md)
+	}
+	if md := discovery.AddressMetadata(resolver.Address{Addr: "a:1"}); md != nil {
+		t.Fatalf("metadata %v", md)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standalone
+
+import (
+	"context"
+
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+)
+
+var _ discovery.GatewayRouter = (*svcDiscoveryRegistry)(nil)
+
+// SetGatewayRing keeps gateway up to date with the instances of the gateway service passed to Register.
+func (x *svcDiscoveryRegistry) SetGatewayRing(ctx context.Context, gateway *hashring.Gateway) error {
+	x.instanceLock.Lock()
+	defer x.instanceLock.Unlock()
+	x.gateway = gateway
+	gateway.Update(x.instances[gateway.Service()])
+	return nil
+}
+
+// GetUserIdHashGatewayHost returns the gateway instance of userId on the ring set by SetGatewayRing,
+// or "" without it.
+func (x *svcDiscoveryRegistry) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
+	x.instanceLock.Lock()
+	gateway := x.gateway
+	x.instanceLock.Unlock()
+	if gateway == nil {
+		return "", nil
+	}
+	return gateway.Host(userId)
+}
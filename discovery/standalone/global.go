@@ -16,8 +16,13 @@ package standalone
 
 import (
 	"context"
+	"net"
+	"strconv"
+	"sync"
 
 	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+	"github.com/smartim/tools/utils/datautil"
 	"google.golang.org/grpc"
 )
 
@@ -51,16 +56,31 @@ type svcDiscoveryRegistry struct {
 	discovery.Conn
 	grpc.ServiceRegistrar
 	keyValue
+
+	instanceLock sync.Mutex
+	instances    map[string][]string
+	gateway      *hashring.Gateway
 }
 
 func (x *svcDiscoveryRegistry) AddOption(opts ...grpc.DialOption) {}
 
+// Register records the instance, the services being called in process. The instances of the gateway
+// service are the members of the ring of SetGatewayRing.
 func (x *svcDiscoveryRegistry) Register(ctx context.Context, serviceName, host string, port int, opts ...grpc.DialOption) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	x.instanceLock.Lock()
+	defer x.instanceLock.Unlock()
+	if datautil.Contain(addr, x.instances[serviceName]...) {
+		return nil
+	}
+	if x.instances == nil {
+		x.instances = make(map[string][]string)
+	}
+	x.instances[serviceName] = append(x.instances[serviceName], addr)
+	if x.gateway != nil && x.gateway.Service() == serviceName {
+		x.gateway.Update(x.instances[serviceName])
+	}
 	return nil
 }
 
 func (x *svcDiscoveryRegistry) Close() {}
-
-func (x *svcDiscoveryRegistry) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
-	return "", nil
-}
@@ -16,30 +16,60 @@ package standalone
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/smartim/tools/discovery"
 )
 
+// keyValue is an in-memory discovery.KeyValue. The keys are also kept sorted, so that the
+// prefix scans are a binary search followed by a range.
 type keyValue struct {
-	lock sync.RWMutex
-	kv   map[string][]byte
+	lock     sync.RWMutex
+	kv       map[string][]byte
+	keys     []string
+	leases   map[string]*time.Timer
+	watchers map[*discovery.WatchQueue]string // prefix watched by queue
 }
 
 func (x *keyValue) SetKey(ctx context.Context, key string, data []byte) error {
 	tmp := make([]byte, len(data))
 	copy(tmp, data)
 	x.lock.Lock()
-	if x.kv == nil {
-		x.kv = make(map[string][]byte)
-	}
-	x.kv[key] = tmp
+	x.put(key, tmp)
 	x.lock.Unlock()
 	return nil
 }
 
+// SetWithLease sets key for ttl seconds, after which it is deleted. Setting or deleting the key
+// before cancels the lease.
 func (x *keyValue) SetWithLease(ctx context.Context, key string, val []byte, ttl int64) error {
-	return discovery.ErrNotSupported
+	if ttl <= 0 {
+		return fmt.Errorf("invalid lease ttl %d", ttl)
+	}
+	tmp := make([]byte, len(val))
+	copy(tmp, val)
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.put(key, tmp)
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Duration(ttl)*time.Second, func() {
+		x.lock.Lock()
+		defer x.lock.Unlock()
+		// The key was set again or deleted since.
+		if x.leases[key] != timer {
+			return
+		}
+		x.del(key)
+	})
+	if x.leases == nil {
+		x.leases = make(map[string]*time.Timer)
+	}
+	x.leases[key] = timer
+	return nil
 }
 
 func (x *keyValue) GetKey(ctx context.Context, key string) ([]byte, error) {
@@ -55,19 +85,90 @@ func (x *keyValue) GetKey(ctx context.Context, key string) ([]byte, error) {
 	return nil, nil
 }
 
+// GetKeyWithPrefix returns the values of the keys starting with key, in the order of the keys.
 func (x *keyValue) GetKeyWithPrefix(ctx context.Context, key string) ([][]byte, error) {
-	return nil, discovery.ErrNotSupported
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	var values [][]byte
+	for i := sort.SearchStrings(x.keys, key); i < len(x.keys) && strings.HasPrefix(x.keys[i], key); i++ {
+		v := x.kv[x.keys[i]]
+		tmp := make([]byte, len(v))
+		copy(tmp, v)
+		values = append(values, tmp)
+	}
+	return values, nil
 }
 
 func (x *keyValue) DelData(ctx context.Context, key string) error {
 	x.lock.Lock()
-	if x.kv != nil {
-		delete(x.kv, key)
-	}
+	x.del(key)
 	x.lock.Unlock()
 	return nil
 }
 
+// WatchKey calls fn for the keys starting with key put or deleted after it started, in order.
+// It returns when ctx is done or fn fails.
 func (x *keyValue) WatchKey(ctx context.Context, key string, fn discovery.WatchKeyHandler) error {
-	return discovery.ErrNotSupported
+	if ctx == nil {
+		return fmt.Errorf("context is nil")
+	}
+	if fn == nil {
+		return fmt.Errorf("watch handler is nil")
+	}
+	queue := discovery.NewWatchQueue()
+	x.lock.Lock()
+	if x.watchers == nil {
+		x.watchers = make(map[*discovery.WatchQueue]string)
+	}
+	x.watchers[queue] = key
+	x.lock.Unlock()
+	defer func() {
+		x.lock.Lock()
+		delete(x.watchers, queue)
+		x.lock.Unlock()
+	}()
+	return queue.Run(ctx, fn)
+}
+
+// put sets key, x.lock held.
+func (x *keyValue) put(key string, value []byte) {
+	if x.kv == nil {
+		x.kv = make(map[string][]byte)
+	}
+	if _, ok := x.kv[key]; !ok {
+		i := sort.SearchStrings(x.keys, key)
+		x.keys = append(x.keys, "")
+		copy(x.keys[i+1:], x.keys[i:])
+		x.keys[i] = key
+	}
+	x.kv[key] = value
+	x.cancelLease(key)
+	x.notify(&discovery.WatchKey{Key: []byte(key), Value: append([]byte(nil), value...), Type: discovery.WatchTypePut})
+}
+
+// del deletes key, x.lock held.
+func (x *keyValue) del(key string) {
+	if _, ok := x.kv[key]; !ok {
+		return
+	}
+	delete(x.kv, key)
+	i := sort.SearchStrings(x.keys, key)
+	x.keys = append(x.keys[:i], x.keys[i+1:]...)
+	x.cancelLease(key)
+	x.notify(&discovery.WatchKey{Key: []byte(key), Type: discovery.WatchTypeDelete})
+}
+
+func (x *keyValue) cancelLease(key string) {
+	if timer, ok := x.leases[key]; ok {
+		timer.Stop()
+		delete(x.leases, key)
+	}
+}
+
+func (x *keyValue) notify(event *discovery.WatchKey) {
+	for queue, prefix := range x.watchers {
+		if strings.HasPrefix(string(event.Key), prefix) {
+			queue.Push(event)
+		}
+	}
 }
@@ -0,0 +1,98 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standalone
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/discovery"
+)
+
+func TestKeyValue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	var kv keyValue
+
+	events := make(chan *discovery.WatchKey, 8)
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- kv.WatchKey(watchCtx, "conf/", func(data *discovery.WatchKey) error {
+			events <- data
+			return nil
+		})
+	}()
+	for {
+		kv.lock.RLock()
+		n := len(kv.watchers)
+		kv.lock.RUnlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, key := range []string{"conf/b", "conf/a", "other", "conf/c"} {
+		if err := kv.SetKey(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := kv.DelData(ctx, "conf/c"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := kv.GetKeyWithPrefix(ctx, "conf/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || string(values[0]) != "conf/a" || string(values[1]) != "conf/b" {
+		t.Fatalf("GetKeyWithPrefix: %q", values)
+	}
+
+	if err := kv.SetWithLease(ctx, "conf/lease", []byte("v"), 1); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		key string
+		typ discovery.WatchType
+	}{
+		{"conf/b", discovery.WatchTypePut},
+		{"conf/a", discovery.WatchTypePut},
+		{"conf/c", discovery.WatchTypePut},
+		{"conf/c", discovery.WatchTypeDelete},
+		{"conf/lease", discovery.WatchTypePut},
+		{"conf/lease", discovery.WatchTypeDelete}, // expired
+	}
+	for _, w := range want {
+		select {
+		case event := <-events:
+			if string(event.Key) != w.key || event.Type != w.typ {
+				t.Fatalf("got event %s %s, want %s %s", event.Type, event.Key, w.typ, w.key)
+			}
+		case <-ctx.Done():
+			t.Fatalf("missing event %s %s", w.typ, w.key)
+		}
+	}
+	if v, err := kv.GetKey(ctx, "conf/lease"); err != nil || v != nil {
+		t.Fatalf("expired key still set: %q %v", v, err)
+	}
+
+	stopWatch()
+	if err := <-watchErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("WatchKey returned %v", err)
+	}
+}
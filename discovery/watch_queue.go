@@ -0,0 +1,68 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"sync"
+)
+
+// WatchQueue queues the events of a WatchKey call between the backend that produces them and
+// the handler, so that a slow handler never blocks the backend.
+type WatchQueue struct {
+	notify chan struct{}
+
+	lock   sync.Mutex
+	events []*WatchKey
+}
+
+func NewWatchQueue() *WatchQueue {
+	return &WatchQueue{notify: make(chan struct{}, 1)}
+}
+
+// Push queues event without blocking.
+func (q *WatchQueue) Push(event *WatchKey) {
+	q.lock.Lock()
+	q.events = append(q.events, event)
+	q.lock.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run calls fn with the queued events in order, until ctx is done or fn fails.
+func (q *WatchQueue) Run(ctx context.Context, fn WatchKeyHandler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.notify:
+		}
+		for _, event := range q.take() {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (q *WatchQueue) take() []*WatchKey {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	events := q.events
+	q.events = nil
+	return events
+}
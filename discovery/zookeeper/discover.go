@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/go-zookeeper/zk"
+	"github.com/smartim/tools/discovery"
 	"github.com/smartim/tools/errs"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/resolver"
@@ -44,7 +45,7 @@ func (s *ZkClient) watch(ctx context.Context) {
 				case zk.StateHasSession:
 					if s.isRegistered && !s.isStateDisconnected {
 						s.logger.Debug(ctx, "zk session event stateHasSession, client prepare to create new temp node", "event", event)
-						node, err := s.CreateTempNode(s.rpcRegisterName, s.rpcRegisterAddr)
+						node, err := s.createTempNode(s.rpcRegisterName, s.rpcRegisterAddr, s.rpcRegisterMeta)
 						if err != nil {
 							s.logger.Error(ctx, "zk session event stateHasSession, create temp node error", err, "event", event)
 						} else {
@@ -66,6 +67,11 @@ func (s *ZkClient) watch(ctx context.Context) {
 					s.lock.Lock()
 					s.flushResolverAndDeleteLocal(serviceName)
 					s.lock.Unlock()
+					if gateway := s.gateway.Load(); gateway != nil && gateway.Service() == serviceName {
+						if err := s.updateGateway(ctx, gateway); err != nil {
+							s.logger.Error(ctx, "update gateway ring error", err, "serviceName", serviceName)
+						}
+					}
 				}
 				s.logger.Debug(ctx, "zk event handle success", "path", event.Path)
 			case zk.EventNodeDataChanged:
@@ -100,17 +106,13 @@ func (s *ZkClient) GetConnsRemote(ctx context.Context, serviceName string) (conn
 				return nil, errs.WrapMsg(err, "get children error", "fullPath", fullPath)
 			}
 			s.logger.Debug(ctx, "get addr from remote", "conn", string(data))
-			conns = append(conns, resolver.Address{Addr: string(data), ServerName: serviceName})
+			addr, md := decodeNode(data)
+			conns = append(conns, discovery.SetAddressMetadata(resolver.Address{Addr: addr, ServerName: serviceName}, md))
 		}
 	}
 	return conns, nil
 }
 
-func (s *ZkClient) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
-	s.logger.Warn(ctx, "not implement", errs.New("zkclinet not implement GetUserIdHashGatewayHost method"))
-	return "", nil
-}
-
 func (s *ZkClient) GetConns(ctx context.Context, serviceName string, opts ...grpc.DialOption) ([]grpc.ClientConnInterface, error) {
 	s.logger.Debug(ctx, "get conns from client", "serviceName", serviceName)
 	s.lock.Lock()
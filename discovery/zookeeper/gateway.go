@@ -0,0 +1,56 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"context"
+
+	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
+)
+
+var _ discovery.GatewayRouter = (*ZkClient)(nil)
+
+// SetGatewayRing keeps gateway up to date with the registered instances of the gateway service.
+func (s *ZkClient) SetGatewayRing(ctx context.Context, gateway *hashring.Gateway) error {
+	s.gateway.Store(gateway)
+	return s.updateGateway(ctx, gateway)
+}
+
+// updateGateway reads the instances of the gateway service, which also watches its children again.
+func (s *ZkClient) updateGateway(ctx context.Context, gateway *hashring.Gateway) error {
+	s.gatewayMu.Lock()
+	defer s.gatewayMu.Unlock()
+	addrs, err := s.GetConnsRemote(ctx, gateway.Service())
+	if err != nil {
+		return err
+	}
+	members := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		members = append(members, addr.Addr)
+	}
+	gateway.Update(members)
+	return nil
+}
+
+// GetUserIdHashGatewayHost returns the gateway instance of userId on the ring set by SetGatewayRing,
+// or "" without it.
+func (s *ZkClient) GetUserIdHashGatewayHost(ctx context.Context, userId string) (string, error) {
+	gateway := s.gateway.Load()
+	if gateway == nil {
+		return "", nil
+	}
+	return gateway.Host(userId)
+}
@@ -0,0 +1,47 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/smartim/tools/discovery"
+)
+
+// nodeData is the data of a temp node registered with metadata. A node registered without
+// metadata holds the plain address, as read by the older clients.
+type nodeData struct {
+	Addr     string             `json:"addr"`
+	Metadata discovery.Metadata `json:"metadata,omitempty"`
+}
+
+func encodeNode(addr string, md discovery.Metadata) ([]byte, error) {
+	if len(md) == 0 {
+		return []byte(addr), nil
+	}
+	return json.Marshal(nodeData{Addr: addr, Metadata: md})
+}
+
+func decodeNode(data []byte) (string, discovery.Metadata) {
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return string(data), nil
+	}
+	var node nodeData
+	if err := json.Unmarshal(data, &node); err != nil {
+		return string(data), nil
+	}
+	return node.Addr, node.Metadata
+}
@@ -0,0 +1,53 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/smartim/tools/discovery"
+)
+
+func TestNodeData(t *testing.T) {
+	md := discovery.Metadata{discovery.MetadataZone: "az1", discovery.MetadataVersion: "v2"}
+	data, err := encodeNode("127.0.0.1:10001", md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, decoded := decodeNode(data)
+	if addr != "127.0.0.1:10001" || !decoded.Equal(md) {
+		t.Fatalf("decoded %s %v", addr, decoded)
+	}
+
+	// Without metadata the node holds the plain address, as written by the older clients.
+	data, err = encodeNode("127.0.0.1:10002", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte("127.0.0.1:10002")) {
+		t.Fatalf("encoded %q", data)
+	}
+	for _, data := range [][]byte{data, []byte("127.0.0.1:10002")} {
+		if addr, md := decodeNode(data); addr != "127.0.0.1:10002" || md != nil {
+			t.Fatalf("decoded %s %v", addr, md)
+		}
+	}
+
+	// A node that is not valid JSON is taken as a plain address.
+	if addr, md := decodeNode([]byte("{broken")); addr != "{broken" || md != nil {
+		t.Fatalf("decoded %s %v", addr, md)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/go-zookeeper/zk"
+	"github.com/smartim/tools/discovery"
 	"github.com/smartim/tools/errs"
 	"google.golang.org/grpc"
 )
@@ -32,10 +33,20 @@ func (s *ZkClient) CreateRpcRootNodes(serviceNames []string) error {
 	return nil
 }
 
+var _ discovery.MetadataRegistrar = (*ZkClient)(nil)
+
 func (s *ZkClient) CreateTempNode(rpcRegisterName, addr string) (node string, err error) {
+	return s.createTempNode(rpcRegisterName, addr, nil)
+}
+
+func (s *ZkClient) createTempNode(rpcRegisterName, addr string, md discovery.Metadata) (node string, err error) {
+	data, err := encodeNode(addr, md)
+	if err != nil {
+		return "", errs.WrapMsg(err, "encode node data failed", "addr", addr)
+	}
 	node, err = s.conn.CreateProtectedEphemeralSequential(
 		s.getPath(rpcRegisterName)+"/"+addr+"_",
-		[]byte(addr),
+		data,
 		zk.WorldACL(zk.PermAll),
 	)
 	if err != nil {
@@ -45,6 +56,14 @@ func (s *ZkClient) CreateTempNode(rpcRegisterName, addr string) (node string, er
 }
 
 func (s *ZkClient) Register(ctx context.Context, rpcRegisterName, host string, port int, opts ...grpc.DialOption) error {
+	return s.RegisterWithMetadata(ctx, rpcRegisterName, host, port, nil, opts...)
+}
+
+// RegisterWithMetadata is Register storing md with the address in the temp node, as JSON.
+// Clients from before the metadata support read the node data as the plain address and can't
+// dial such an instance, so during a rolling upgrade md must only be passed once every client
+// of the service reads it: until then, Register keeps the plain address.
+func (s *ZkClient) RegisterWithMetadata(ctx context.Context, rpcRegisterName, host string, port int, md discovery.Metadata, opts ...grpc.DialOption) error {
 	if err := s.ensureName(rpcRegisterName); err != nil {
 		return err
 	}
@@ -53,12 +72,13 @@ func (s *ZkClient) Register(ctx context.Context, rpcRegisterName, host string, p
 	if err != nil {
 		return errs.WrapMsg(err, "grpc dial error", "addr", addr)
 	}
-	node, err := s.CreateTempNode(rpcRegisterName, addr)
+	node, err := s.createTempNode(rpcRegisterName, addr, md)
 	if err != nil {
 		return err
 	}
 	s.rpcRegisterName = rpcRegisterName
 	s.rpcRegisterAddr = addr
+	s.rpcRegisterMeta = md
 	s.node = node
 	s.isRegistered = true
 	return nil
@@ -75,6 +95,7 @@ func (s *ZkClient) UnRegister() error {
 	s.node = ""
 	s.rpcRegisterName = ""
 	s.rpcRegisterAddr = ""
+	s.rpcRegisterMeta = nil
 	s.isRegistered = false
 	s.localConns = make(map[string][]grpc.ClientConnInterface)
 	s.resolvers = make(map[string]*Resolver)
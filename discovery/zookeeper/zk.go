@@ -19,10 +19,12 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-zookeeper/zk"
 	"github.com/smartim/tools/discovery"
+	"github.com/smartim/tools/discovery/hashring"
 	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/log"
 	"google.golang.org/grpc"
@@ -42,6 +44,7 @@ type ZkClient struct {
 
 	rpcRegisterName string
 	rpcRegisterAddr string
+	rpcRegisterMeta discovery.Metadata
 	isRegistered    bool
 	scheme          string
 
@@ -60,6 +63,9 @@ type ZkClient struct {
 	isStateDisconnected bool
 	balancerName        string
 
+	gatewayMu sync.Mutex
+	gateway   atomic.Pointer[hashring.Gateway]
+
 	logger log.Logger
 }
 
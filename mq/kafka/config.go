@@ -28,6 +28,9 @@ func BuildConsumerGroupConfig(conf *Config, initial int64, autoCommitEnable bool
 	kfk.Consumer.Offsets.Initial = initial
 	kfk.Consumer.Offsets.AutoCommit.Enable = autoCommitEnable
 	kfk.Consumer.Return.Errors = false
+	if strings.ToLower(conf.IsolationLevel) == "read_committed" {
+		kfk.Consumer.IsolationLevel = sarama.ReadCommitted
+	}
 	if conf.ConsumerFetchDefaultBytes > 0 {
 		kfk.Consumer.Fetch.Default = int32(conf.ConsumerFetchDefaultBytes)
 	}
@@ -88,6 +91,9 @@ func BuildProducerConfig(conf Config) (*sarama.Config, error) {
 	if conf.MaxMessageBytes > 0 {
 		kfk.Producer.MaxMessageBytes = conf.MaxMessageBytes
 	}
+	if conf.Idempotent {
+		setIdempotent(kfk)
+	}
 	if conf.TLS.EnableTLS {
 		tls, err := newTLSConfig(conf.TLS.ClientCrt, conf.TLS.ClientKey, conf.TLS.CACrt, []byte(conf.TLS.ClientKeyPwd), conf.TLS.InsecureSkipVerify)
 		if err != nil {
@@ -99,6 +105,14 @@ func BuildProducerConfig(conf Config) (*sarama.Config, error) {
 	return kfk, nil
 }
 
+// setIdempotent makes the producer write every message exactly once per partition,
+// which requires acknowledgements from all replicas and a single in-flight request.
+func setIdempotent(kfk *sarama.Config) {
+	kfk.Producer.Idempotent = true
+	kfk.Producer.RequiredAcks = sarama.WaitForAll
+	kfk.Net.MaxOpenRequests = 1
+}
+
 func NewProducer(conf *sarama.Config, addr []string) (sarama.SyncProducer, error) {
 	producer, err := sarama.NewSyncProducer(addr, conf)
 	if err != nil {
@@ -126,4 +140,11 @@ type Config struct {
 	ConsumerFetchMaxBytes     int       `yaml:"consumerFetchMaxBytes"`
 	Addr                      []string  `yaml:"addr"`
 	TLS                       TLSConfig `yaml:"tls"`
+
+	// Idempotent enables the idempotent producer, which never writes a message twice on retries.
+	Idempotent bool `yaml:"idempotent"`
+
+	// IsolationLevel is "read_uncommitted" (default) or "read_committed", which hides the
+	// messages of aborted and open transactions from consumers.
+	IsolationLevel string `yaml:"isolationLevel"`
 }
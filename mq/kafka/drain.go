@@ -0,0 +1,156 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/IBM/sarama"
+	"github.com/smartim/tools/errs"
+)
+
+// handlerGate holds the Subscribe calls while paused, and tracks the handlers in flight for Drain.
+type handlerGate struct {
+	lock     sync.Mutex
+	resumed  chan struct{} // nil while not paused, closed by Resume
+	draining chan struct{} // closed by Drain
+	inflight sync.WaitGroup
+}
+
+func newHandlerGate() *handlerGate {
+	return &handlerGate{draining: make(chan struct{})}
+}
+
+func (g *handlerGate) pause() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+	}
+}
+
+func (g *handlerGate) resume() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// paused reports whether the partitions must not be fetched from, paused or draining.
+func (g *handlerGate) paused() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	select {
+	case <-g.draining:
+		return true
+	default:
+		return g.resumed != nil
+	}
+}
+
+// wait blocks while paused, it returns sarama.ErrClosedConsumerGroup once draining.
+func (g *handlerGate) wait(ctx context.Context) error {
+	g.lock.Lock()
+	resumed := g.resumed
+	g.lock.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-g.draining:
+		return sarama.ErrClosedConsumerGroup
+	case <-resumed:
+		return nil
+	}
+}
+
+// begin registers a handler about to run, it returns false once draining.
+func (g *handlerGate) begin() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	select {
+	case <-g.draining:
+		return false
+	default:
+		g.inflight.Add(1)
+		return true
+	}
+}
+
+func (g *handlerGate) end() {
+	g.inflight.Done()
+}
+
+// drain stops new handlers and waits for those in flight.
+func (g *handlerGate) drain(ctx context.Context) error {
+	g.lock.Lock()
+	select {
+	case <-g.draining:
+	default:
+		close(g.draining)
+	}
+	g.lock.Unlock()
+	done := make(chan struct{})
+	go func() {
+		g.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-done:
+		return nil
+	}
+}
+
+// Pause stops fetching from every claimed partition, and holds the Subscribe calls until Resume.
+// A Subscribe call already waiting for a message may still receive one that was fetched before.
+// The partitions claimed after a rebalance are paused by ConsumeClaim until Resume.
+func (x *mqConsumerGroup) Pause() {
+	x.gate.pause()
+	x.consumer.PauseAll()
+}
+
+// Resume resumes fetching and delivering messages.
+func (x *mqConsumerGroup) Resume() {
+	x.consumer.ResumeAll()
+	x.gate.resume()
+}
+
+// Drain stops fetching, waits for the handlers in flight and commits the offsets they marked,
+// then closes the consumer group. The messages fetched but not handled yet are left uncommitted
+// and go to the next member. Subscribe returns sarama.ErrClosedConsumerGroup from the start of Drain.
+func (x *mqConsumerGroup) Drain(ctx context.Context) error {
+	x.consumer.PauseAll()
+	var errList []error
+	if err := x.gate.drain(ctx); err != nil {
+		errList = append(errList, errs.WrapMsg(err, "wait for handlers failed", "groupID", x.groupID))
+	}
+	x.sessionLock.Lock()
+	if x.session != nil {
+		x.session.Commit()
+	}
+	x.sessionLock.Unlock()
+	if err := x.Close(); err != nil {
+		errList = append(errList, err)
+	}
+	return errors.Join(errList...)
+}
@@ -14,8 +14,308 @@
 
 package kafka
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/smartim/protocol/constant"
+	"github.com/smartim/tools/mcontext"
+	"github.com/smartim/tools/mq"
+)
 
 func TestProducer(t *testing.T) {
 
 }
+
+func TestHeaderRoundTrip(t *testing.T) {
+	var sent *sarama.ProducerMessage
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+	p := &mqProducer{topic: "topic", producer: producer}
+	ctx := mcontext.WithMustInfoCtx([]string{"op1", "u1", "web", "c1"})
+	if err := p.SendMessageWithHeaders(ctx, "key", []byte("value"), map[string]string{"trace": "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SendMessageWithHeaders(ctx, "key", []byte("value"), map[string]string{constant.OperationID: "op2"}); err == nil {
+		t.Fatal("expected reserved header key rejected")
+	}
+	if err := producer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The consumer finds the context values by key, whatever their position.
+	headers := make([]*sarama.RecordHeader, len(sent.Headers))
+	for i := range sent.Headers {
+		headers[len(headers)-1-i] = &sent.Headers[i]
+	}
+	msg := kafkaMessage{
+		ctx: GetContextWithMQHeader(headers),
+		msg: &consumerMessage{Msg: &sarama.ConsumerMessage{Headers: headers}},
+	}
+	ctx = msg.Context()
+	if mcontext.GetOperationID(ctx) != "op1" || mcontext.GetOpUserID(ctx) != "u1" ||
+		mcontext.GetOpUserPlatform(ctx) != "web" || mcontext.GetConnID(ctx) != "c1" {
+		t.Fatal("context values not restored from the headers")
+	}
+	if h := msg.Headers(); len(h) != 1 || h["trace"] != "t1" {
+		t.Fatalf("unexpected headers %v", h)
+	}
+	// The user header comes first once reversed, the context has no operationID.
+	if _, _, _, _, err := mcontext.GetCtxInfos(GetContextWithMQHeader(headers[:1])); err == nil {
+		t.Fatal("expected missing operationID")
+	}
+}
+
+func TestIdempotentConfig(t *testing.T) {
+	conf, err := BuildProducerConfig(Config{ProducerAck: "wait_for_local", Idempotent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	setIdempotent(conf)
+	conf.Producer.Transaction.ID = "txn"
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Producer.RequiredAcks != sarama.WaitForAll || conf.Net.MaxOpenRequests != 1 {
+		t.Fatalf("unexpected idempotent config %+v", conf.Producer)
+	}
+}
+
+type recordMetrics struct {
+	lag        map[int32]int64
+	messages   int
+	handled    int
+	rebalances []string
+}
+
+func (m *recordMetrics) PartitionLag(groupID string, topic string, partition int32, lag int64) {
+	m.lag[partition] = lag
+}
+
+func (m *recordMetrics) MessageConsumed(groupID string, topic string, partition int32) {
+	m.messages++
+}
+
+func (m *recordMetrics) HandlerDone(groupID string, topic string, messages int, duration time.Duration, err error) {
+	m.handled += messages
+}
+
+func (m *recordMetrics) Rebalance(groupID string, event RebalanceEvent) {
+	m.rebalances = append(m.rebalances, event.Type)
+}
+
+func TestConsumerStats(t *testing.T) {
+	metrics := &recordMetrics{lag: make(map[int32]int64)}
+	stats := newConsumerStats("group")
+	stats.metrics = metrics
+	claims := map[string][]int32{"topic": {0, 1}}
+	stats.rebalance(RebalanceEvent{Type: RebalanceAssigned, Claims: claims})
+	stats.claimed("topic", 0, 100)
+	stats.claimed("topic", 1, sarama.OffsetNewest)
+
+	stats.received("topic", 0, 100, 150)
+	stats.received("topic", 1, 7, 10)
+	for _, p := range []int32{0, 1} {
+		stats.delivered("topic", p)
+	}
+	stats.handled("topic", 1, time.Millisecond*10, nil)
+	stats.handled("topic", 1, time.Millisecond*30, errors.New("failed"))
+	stats.marked("topic", 0, 100)
+
+	snapshot := stats.snapshot()
+	if metrics.lag[0] != 49 || metrics.lag[1] != 3 {
+		t.Fatalf("unexpected lag %v", metrics.lag)
+	}
+	if len(snapshot.Partitions) != 2 || snapshot.Partitions[0].Committed != 101 || snapshot.Partitions[1].Lag != 3 {
+		t.Fatalf("unexpected partitions %+v", snapshot.Partitions)
+	}
+	if snapshot.Messages != 2 || snapshot.HandlerCalls != 2 || snapshot.HandlerErrors != 1 ||
+		snapshot.HandlerAvgLatency != time.Millisecond*20 || snapshot.HandlerMaxLatency != time.Millisecond*30 {
+		t.Fatalf("unexpected stats %+v", snapshot)
+	}
+	if metrics.messages != 2 || metrics.handled != 2 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+
+	stats.rebalance(RebalanceEvent{Type: RebalanceRevoked, Claims: claims})
+	if snapshot := stats.snapshot(); len(snapshot.Partitions) != 0 || snapshot.Rebalances != 2 {
+		t.Fatalf("unexpected stats after revoke %+v", snapshot)
+	}
+
+	var rate rateCounter
+	now := time.Unix(1000, 0)
+	for i := 0; i < 50; i++ {
+		rate.add(now.Add(-time.Second * time.Duration(i%5+1)))
+	}
+	rate.add(now)
+	if r := rate.rate(now); r != 5 {
+		t.Fatalf("rate %v, want 5", r)
+	}
+}
+
+type fakeConsumerGroup struct {
+	sarama.ConsumerGroup
+	paused     atomic.Bool
+	closed     atomic.Bool
+	partitions atomic.Pointer[map[string][]int32]
+}
+
+func (g *fakeConsumerGroup) Pause(partitions map[string][]int32) { g.partitions.Store(&partitions) }
+
+func (g *fakeConsumerGroup) PauseAll()    { g.paused.Store(true) }
+func (g *fakeConsumerGroup) ResumeAll()   { g.paused.Store(false) }
+func (g *fakeConsumerGroup) Close() error { g.closed.Store(true); return nil }
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	marked  atomic.Int64
+	commits atomic.Int32
+}
+
+func (s *fakeSession) Claims() map[string][]int32 { return map[string][]int32{"topic": {0}} }
+func (s *fakeSession) MemberID() string           { return "member" }
+func (s *fakeSession) GenerationID() int32        { return 1 }
+func (s *fakeSession) Commit()                    { s.commits.Add(1) }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked.Store(msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages      chan *sarama.ConsumerMessage
+	highWaterMark atomic.Int64
+}
+
+func (c *fakeClaim) Topic() string                            { return "topic" }
+func (c *fakeClaim) Partition() int32                         { return 3 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return c.highWaterMark.Load() }
+
+func TestPauseNewClaim(t *testing.T) {
+	group := &fakeConsumerGroup{}
+	x := &mqConsumerGroup{
+		groupID:  "group",
+		consumer: group,
+		msg:      make(chan *consumerMessage, 4),
+		stats:    newConsumerStats("group"),
+		gate:     newHandlerGate(),
+	}
+	x.ctx, x.cancel = context.WithCancel(context.Background())
+	defer x.cancel()
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage)}
+	close(claim.messages)
+	if err := x.ConsumeClaim(&fakeSession{}, claim); err != nil || group.partitions.Load() != nil {
+		t.Fatalf("claim paused while not paused: %v", err)
+	}
+	// A partition claimed after Pause, by a rebalance, is paused as well.
+	x.Pause()
+	if err := x.ConsumeClaim(&fakeSession{}, claim); err != nil {
+		t.Fatal(err)
+	}
+	if partitions := group.partitions.Load(); partitions == nil || len((*partitions)["topic"]) != 1 || (*partitions)["topic"][0] != 3 {
+		t.Fatalf("claim not paused: %v", partitions)
+	}
+}
+
+func TestIdleClaimLag(t *testing.T) {
+	x := &mqConsumerGroup{
+		groupID:     "group",
+		consumer:    &fakeConsumerGroup{},
+		msg:         make(chan *consumerMessage, 4),
+		stats:       newConsumerStats("group"),
+		gate:        newHandlerGate(),
+		hwmInterval: time.Millisecond * 10,
+	}
+	x.ctx, x.cancel = context.WithCancel(context.Background())
+	x.stats.rebalance(RebalanceEvent{Type: RebalanceAssigned, Claims: map[string][]int32{"topic": {3}}})
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage)}
+	claim.highWaterMark.Store(5)
+	done := make(chan error, 1)
+	go func() { done <- x.ConsumeClaim(&fakeSession{}, claim) }()
+	// No message is read, the lag follows the high-water mark anyway.
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		if partitions := x.stats.snapshot().Partitions; len(partitions) == 1 && partitions[0].Lag == 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lag not refreshed %+v", x.stats.snapshot().Partitions)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	x.cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	group := &fakeConsumerGroup{}
+	x := &mqConsumerGroup{
+		groupID:  "group",
+		consumer: group,
+		msg:      make(chan *consumerMessage, 4),
+		stats:    newConsumerStats("group"),
+		gate:     newHandlerGate(),
+	}
+	x.ctx, x.cancel = context.WithCancel(context.Background())
+	session := &fakeSession{}
+	if err := x.Setup(session); err != nil {
+		t.Fatal(err)
+	}
+	for offset := int64(1); offset <= 2; offset++ {
+		x.msg <- &consumerMessage{Msg: &sarama.ConsumerMessage{Topic: "topic", Offset: offset}, Session: session, stats: x.stats}
+	}
+
+	x.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := x.Subscribe(ctx, func(msg mq.Message) error { return nil }); !errors.Is(err, context.DeadlineExceeded) || !group.paused.Load() {
+		t.Fatalf("paused consumer returned %v", err)
+	}
+	x.Resume()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	handled := make(chan error, 1)
+	go func() {
+		handled <- x.Subscribe(context.Background(), func(msg mq.Message) error {
+			close(started)
+			<-release
+			msg.Mark()
+			return nil
+		})
+	}()
+	<-started
+	drained := make(chan error, 1)
+	go func() { drained <- x.Drain(context.Background()) }()
+	select {
+	case err := <-drained:
+		t.Fatalf("drain returned before the handler: %v", err)
+	case <-time.After(time.Millisecond * 20):
+	}
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-handled; err != nil {
+		t.Fatal(err)
+	}
+	if session.marked.Load() != 1 || session.commits.Load() != 1 || !group.closed.Load() {
+		t.Fatalf("marked %d, commits %d, closed %v", session.marked.Load(), session.commits.Load(), group.closed.Load())
+	}
+	if err := x.Subscribe(context.Background(), func(msg mq.Message) error { return nil }); !errors.Is(err, sarama.ErrClosedConsumerGroup) {
+		t.Fatalf("unexpected error after drain %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/smartim/tools/mq"
+)
+
+// Rebalance event types.
+const (
+	// RebalanceAssigned is reported when a new generation starts with the partitions claimed by the member.
+	RebalanceAssigned = "assigned"
+	// RebalanceRevoked is reported when a generation ends and its partitions are released.
+	RebalanceRevoked = "revoked"
+)
+
+// rateWindow is the number of full seconds Stats.MessagesPerSecond is averaged over.
+const rateWindow = 10
+
+// Metrics receives the measurements of a consumer group, see the prommetrics package for
+// a Prometheus implementation. The methods are called from the consuming goroutines and
+// must not block.
+type Metrics interface {
+	// PartitionLag reports the high-water mark of a claimed partition minus its committed offset.
+	PartitionLag(groupID string, topic string, partition int32, lag int64)
+	// MessageConsumed is called for every message delivered to a handler.
+	MessageConsumed(groupID string, topic string, partition int32)
+	// HandlerDone reports a handler call of Subscribe, or of SubscribeBatch with several
+	// messages, topic being the one of the first message.
+	HandlerDone(groupID string, topic string, messages int, duration time.Duration, err error)
+	// Rebalance reports the partitions assigned to or revoked from this member.
+	Rebalance(groupID string, event RebalanceEvent)
+}
+
+// RebalanceEvent describes a change of the partitions claimed by a consumer group member.
+type RebalanceEvent struct {
+	Type         string
+	MemberID     string
+	GenerationID int32
+	Claims       map[string][]int32
+}
+
+// StatsConsumer is implemented by the consumer returned by NewMConsumerGroupV2.
+type StatsConsumer interface {
+	mq.Consumer
+	Stats() Stats
+}
+
+// Stats is a snapshot of the activity of a consumer group member.
+type Stats struct {
+	GroupID string
+	// Messages is the number of messages delivered to handlers.
+	Messages int64
+	// MessagesPerSecond is the delivery rate over the last 10 seconds.
+	MessagesPerSecond float64
+	HandlerCalls      int64
+	HandlerErrors     int64
+	// HandlerAvgLatency is the average duration of the handler calls.
+	HandlerAvgLatency time.Duration
+	HandlerMaxLatency time.Duration
+	Rebalances        int64
+	// Partitions are the partitions currently claimed, by topic and partition.
+	Partitions []PartitionStats
+}
+
+// PartitionStats is the position of the consumer group in a claimed partition.
+// Committed is the offset following the last marked message, which is committed by the
+// next Commit or auto commit, -1 until it is known.
+type PartitionStats struct {
+	Topic         string
+	Partition     int32
+	HighWaterMark int64
+	Committed     int64
+	Lag           int64
+}
+
+// ConsumerOption configures a consumer group created by NewMConsumerGroupV2.
+type ConsumerOption func(*mqConsumerGroup)
+
+// WithMetrics reports the activity of the consumer group to metrics.
+func WithMetrics(metrics Metrics) ConsumerOption {
+	return func(x *mqConsumerGroup) {
+		x.stats.metrics = metrics
+	}
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// consumerStats tracks the Stats of a consumer group and forwards them to its Metrics.
+type consumerStats struct {
+	groupID string
+	metrics Metrics
+
+	lock          sync.Mutex
+	partitions    map[topicPartition]*PartitionStats
+	messages      int64
+	rate          rateCounter
+	handlerCalls  int64
+	handlerErrors int64
+	handlerTime   time.Duration
+	handlerMax    time.Duration
+	rebalances    int64
+}
+
+func newConsumerStats(groupID string) *consumerStats {
+	return &consumerStats{
+		groupID:    groupID,
+		partitions: make(map[topicPartition]*PartitionStats),
+	}
+}
+
+// rebalance records the partitions assigned or revoked by a rebalance.
+func (s *consumerStats) rebalance(event RebalanceEvent) {
+	s.lock.Lock()
+	s.rebalances++
+	for topic, partitions := range event.Claims {
+		for _, partition := range partitions {
+			key := topicPartition{topic: topic, partition: partition}
+			if event.Type == RebalanceRevoked {
+				delete(s.partitions, key)
+			} else if _, ok := s.partitions[key]; !ok {
+				s.partitions[key] = &PartitionStats{Topic: topic, Partition: partition, HighWaterMark: -1, Committed: -1}
+			}
+		}
+	}
+	s.lock.Unlock()
+	if s.metrics != nil {
+		s.metrics.Rebalance(s.groupID, event)
+	}
+}
+
+// claimed records the offset a claim starts at, which is the committed offset of the group.
+func (s *consumerStats) claimed(topic string, partition int32, offset int64) {
+	if offset < 0 {
+		// sarama.OffsetNewest or sarama.OffsetOldest, resolved by the first message.
+		return
+	}
+	s.update(topic, partition, func(p *PartitionStats) { p.Committed = offset })
+}
+
+// received records a message read from a claim, with the high-water mark of its partition.
+func (s *consumerStats) received(topic string, partition int32, offset int64, highWaterMark int64) {
+	s.update(topic, partition, func(p *PartitionStats) {
+		p.HighWaterMark = highWaterMark
+		if p.Committed < 0 {
+			p.Committed = offset
+		}
+	})
+}
+
+// highWaterMark records the high-water mark of a claimed partition.
+func (s *consumerStats) highWaterMark(topic string, partition int32, highWaterMark int64) {
+	s.update(topic, partition, func(p *PartitionStats) {
+		p.HighWaterMark = max(p.HighWaterMark, highWaterMark)
+	})
+}
+
+// marked records a message marked as consumed.
+func (s *consumerStats) marked(topic string, partition int32, offset int64) {
+	s.update(topic, partition, func(p *PartitionStats) {
+		p.Committed = max(p.Committed, offset+1)
+	})
+}
+
+func (s *consumerStats) update(topic string, partition int32, fn func(p *PartitionStats)) {
+	s.lock.Lock()
+	p, ok := s.partitions[topicPartition{topic: topic, partition: partition}]
+	if !ok {
+		s.lock.Unlock()
+		return
+	}
+	fn(p)
+	if p.HighWaterMark < 0 || p.Committed < 0 {
+		s.lock.Unlock()
+		return
+	}
+	p.Lag = max(p.HighWaterMark-p.Committed, 0)
+	lag := p.Lag
+	s.lock.Unlock()
+	if s.metrics != nil {
+		s.metrics.PartitionLag(s.groupID, topic, partition, lag)
+	}
+}
+
+// delivered records a message handed to a handler.
+func (s *consumerStats) delivered(topic string, partition int32) {
+	s.lock.Lock()
+	s.messages++
+	s.rate.add(time.Now())
+	s.lock.Unlock()
+	if s.metrics != nil {
+		s.metrics.MessageConsumed(s.groupID, topic, partition)
+	}
+}
+
+// handled records a handler call.
+func (s *consumerStats) handled(topic string, messages int, duration time.Duration, err error) {
+	s.lock.Lock()
+	s.handlerCalls++
+	if err != nil {
+		s.handlerErrors++
+	}
+	s.handlerTime += duration
+	s.handlerMax = max(s.handlerMax, duration)
+	s.lock.Unlock()
+	if s.metrics != nil {
+		s.metrics.HandlerDone(s.groupID, topic, messages, duration, err)
+	}
+}
+
+func (s *consumerStats) snapshot() Stats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stats := Stats{
+		GroupID:           s.groupID,
+		Messages:          s.messages,
+		MessagesPerSecond: s.rate.rate(time.Now()),
+		HandlerCalls:      s.handlerCalls,
+		HandlerErrors:     s.handlerErrors,
+		HandlerMaxLatency: s.handlerMax,
+		Rebalances:        s.rebalances,
+		Partitions:        make([]PartitionStats, 0, len(s.partitions)),
+	}
+	if s.handlerCalls > 0 {
+		stats.HandlerAvgLatency = s.handlerTime / time.Duration(s.handlerCalls)
+	}
+	for _, p := range s.partitions {
+		stats.Partitions = append(stats.Partitions, *p)
+	}
+	slices.SortFunc(stats.Partitions, func(a, b PartitionStats) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+	return stats
+}
+
+// rateCounter counts events in one-second buckets.
+type rateCounter struct {
+	seconds [rateWindow + 1]int64
+	counts  [rateWindow + 1]int64
+}
+
+func (r *rateCounter) add(now time.Time) {
+	sec := now.Unix()
+	i := sec % int64(len(r.seconds))
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+// rate returns the average per second over the last full seconds, the current one excluded.
+func (r *rateCounter) rate(now time.Time) float64 {
+	sec := now.Unix()
+	var total int64
+	for i, s := range r.seconds {
+		if s < sec && s >= sec-rateWindow {
+			total += r.counts[i]
+		}
+	}
+	return float64(total) / rateWindow
+}
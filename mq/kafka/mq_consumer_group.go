@@ -21,14 +21,22 @@ import (
 	"math/rand"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/log"
 	"github.com/smartim/tools/mcontext"
 	"github.com/smartim/tools/mq"
 )
 
-func NewMConsumerGroupV2(ctx context.Context, conf *Config, groupID string, topics []string, autoCommitEnable bool) (mq.Consumer, error) {
+var (
+	_ mq.BatchConsumer = (*mqConsumerGroup)(nil)
+	_ StatsConsumer    = (*mqConsumerGroup)(nil)
+	_ mq.DrainConsumer = (*mqConsumerGroup)(nil)
+)
+
+func NewMConsumerGroupV2(ctx context.Context, conf *Config, groupID string, topics []string, autoCommitEnable bool, opts ...ConsumerOption) (mq.Consumer, error) {
 	config, err := BuildConsumerGroupConfig(conf, sarama.OffsetNewest, autoCommitEnable)
 	if err != nil {
 		return nil, err
@@ -42,15 +50,25 @@ func NewMConsumerGroupV2(ctx context.Context, conf *Config, groupID string, topi
 		groupID:  groupID,
 		consumer: group,
 		msg:      make(chan *consumerMessage, 64),
+		stats:    newConsumerStats(groupID),
+		gate:     newHandlerGate(),
+	}
+	for _, opt := range opts {
+		opt(mcg)
 	}
 	mcg.ctx, mcg.cancel = context.WithCancel(ctx)
 	go mcg.loopConsume()
 	return mcg, nil
 }
 
+// highWaterMarkInterval is how often the high-water mark of a claimed partition is refreshed,
+// so that the lag keeps growing while no message is read, e.g. when the handlers are stuck.
+const highWaterMarkInterval = time.Second * 10
+
 type consumerMessage struct {
 	Msg     *sarama.ConsumerMessage
 	Session sarama.ConsumerGroupSession
+	stats   *consumerStats
 }
 
 type mqConsumerGroup struct {
@@ -61,14 +79,40 @@ type mqConsumerGroup struct {
 	cancel   context.CancelFunc
 	msg      chan *consumerMessage
 	once     sync.Once
+	stats    *consumerStats
+	gate     *handlerGate
+
+	hwmInterval time.Duration // interval of refreshHighWaterMark, highWaterMarkInterval if zero
+
+	sessionLock sync.Mutex
+	session     sarama.ConsumerGroupSession // session of the current generation, committed by Drain
 }
 
-func (*mqConsumerGroup) Setup(sarama.ConsumerGroupSession) error { return nil }
+func (x *mqConsumerGroup) Setup(session sarama.ConsumerGroupSession) error {
+	x.sessionLock.Lock()
+	x.session = session
+	x.sessionLock.Unlock()
+	x.stats.rebalance(newRebalanceEvent(RebalanceAssigned, session))
+	return nil
+}
 
-func (*mqConsumerGroup) Cleanup(sarama.ConsumerGroupSession) error {
+func (x *mqConsumerGroup) Cleanup(session sarama.ConsumerGroupSession) error {
+	x.sessionLock.Lock()
+	x.session = nil
+	x.sessionLock.Unlock()
+	x.stats.rebalance(newRebalanceEvent(RebalanceRevoked, session))
 	return nil
 }
 
+func newRebalanceEvent(typ string, session sarama.ConsumerGroupSession) RebalanceEvent {
+	return RebalanceEvent{
+		Type:         typ,
+		MemberID:     session.MemberID(),
+		GenerationID: session.GenerationID(),
+		Claims:       session.Claims(),
+	}
+}
+
 func (x *mqConsumerGroup) closeMsgChan() {
 	x.once.Do(func() {
 		x.cancel()
@@ -97,6 +141,15 @@ func (x *mqConsumerGroup) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 		_ = recover()
 	}()
 
+	x.stats.claimed(claim.Topic(), claim.Partition(), claim.InitialOffset())
+	// PauseAll only covers the partitions consumed at the time, and the partition consumers of a
+	// new session are only created after Setup, so a claim made while paused is paused here.
+	if x.gate.paused() {
+		x.consumer.Pause(map[string][]int32{claim.Topic(): {claim.Partition()}})
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go x.refreshHighWaterMark(claim, stop)
 	msg := claim.Messages()
 	for {
 		select {
@@ -106,30 +159,117 @@ func (x *mqConsumerGroup) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 			if !ok {
 				return nil
 			}
+			x.stats.received(val.Topic, val.Partition, val.Offset, claim.HighWaterMarkOffset())
 
 			select {
 			case <-x.ctx.Done():
 				return context.Canceled
-			case x.msg <- &consumerMessage{Msg: val, Session: session}:
+			case x.msg <- &consumerMessage{Msg: val, Session: session, stats: x.stats}:
 			}
 		}
 	}
 }
 
+// refreshHighWaterMark records the high-water mark of claim until stop is closed, messages
+// only update it when they are read.
+func (x *mqConsumerGroup) refreshHighWaterMark(claim sarama.ConsumerGroupClaim, stop <-chan struct{}) {
+	interval := x.hwmInterval
+	if interval <= 0 {
+		interval = highWaterMarkInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			x.stats.highWaterMark(claim.Topic(), claim.Partition(), claim.HighWaterMarkOffset())
+		}
+	}
+}
+
 func (x *mqConsumerGroup) Subscribe(ctx context.Context, fn mq.Handler) error {
+	msg, err := x.receive(ctx)
+	if err != nil {
+		return err
+	}
+	if !x.gate.begin() {
+		return sarama.ErrClosedConsumerGroup
+	}
+	defer x.gate.end()
+	start := time.Now()
+	err = fn(x.newMessage(msg))
+	x.stats.handled(msg.Msg.Topic, 1, time.Since(start), err)
+	return err
+}
+
+// receive waits for the next message, unless the consumer group is paused or draining.
+func (x *mqConsumerGroup) receive(ctx context.Context) (*consumerMessage, error) {
+	if err := x.gate.wait(ctx); err != nil {
+		return nil, err
+	}
 	select {
 	case <-ctx.Done():
-		return context.Cause(ctx)
+		return nil, context.Cause(ctx)
+	case <-x.gate.draining:
+		return nil, sarama.ErrClosedConsumerGroup
 	case msg, ok := <-x.msg:
 		if !ok {
-			return sarama.ErrClosedConsumerGroup
+			return nil, sarama.ErrClosedConsumerGroup
 		}
-		ctx := GetContextWithMQHeader(msg.Msg.Headers)
-		if err := fn(kafkaMessage{ctx: ctx, msg: msg}); err != nil {
-			return err
+		return msg, nil
+	}
+}
+
+func (x *mqConsumerGroup) SubscribeBatch(ctx context.Context, size int, maxWait time.Duration, fn mq.BatchHandler) error {
+	if size <= 0 {
+		return errs.ErrArgs.WrapMsg("invalid batch size", "size", size)
+	}
+	msg, err := x.receive(ctx)
+	if err != nil {
+		return err
+	}
+	if !x.gate.begin() {
+		return sarama.ErrClosedConsumerGroup
+	}
+	defer x.gate.end()
+	batch := append(make(mq.Batch, 0, size), x.newMessage(msg))
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+collect:
+	for len(batch) < size {
+		select {
+		case <-ctx.Done():
+			// Handle what was collected rather than dropping it.
+			break collect
+		case <-timer.C:
+			break collect
+		case <-x.gate.draining:
+			break collect
+		case msg, ok := <-x.msg:
+			if !ok {
+				break collect
+			}
+			batch = append(batch, x.newMessage(msg))
 		}
-		return nil
 	}
+	start := time.Now()
+	err = fn(batch)
+	x.stats.handled(batch[0].(kafkaMessage).msg.Msg.Topic, len(batch), time.Since(start), err)
+	return err
+}
+
+func (x *mqConsumerGroup) newMessage(msg *consumerMessage) kafkaMessage {
+	x.stats.delivered(msg.Msg.Topic, msg.Msg.Partition)
+	return kafkaMessage{ctx: GetContextWithMQHeader(msg.Msg.Headers), msg: msg}
+}
+
+// Stats returns a snapshot of the activity of this member of the group.
+func (x *mqConsumerGroup) Stats() Stats {
+	return x.stats.snapshot()
 }
 
 func (x *mqConsumerGroup) Close() error {
@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"github.com/IBM/sarama"
+	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/mq"
 )
 
@@ -42,10 +43,22 @@ type mqProducer struct {
 }
 
 func (x *mqProducer) SendMessage(ctx context.Context, key string, value []byte) error {
+	return x.SendMessageWithHeaders(ctx, key, value, nil)
+}
+
+// SendMessageWithHeaders sends the message with headers added after the context headers.
+// The keys of the context headers, such as constant.OperationID, are reserved and rejected.
+func (x *mqProducer) SendMessageWithHeaders(ctx context.Context, key string, value []byte, header map[string]string) error {
 	headers, err := GetMQHeaderWithContext(ctx)
 	if err != nil {
 		return err
 	}
+	for k, v := range header {
+		if isCtxHeader(k) {
+			return errs.ErrArgs.WrapMsg("header key reserved for the context", "key", k)
+		}
+		headers = append(headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
 	kMsg := &sarama.ProducerMessage{
 		Topic:   x.topic,
 		Key:     sarama.StringEncoder(key),
@@ -0,0 +1,116 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/mq"
+)
+
+// NewTransactionalProducer returns a producer whose messages are only visible to read_committed
+// consumers once the transaction they belong to is committed. transactionalID must be unique
+// per producer instance and stable across restarts, so that a restarted instance fences off
+// the transactions left open by its previous incarnation.
+//
+// Every SendMessage must happen between Begin and Commit or Abort. In a consume-transform-produce
+// loop, the consumer group is created with autoCommitEnable false, and the consumed messages are
+// committed with SendOffsets as part of the transaction instead of Message.Commit.
+func NewTransactionalProducer(config *Config, addr []string, topic string, transactionalID string) (*TxnProducer, error) {
+	if transactionalID == "" {
+		return nil, errs.ErrArgs.WrapMsg("transactionalID is empty")
+	}
+	conf, err := BuildProducerConfig(*config)
+	if err != nil {
+		return nil, err
+	}
+	setIdempotent(conf)
+	conf.Producer.Transaction.ID = transactionalID
+	producer, err := NewProducer(conf, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &TxnProducer{
+		mqProducer: mqProducer{
+			topic:    topic,
+			producer: producer,
+		},
+	}, nil
+}
+
+// TxnProducer is an mq.Producer publishing within Kafka transactions.
+type TxnProducer struct {
+	mqProducer
+}
+
+// Begin starts a transaction.
+func (x *TxnProducer) Begin() error {
+	if err := x.producer.BeginTxn(); err != nil {
+		return errs.WrapMsg(err, "BeginTxn failed", "topic", x.topic)
+	}
+	return nil
+}
+
+// Commit makes the messages sent and the offsets added since Begin visible atomically.
+func (x *TxnProducer) Commit() error {
+	if err := x.producer.CommitTxn(); err != nil {
+		return errs.WrapMsg(err, "CommitTxn failed", "topic", x.topic)
+	}
+	return nil
+}
+
+// Abort discards the messages sent and the offsets added since Begin.
+func (x *TxnProducer) Abort() error {
+	if err := x.producer.AbortTxn(); err != nil {
+		return errs.WrapMsg(err, "AbortTxn failed", "topic", x.topic)
+	}
+	return nil
+}
+
+// SendOffsets adds the offsets of consumed messages to the transaction, so that they are
+// committed for groupID if and only if the transaction is. The messages must come from a
+// consumer group created by this package.
+func (x *TxnProducer) SendOffsets(groupID string, msgs ...mq.Message) error {
+	for _, msg := range msgs {
+		kMsg, ok := msg.(kafkaMessage)
+		if !ok {
+			return errs.ErrArgs.WrapMsg("not a kafka message", "key", msg.Key())
+		}
+		if err := x.producer.AddMessageToTxn(kMsg.msg.Msg, groupID, nil); err != nil {
+			return errs.WrapMsg(err, "AddMessageToTxn failed", "groupID", groupID, "topic", kMsg.msg.Msg.Topic,
+				"partition", kMsg.msg.Msg.Partition, "offset", kMsg.msg.Msg.Offset)
+		}
+	}
+	return nil
+}
+
+// InTxn runs fn in a transaction, which is committed if fn returns nil and aborted otherwise.
+func (x *TxnProducer) InTxn(fn func() error) error {
+	if err := x.Begin(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if abortErr := x.Abort(); abortErr != nil {
+			return errs.WrapMsg(abortErr, "abort after failure", "err", err.Error())
+		}
+		return err
+	}
+	return x.Commit()
+}
+
+// TxnStatus returns the current state of the transaction.
+func (x *TxnProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return x.producer.TxnStatus()
+}
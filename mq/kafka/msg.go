@@ -16,6 +16,7 @@ package kafka
 
 import (
 	"context"
+	"time"
 )
 
 type kafkaMessage struct {
@@ -35,8 +36,38 @@ func (m kafkaMessage) Value() []byte {
 	return m.msg.Msg.Value
 }
 
+// Headers returns the record headers, except those carrying the context values.
+func (m kafkaMessage) Headers() map[string]string {
+	var headers map[string]string
+	for _, header := range m.msg.Msg.Headers {
+		if isCtxHeader(string(header.Key)) {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[string(header.Key)] = string(header.Value)
+	}
+	return headers
+}
+
+func (m kafkaMessage) Partition() int32 {
+	return m.msg.Msg.Partition
+}
+
+func (m kafkaMessage) Offset() int64 {
+	return m.msg.Msg.Offset
+}
+
+func (m kafkaMessage) Timestamp() time.Time {
+	return m.msg.Msg.Timestamp
+}
+
 func (m kafkaMessage) Mark() {
 	m.msg.Session.MarkMessage(m.msg.Msg, "")
+	if m.msg.stats != nil {
+		m.msg.stats.marked(m.msg.Msg.Topic, m.msg.Msg.Partition, m.msg.Msg.Offset)
+	}
 }
 
 func (m kafkaMessage) Commit() {
@@ -0,0 +1,107 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prommetrics exports the kafka consumer group metrics to Prometheus.
+package prommetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/mq/kafka"
+)
+
+var _ kafka.Metrics = (*Metrics)(nil)
+
+// New registers the collectors on reg, their names prefixed with namespace if it is not empty:
+//
+//	kafka_consumer_lag{group,topic,partition}                   gauge
+//	kafka_consumer_messages_total{group,topic,partition}        counter, rate() gives messages per second
+//	kafka_consumer_handler_seconds{group,topic,result}          histogram
+//	kafka_consumer_rebalances_total{group,type}                 counter
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := &Metrics{
+		lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "kafka_consumer_lag",
+			Help:      "High-water mark minus committed offset of the claimed partitions.",
+		}, []string{"group", "topic", "partition"}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "kafka_consumer_messages_total",
+			Help:      "Messages delivered to the handlers.",
+		}, []string{"group", "topic", "partition"}),
+		handler: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "kafka_consumer_handler_seconds",
+			Help:      "Duration of the handler calls.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"group", "topic", "result"}),
+		rebalances: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "kafka_consumer_rebalances_total",
+			Help:      "Partitions assignments and revocations of this member.",
+		}, []string{"group", "type"}),
+	}
+	for _, c := range []prometheus.Collector{m.lag, m.messages, m.handler, m.rebalances} {
+		if err := reg.Register(c); err != nil {
+			return nil, errs.WrapMsg(err, "register kafka consumer metrics failed")
+		}
+	}
+	return m, nil
+}
+
+// Metrics is a kafka.Metrics backed by Prometheus collectors.
+type Metrics struct {
+	lag        *prometheus.GaugeVec
+	messages   *prometheus.CounterVec
+	handler    *prometheus.HistogramVec
+	rebalances *prometheus.CounterVec
+}
+
+func (m *Metrics) PartitionLag(groupID string, topic string, partition int32, lag int64) {
+	m.lag.WithLabelValues(groupID, topic, formatPartition(partition)).Set(float64(lag))
+}
+
+func (m *Metrics) MessageConsumed(groupID string, topic string, partition int32) {
+	m.messages.WithLabelValues(groupID, topic, formatPartition(partition)).Inc()
+}
+
+func (m *Metrics) HandlerDone(groupID string, topic string, _ int, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.handler.WithLabelValues(groupID, topic, result).Observe(duration.Seconds())
+}
+
+// Rebalance counts the event, and drops the lag of the revoked partitions, which another
+// member reports from now on.
+func (m *Metrics) Rebalance(groupID string, event kafka.RebalanceEvent) {
+	m.rebalances.WithLabelValues(groupID, event.Type).Inc()
+	if event.Type != kafka.RebalanceRevoked {
+		return
+	}
+	for topic, partitions := range event.Claims {
+		for _, partition := range partitions {
+			m.lag.DeleteLabelValues(groupID, topic, formatPartition(partition))
+		}
+	}
+}
+
+func formatPartition(partition int32) string {
+	return strconv.FormatInt(int64(partition), 10)
+}
@@ -25,6 +25,25 @@ import (
 
 var errEmptyMsg = errors.New("kafka binary msg is empty")
 
+// ctxHeaderKeys are the keys of the headers written by GetMQHeaderWithContext, in the order of
+// the values of mcontext.WithMustInfoCtx.
+var ctxHeaderKeys = []string{
+	constant.OperationID,
+	constant.OpUserID,
+	constant.OpUserPlatform,
+	constant.ConnID,
+}
+
+// isCtxHeader reports whether key is the key of a header carrying a context value.
+func isCtxHeader(key string) bool {
+	for _, ctxKey := range ctxHeaderKeys {
+		if key == ctxKey {
+			return true
+		}
+	}
+	return false
+}
+
 // GetMQHeaderWithContext extracts message queue headers from the context.
 func GetMQHeaderWithContext(ctx context.Context) ([]sarama.RecordHeader, error) {
 	operationID, opUserID, platform, connID, err := mcontext.GetCtxInfos(ctx)
@@ -40,10 +59,19 @@ func GetMQHeaderWithContext(ctx context.Context) ([]sarama.RecordHeader, error)
 }
 
 // GetContextWithMQHeader creates a context from message queue headers.
+// The context values are looked up by header key, wherever they are among the other headers.
 func GetContextWithMQHeader(header []*sarama.RecordHeader) context.Context {
-	var values []string
+	values := make([]string, len(ctxHeaderKeys))
+	var n int
 	for _, recordHeader := range header {
-		values = append(values, string(recordHeader.Value))
+		for i, key := range ctxHeaderKeys {
+			if string(recordHeader.Key) == key {
+				values[i] = string(recordHeader.Value)
+				n = max(n, i+1)
+				break
+			}
+		}
 	}
-	return mcontext.WithMustInfoCtx(values) // Attach extracted values to context
+	// Values missing from the end are left unset rather than empty.
+	return mcontext.WithMustInfoCtx(values[:n]) // Attach extracted values to context
 }
@@ -14,12 +14,23 @@
 
 package mq
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Message interface {
 	Context() context.Context
 	Key() string
 	Value() []byte
+	// Headers returns the headers sent with SendMessageWithHeaders, nil if there are none.
+	Headers() map[string]string
+	// Partition returns the partition the message was read from, 0 for unpartitioned backends.
+	Partition() int32
+	// Offset returns the position of the message in its partition, -1 if the backend has no numeric offsets.
+	Offset() int64
+	// Timestamp returns when the message was produced.
+	Timestamp() time.Time
 	Mark()
 	Commit()
 }
@@ -33,5 +44,46 @@ type Consumer interface {
 
 type Producer interface {
 	SendMessage(ctx context.Context, key string, value []byte) error
+	// SendMessageWithHeaders sends the message with headers, which are read back by Message.Headers.
+	SendMessageWithHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error
 	Close() error
 }
+
+// Batch is a group of messages delivered together by SubscribeBatch.
+type Batch []Message
+
+// Mark marks every message of the batch.
+func (b Batch) Mark() {
+	for _, msg := range b {
+		msg.Mark()
+	}
+}
+
+// Commit marks every message of the batch and commits them.
+func (b Batch) Commit() {
+	if len(b) == 0 {
+		return
+	}
+	b.Mark()
+	b[len(b)-1].Commit()
+}
+
+type BatchHandler func(batch Batch) error
+
+// BatchConsumer is implemented by consumers that can deliver messages in batches.
+type BatchConsumer interface {
+	// SubscribeBatch waits for a message, then handles it together with the following ones
+	// until size messages are collected or maxWait elapsed, whichever comes first.
+	SubscribeBatch(ctx context.Context, size int, maxWait time.Duration, fn BatchHandler) error
+}
+
+// DrainConsumer is implemented by consumers that can be paused and stopped gracefully.
+type DrainConsumer interface {
+	Consumer
+	// Pause stops fetching messages until Resume, the Subscribe calls wait meanwhile.
+	Pause()
+	Resume()
+	// Drain stops fetching, waits for the handlers in flight, commits what they marked and
+	// closes the consumer. The consumer is closed even if ctx is done first.
+	Drain(ctx context.Context) error
+}
@@ -0,0 +1,271 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/mcontext"
+	"github.com/smartim/tools/mq"
+)
+
+const (
+	// retryDelay is the pause after a failed read before trying again.
+	retryDelay = time.Second
+
+	// claimStart is the smallest entry id, where XAUTOCLAIM scans start.
+	claimStart = "0-0"
+)
+
+var errClosed = errors.New("redis stream consumer closed")
+
+// NewConsumer returns a Consumer reading stream as the named member of the consumer group,
+// which is created if it does not exist. Entries are acknowledged with XACK by Message.Mark or
+// Message.Commit. Entries left unacknowledged for longer than the claim idle time, for example
+// by a crashed member, are reclaimed with XAUTOCLAIM and delivered again, so handlers must
+// be idempotent and take less time than the claim idle time.
+// The client is shared and not closed by the consumer.
+func NewConsumer(ctx context.Context, client redis.UniversalClient, stream string, group string, name string, opts ...Option) (mq.Consumer, error) {
+	o := newOptions(opts)
+	if err := client.XGroupCreateMkStream(ctx, stream, group, o.startID).Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, errs.WrapMsg(err, "XGROUP CREATE failed", "stream", stream, "group", group)
+	}
+	x := &consumer{
+		client: client,
+		stream: stream,
+		group:  group,
+		name:   name,
+		opts:   o,
+		msg:    make(chan *message, o.batchSize),
+	}
+	x.ctx, x.cancel = context.WithCancel(ctx)
+	x.wg.Add(1)
+	go x.loopConsume()
+	return x, nil
+}
+
+type consumer struct {
+	client redis.UniversalClient
+	stream string
+	group  string
+	name   string
+	opts   *options
+	msg    chan *message
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (x *consumer) loopConsume() {
+	defer x.wg.Done()
+	defer close(x.msg)
+	ctx := mcontext.SetOperationID(x.ctx, fmt.Sprintf("redis_stream_%s_%s_%s", x.stream, x.group, x.name))
+	// Entries delivered to this member before a restart and never acknowledged.
+	for start := claimStart; ; {
+		next, err := x.readPending(ctx, start)
+		if err != nil {
+			if x.ctx.Err() != nil {
+				return
+			}
+			log.ZWarn(ctx, "read pending entries", err, "stream", x.stream, "group", x.group)
+			break
+		}
+		if next == "" {
+			break
+		}
+		start = next
+	}
+	// Claim at half the idle time so that an entry waits at most 1.5 times the idle time.
+	claimInterval := x.opts.claimIdle / 2
+	lastClaim := time.Now()
+	for {
+		if time.Since(lastClaim) >= claimInterval {
+			if err := x.autoClaim(ctx); err != nil && x.ctx.Err() == nil {
+				log.ZWarn(ctx, "auto claim entries", err, "stream", x.stream, "group", x.group)
+			}
+			lastClaim = time.Now()
+		}
+		if x.ctx.Err() != nil {
+			return
+		}
+		streams, err := x.client.XReadGroup(x.ctx, &redis.XReadGroupArgs{
+			Group:    x.group,
+			Consumer: x.name,
+			Streams:  []string{x.stream, ">"},
+			Count:    x.opts.batchSize,
+			Block:    min(x.opts.block, claimInterval),
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if x.ctx.Err() != nil {
+				return
+			}
+			log.ZWarn(ctx, "XREADGROUP failed", err, "stream", x.stream, "group", x.group)
+			select {
+			case <-x.ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		for _, stream := range streams {
+			if !x.deliver(ctx, stream.Messages) {
+				return
+			}
+		}
+	}
+}
+
+// readPending delivers the pending entries of this member after start and returns the id to
+// continue from, or "" once they are all delivered.
+func (x *consumer) readPending(ctx context.Context, start string) (string, error) {
+	streams, err := x.client.XReadGroup(x.ctx, &redis.XReadGroupArgs{
+		Group:    x.group,
+		Consumer: x.name,
+		Streams:  []string{x.stream, start},
+		Count:    x.opts.batchSize,
+		Block:    -1,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", err
+	}
+	var next string
+	for _, stream := range streams {
+		if len(stream.Messages) == 0 {
+			continue
+		}
+		if !x.deliver(ctx, stream.Messages) {
+			return "", x.ctx.Err()
+		}
+		next = stream.Messages[len(stream.Messages)-1].ID
+	}
+	return next, nil
+}
+
+// autoClaim takes over the entries of the group idle for longer than the claim idle time and delivers them.
+func (x *consumer) autoClaim(ctx context.Context) error {
+	start := claimStart
+	for {
+		msgs, next, err := x.client.XAutoClaim(x.ctx, &redis.XAutoClaimArgs{
+			Stream:   x.stream,
+			Group:    x.group,
+			Consumer: x.name,
+			MinIdle:  x.opts.claimIdle,
+			Start:    start,
+			Count:    x.opts.batchSize,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(msgs) > 0 {
+			log.ZDebug(ctx, "claimed idle entries", "stream", x.stream, "group", x.group, "count", len(msgs))
+		}
+		if !x.deliver(ctx, msgs) {
+			return x.ctx.Err()
+		}
+		if next == "" || next == claimStart {
+			return nil
+		}
+		start = next
+	}
+}
+
+// deliver hands the entries to Subscribe, it returns false once the consumer is closed.
+func (x *consumer) deliver(ctx context.Context, msgs []redis.XMessage) bool {
+	for _, msg := range msgs {
+		if msg.Values == nil {
+			// The entry was trimmed from the stream while pending, nothing can be delivered.
+			if err := x.client.XAck(ctx, x.stream, x.group, msg.ID).Err(); err != nil {
+				log.ZWarn(ctx, "XACK deleted entry", err, "stream", x.stream, "id", msg.ID)
+			}
+			continue
+		}
+		select {
+		case <-x.ctx.Done():
+			return false
+		case x.msg <- x.newMessage(msg):
+		}
+	}
+	return true
+}
+
+func (x *consumer) newMessage(msg redis.XMessage) *message {
+	field := func(name string) string {
+		str, _ := msg.Values[name].(string)
+		return str
+	}
+	values := make([]string, len(headerFields))
+	for i, name := range headerFields {
+		values[i] = field(name)
+	}
+	var headers map[string]string
+	for name, value := range msg.Values {
+		if k, ok := strings.CutPrefix(name, headerPrefix); ok {
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers[k], _ = value.(string)
+		}
+	}
+	var timestamp time.Time
+	// Entry ids are "<unix milliseconds>-<sequence>".
+	if ms, err := strconv.ParseInt(strings.SplitN(msg.ID, "-", 2)[0], 10, 64); err == nil {
+		timestamp = time.UnixMilli(ms)
+	}
+	return &message{
+		ctx:       mcontext.WithMustInfoCtx(values),
+		id:        msg.ID,
+		key:       field(fieldKey),
+		value:     []byte(field(fieldValue)),
+		headers:   headers,
+		timestamp: timestamp,
+		consumer:  x,
+	}
+}
+
+func (x *consumer) Subscribe(ctx context.Context, fn mq.Handler) error {
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case msg, ok := <-x.msg:
+		if !ok {
+			return errClosed
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+func (x *consumer) Close() error {
+	x.cancel()
+	x.wg.Wait()
+	return nil
+}
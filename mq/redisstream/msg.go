@@ -0,0 +1,83 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisstream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/smartim/tools/log"
+)
+
+type message struct {
+	ctx       context.Context
+	id        string
+	key       string
+	value     []byte
+	headers   map[string]string
+	timestamp time.Time
+	consumer  *consumer
+	once      sync.Once
+}
+
+func (m *message) Context() context.Context {
+	return m.ctx
+}
+
+func (m *message) Key() string {
+	return m.key
+}
+
+func (m *message) Value() []byte {
+	return m.value
+}
+
+func (m *message) Headers() map[string]string {
+	return m.headers
+}
+
+func (m *message) Partition() int32 {
+	return 0
+}
+
+// Offset returns -1, entries are identified by string ids.
+func (m *message) Offset() int64 {
+	return -1
+}
+
+// Timestamp returns the time of the entry id, which Redis assigns on XADD.
+func (m *message) Timestamp() time.Time {
+	return m.timestamp
+}
+
+// Mark acknowledges the entry with XACK, there is no separate commit step in Redis Streams.
+func (m *message) Mark() {
+	m.ack()
+}
+
+// Commit acknowledges the entry with XACK, it is a no-op if Mark was called.
+func (m *message) Commit() {
+	m.ack()
+}
+
+func (m *message) ack() {
+	m.once.Do(func() {
+		c := m.consumer
+		if err := c.client.XAck(m.ctx, c.stream, c.group, m.id).Err(); err != nil {
+			log.ZWarn(m.ctx, "XACK failed", err, "stream", c.stream, "group", c.group, "id", m.id)
+		}
+	})
+}
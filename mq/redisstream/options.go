@@ -0,0 +1,91 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisstream
+
+import "time"
+
+const (
+	defaultBatchSize = 64
+	defaultBlock     = time.Second * 5
+	defaultClaimIdle = time.Minute
+)
+
+type options struct {
+	maxLen    int64
+	startID   string
+	batchSize int64
+	block     time.Duration
+	claimIdle time.Duration
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		startID:   "$",
+		batchSize: defaultBatchSize,
+		block:     defaultBlock,
+		claimIdle: defaultClaimIdle,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Option configures a Producer or a Consumer.
+type Option func(*options)
+
+// WithMaxLen makes the producer trim the stream to about n entries on every XADD.
+// The stream is not trimmed by default.
+func WithMaxLen(n int64) Option {
+	return func(o *options) {
+		o.maxLen = n
+	}
+}
+
+// WithStartID sets where a new consumer group starts reading, "$" (new entries only) by default.
+// Use "0" to read the whole stream. It has no effect on an existing group.
+func WithStartID(id string) Option {
+	return func(o *options) {
+		o.startID = id
+	}
+}
+
+// WithBatchSize sets how many entries are read per XREADGROUP or XAUTOCLAIM, 64 by default.
+func WithBatchSize(n int64) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.batchSize = n
+		}
+	}
+}
+
+// WithBlock sets how long XREADGROUP waits for new entries, 5 seconds by default.
+func WithBlock(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.block = d
+		}
+	}
+}
+
+// WithClaimIdle sets how long an entry stays unacknowledged before another consumer of the
+// group reclaims it with XAUTOCLAIM, 1 minute by default.
+func WithClaimIdle(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.claimIdle = d
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisstream
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/smartim/protocol/constant"
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/mcontext"
+	"github.com/smartim/tools/mq"
+)
+
+const (
+	fieldKey   = "key"
+	fieldValue = "value"
+
+	// headerPrefix prefixes the fields holding the headers of SendMessageWithHeaders.
+	headerPrefix = "h:"
+)
+
+// headerFields are the context values carried by every entry, in the order of mcontext.WithMustInfoCtx.
+var headerFields = []string{constant.OperationID, constant.OpUserID, constant.OpUserPlatform, constant.ConnID}
+
+// NewProducer returns a Producer appending messages to stream with XADD.
+// The client is shared and not closed by the producer.
+func NewProducer(client redis.UniversalClient, stream string, opts ...Option) mq.Producer {
+	return &producer{
+		client: client,
+		stream: stream,
+		opts:   newOptions(opts),
+	}
+}
+
+type producer struct {
+	client redis.UniversalClient
+	stream string
+	opts   *options
+}
+
+func (x *producer) SendMessage(ctx context.Context, key string, value []byte) error {
+	return x.SendMessageWithHeaders(ctx, key, value, nil)
+}
+
+func (x *producer) SendMessageWithHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	operationID, opUserID, platform, connID, err := mcontext.GetCtxInfos(ctx)
+	if err != nil {
+		return err
+	}
+	values := make([]any, 0, 12+len(headers)*2)
+	values = append(values,
+		fieldKey, key,
+		fieldValue, value,
+		headerFields[0], operationID,
+		headerFields[1], opUserID,
+		headerFields[2], platform,
+		headerFields[3], connID,
+	)
+	for k, v := range headers {
+		values = append(values, headerPrefix+k, v)
+	}
+	args := &redis.XAddArgs{
+		Stream: x.stream,
+		Values: values,
+	}
+	if x.opts.maxLen > 0 {
+		args.MaxLen = x.opts.maxLen
+		args.Approx = true
+	}
+	if err := x.client.XAdd(ctx, args).Err(); err != nil {
+		return errs.WrapMsg(err, "XADD failed", "stream", x.stream, "key", key)
+	}
+	return nil
+}
+
+func (x *producer) Close() error {
+	return nil
+}
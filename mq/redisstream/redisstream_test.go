@@ -0,0 +1,97 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/smartim/tools/mcontext"
+	"github.com/smartim/tools/mq"
+)
+
+func TestRedisStream(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer client.Close()
+	ctx := mcontext.SetOperationID(context.Background(), "op1")
+
+	crashed, err := NewConsumer(ctx, client, "events", "group", "a", WithStartID("0"), WithBlock(time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := NewProducer(client, "events")
+	for i := 0; i < 3; i++ {
+		headers := map[string]string{"tenant": fmt.Sprintf("t%d", i)}
+		if err := producer.SendMessageWithHeaders(ctx, fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i)), headers); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	subCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	// The first consumer takes an entry and crashes before acknowledging it.
+	if err := crashed.Subscribe(subCtx, func(msg mq.Message) error {
+		if msg.Key() != "k0" || string(msg.Value()) != "v0" {
+			t.Fatalf("unexpected message %s=%s", msg.Key(), msg.Value())
+		}
+		if id := mcontext.GetOperationID(msg.Context()); id != "op1" {
+			t.Fatalf("unexpected operationID %q", id)
+		}
+		if tenant := msg.Headers()["tenant"]; tenant != "t0" || len(msg.Headers()) != 1 {
+			t.Fatalf("unexpected headers %v", msg.Headers())
+		}
+		if since := time.Since(msg.Timestamp()); since < 0 || since > time.Minute {
+			t.Fatalf("unexpected timestamp %s", msg.Timestamp())
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := crashed.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := NewConsumer(ctx, client, "events", "group", "b", WithBlock(time.Millisecond*50), WithClaimIdle(time.Millisecond*100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	received := make(map[string]int)
+	for len(received) < 3 {
+		if err := consumer.Subscribe(subCtx, func(msg mq.Message) error {
+			received[msg.Key()]++
+			msg.Mark()
+			msg.Commit()
+			return nil
+		}); err != nil {
+			t.Fatal(err, received)
+		}
+	}
+	if received["k0"] == 0 {
+		t.Fatalf("unacknowledged entry not reclaimed: %v", received)
+	}
+	pending, err := client.XPending(ctx, "events", "group").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("%d entries left pending", pending.Count)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry wraps an mq.Consumer so that failing handlers are retried with an exponential
+// backoff, and the messages still failing afterwards are forwarded to a dead-letter producer.
+package retry
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/mq"
+)
+
+// Headers of the messages forwarded to the dead-letter producer.
+const (
+	HeaderOriginalKey = "x-dlq-original-key"
+	HeaderError       = "x-dlq-error"
+	HeaderAttempts    = "x-dlq-attempts"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = time.Millisecond * 100
+	defaultMaxBackoff  = time.Second * 10
+)
+
+// Option configures a retrying Consumer.
+type Option func(*Consumer)
+
+// WithMaxAttempts sets how many times a message is handled before being given up, 3 by default.
+func WithMaxAttempts(n int) Option {
+	return func(c *Consumer) {
+		if n > 0 {
+			c.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled for every following one up to
+// maxBackoff. 100ms up to 10s by default.
+func WithBackoff(backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(c *Consumer) {
+		if backoff > 0 {
+			c.backoff = backoff
+		}
+		if maxBackoff > 0 {
+			c.maxBackoff = maxBackoff
+		}
+	}
+}
+
+// WithRetryable sets which errors are worth retrying, the others are dead-lettered right away.
+// Every error is retried by default.
+func WithRetryable(fn func(err error) bool) Option {
+	return func(c *Consumer) {
+		c.retryable = fn
+	}
+}
+
+// WithDeadLetter forwards the messages that failed every attempt to producer, with the headers
+// HeaderOriginalKey, HeaderError and HeaderAttempts added to their own.
+// Without a dead-letter producer, the last handler error is returned.
+func WithDeadLetter(producer mq.Producer) Option {
+	return func(c *Consumer) {
+		c.deadLetter = producer
+	}
+}
+
+// NewConsumer wraps consumer with the retry policy.
+func NewConsumer(consumer mq.Consumer, opts ...Option) *Consumer {
+	c := &Consumer{
+		consumer:    consumer,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+		maxBackoff:  defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Consumer is an mq.Consumer retrying the handler on failure.
+type Consumer struct {
+	consumer    mq.Consumer
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	retryable   func(err error) bool
+	deadLetter  mq.Producer
+}
+
+// Subscribe handles the next message of the wrapped consumer. A message forwarded to the
+// dead-letter producer is marked and nil is returned, so that consumption goes on.
+func (c *Consumer) Subscribe(ctx context.Context, fn mq.Handler) error {
+	return c.consumer.Subscribe(ctx, func(msg mq.Message) error {
+		return c.handle(ctx, msg, fn)
+	})
+}
+
+func (c *Consumer) handle(ctx context.Context, msg mq.Message, fn mq.Handler) error {
+	var (
+		err     error
+		attempt int
+	)
+	backoff := c.backoff
+	for attempt = 1; ; attempt++ {
+		if err = fn(msg); err == nil {
+			return nil
+		}
+		if attempt >= c.maxAttempts || (c.retryable != nil && !c.retryable(err)) {
+			break
+		}
+		log.ZWarn(msg.Context(), "handle message, retry later", err, "key", msg.Key(), "attempt", attempt, "backoff", backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return context.Cause(ctx)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, c.maxBackoff)
+	}
+	if c.deadLetter == nil {
+		return err
+	}
+	if dlqErr := c.sendDeadLetter(msg, err, attempt); dlqErr != nil {
+		return errs.WrapMsg(dlqErr, "forward to dead letter failed", "key", msg.Key(), "err", err.Error())
+	}
+	log.ZError(msg.Context(), "message forwarded to dead letter", err, "key", msg.Key(), "attempts", attempt)
+	msg.Mark()
+	return nil
+}
+
+// sendDeadLetter forwards msg with its own headers and the ones describing the failure.
+func (c *Consumer) sendDeadLetter(msg mq.Message, err error, attempts int) error {
+	headers := maps.Clone(msg.Headers())
+	if headers == nil {
+		headers = make(map[string]string, 3)
+	}
+	headers[HeaderOriginalKey] = msg.Key()
+	headers[HeaderError] = err.Error()
+	headers[HeaderAttempts] = strconv.Itoa(attempts)
+	return c.deadLetter.SendMessageWithHeaders(msg.Context(), msg.Key(), msg.Value(), headers)
+}
+
+// Close closes the wrapped consumer, the dead-letter producer is left open.
+func (c *Consumer) Close() error {
+	return c.consumer.Close()
+}
@@ -0,0 +1,110 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/mq"
+	"github.com/smartim/tools/mq/simmq"
+)
+
+type deadLetterMessage struct {
+	key     string
+	value   []byte
+	headers map[string]string
+}
+
+type deadLetterProducer struct {
+	msgs []deadLetterMessage
+}
+
+func (p *deadLetterProducer) SendMessage(ctx context.Context, key string, value []byte) error {
+	return p.SendMessageWithHeaders(ctx, key, value, nil)
+}
+
+func (p *deadLetterProducer) SendMessageWithHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	p.msgs = append(p.msgs, deadLetterMessage{key: key, value: value, headers: headers})
+	return nil
+}
+
+func (p *deadLetterProducer) Close() error {
+	return nil
+}
+
+func TestRetryConsumer(t *testing.T) {
+	ctx := context.Background()
+	producer, consumer := simmq.NewMemory(16)
+	dlq := &deadLetterProducer{}
+	c := NewConsumer(consumer, WithMaxAttempts(3), WithBackoff(time.Millisecond, time.Millisecond*5), WithDeadLetter(dlq),
+		WithRetryable(func(err error) bool { return err.Error() != "permanent" }))
+	defer c.Close()
+
+	cases := []struct {
+		key      string
+		failures int
+		err      string
+		calls    int
+		dead     bool
+	}{
+		{key: "flaky", failures: 2, err: "temporary", calls: 3},
+		{key: "broken", failures: 10, err: "temporary", calls: 3, dead: true},
+		{key: "invalid", failures: 10, err: "permanent", calls: 1, dead: true},
+	}
+	for _, tc := range cases {
+		if err := producer.SendMessageWithHeaders(ctx, tc.key, []byte("value"), map[string]string{"trace": tc.key}); err != nil {
+			t.Fatal(err)
+		}
+		var calls int
+		err := c.Subscribe(ctx, func(msg mq.Message) error {
+			calls++
+			if calls <= tc.failures {
+				return errors.New(tc.err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.key, err)
+		}
+		if calls != tc.calls {
+			t.Fatalf("%s: handled %d times, want %d", tc.key, calls, tc.calls)
+		}
+		if dead := len(dlq.msgs) > 0 && dlq.msgs[len(dlq.msgs)-1].key == tc.key; dead != tc.dead {
+			t.Fatalf("%s: dead lettered %v, want %v", tc.key, dead, tc.dead)
+		}
+	}
+	if len(dlq.msgs) != 2 {
+		t.Fatalf("%d dead letters, want 2", len(dlq.msgs))
+	}
+	headers := dlq.msgs[0].headers
+	if headers[HeaderOriginalKey] != "broken" || headers[HeaderError] != "temporary" || headers[HeaderAttempts] != "3" || headers["trace"] != "broken" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+	if attempts := dlq.msgs[1].headers[HeaderAttempts]; attempts != "1" {
+		t.Fatalf("permanent error retried %s times", attempts)
+	}
+
+	// Without a dead-letter producer, the last error is returned.
+	c = NewConsumer(consumer, WithMaxAttempts(2), WithBackoff(time.Millisecond, 0))
+	if err := producer.SendMessage(ctx, "lost", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Subscribe(ctx, func(msg mq.Message) error { return errors.New("failed") }); err == nil || err.Error() != "failed" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
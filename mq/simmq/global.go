@@ -23,15 +23,15 @@ import (
 const defaultMqSize = 1024 * 16
 
 var (
-	topicMq   map[string]*memory
+	topicMq   map[string]*Topic
 	topicLock sync.Mutex
 )
 
-func getTopicMemory(topic string) *memory {
+func getTopicMemory(topic string) *Topic {
 	topicLock.Lock()
 	defer topicLock.Unlock()
 	if topicMq == nil {
-		topicMq = make(map[string]*memory)
+		topicMq = make(map[string]*Topic)
 	}
 	val, ok := topicMq[topic]
 	if !ok {
@@ -50,5 +50,11 @@ func GetTopicProducer(topic string) mq.Producer {
 }
 
 func GetTopicConsumer(topic string) mq.Consumer {
-	return getTopicMemory(topic)
+	return getTopicMemory(topic).defaultConsumer()
+}
+
+// GetTopicGroupConsumer returns a consumer of groupID on topic. Every group receives every
+// message of the topic, while the consumers of a group share them.
+func GetTopicGroupConsumer(topic string, groupID string) mq.Consumer {
+	return getTopicMemory(topic).NewConsumer(groupID)
 }
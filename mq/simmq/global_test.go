@@ -20,6 +20,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+
+	"github.com/smartim/tools/mq"
 )
 
 func TestName(t *testing.T) {
@@ -33,8 +35,8 @@ func TestName(t *testing.T) {
 			t.Log("consumer end")
 			close(done)
 		}()
-		fn := func(ctx context.Context, key string, value []byte) error {
-			t.Logf("consumer key: %s, value: %s", key, value)
+		fn := func(msg mq.Message) error {
+			t.Logf("consumer key: %s, value: %s", msg.Key(), msg.Value())
 			return nil
 		}
 		c := GetTopicConsumer(topic)
@@ -17,9 +17,11 @@ package simmq
 import (
 	"context"
 	"errors"
+	"maps"
 	"sync"
-	"sync/atomic"
+	"time"
 
+	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/mq"
 )
 
@@ -27,73 +29,262 @@ var (
 	errClosed = errors.New("memory mq closed")
 )
 
+// defaultGroup is the group of the consumers created without group ID.
+const defaultGroup = ""
+
+var _ mq.BatchConsumer = (*consumer)(nil)
+
+// NewMemory returns a topic of the given size and a consumer of its default group.
 func NewMemory(size int) (mq.Producer, mq.Consumer) {
 	m := newMemory(size, nil)
-	return m, m
+	return m, m.defaultConsumer()
 }
 
-func newMemory(size int, fn func()) *memory {
-	return &memory{
-		ch:   make(chan *message, size),
-		done: make(chan struct{}),
-		fn:   fn,
+// NewTopic returns an in-memory topic behaving like a Kafka topic: every consumer group
+// receives every message, while the consumers of a group share them. The producer blocks
+// while the slowest group is size messages behind.
+func NewTopic(size int) *Topic {
+	return newMemory(size, nil)
+}
+
+func newMemory(size int, fn func()) *Topic {
+	return &Topic{
+		size:   max(size, 1),
+		groups: make(map[string]*group),
+		notify: make(chan struct{}),
+		fn:     fn,
 	}
 }
 
-type memory struct {
-	lock   sync.RWMutex
-	closed atomic.Bool
-	ch     chan *message
-	done   chan struct{}
+// Topic is an in-memory mq.Producer, its consumers are created with NewConsumer.
+type Topic struct {
+	lock   sync.Mutex
+	size   int
+	msgs   []*message // messages not consumed by every group yet, msgs[0] having offset first
+	first  int64
+	next   int64
+	groups map[string]*group
+	notify chan struct{} // closed and replaced on every change
+	closed bool
 	fn     func()
 }
 
-func (x *memory) Subscribe(ctx context.Context, fn mq.Handler) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case msg, ok := <-x.ch:
-		if !ok {
-			return errClosed
-		}
-		if err := fn(msg); err != nil {
-			return err
-		}
-		return nil
+// group is the position of a consumer group in the topic.
+type group struct {
+	next      int64
+	consumers int
+}
+
+// NewConsumer returns a consumer of groupID. A new group starts at the oldest message not
+// consumed by every other group, which is the first message for the first group.
+// Unlike Kafka, nothing expires: a group nobody consumes from eventually blocks the producer,
+// until its consumers are closed, which removes the group.
+func (x *Topic) NewConsumer(groupID string) mq.Consumer {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	g, ok := x.groups[groupID]
+	if !ok {
+		g = &group{next: x.first}
+		x.groups[groupID] = g
 	}
+	g.consumers++
+	return &consumer{topic: x, groupID: groupID, group: g}
 }
 
-func (x *memory) SendMessage(ctx context.Context, key string, value []byte) error {
-	if x.closed.Load() {
-		return errClosed
+// release removes the group of a closed consumer once it has no consumer left, so that it no
+// longer holds messages back. Without any group left, the messages are kept for the next one.
+// It is called with x.lock held.
+func (x *Topic) release(groupID string, g *group) {
+	if g.consumers--; g.consumers > 0 || x.groups[groupID] != g {
+		return
+	}
+	delete(x.groups, groupID)
+	if len(x.groups) > 0 {
+		x.trim()
 	}
+}
+
+// defaultConsumer returns a consumer of the default group, closing the topic on Close.
+func (x *Topic) defaultConsumer() mq.Consumer {
+	c := x.NewConsumer(defaultGroup).(*consumer)
+	c.closeTopic = true
+	return c
+}
+
+func (x *Topic) SendMessage(ctx context.Context, key string, value []byte) error {
+	return x.SendMessageWithHeaders(ctx, key, value, nil)
+}
+
+func (x *Topic) SendMessageWithHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error {
 	msg := &message{
-		ctx:   context.WithoutCancel(ctx),
-		key:   key,
-		value: value,
-	}
-	x.lock.RLock()
-	defer x.lock.RUnlock()
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-x.done:
+		ctx:       context.WithoutCancel(ctx),
+		key:       key,
+		value:     value,
+		headers:   maps.Clone(headers),
+		timestamp: time.Now(),
+	}
+	x.lock.Lock()
+	for len(x.msgs) >= x.size && !x.closed {
+		notify := x.notify
+		x.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		}
+		x.lock.Lock()
+	}
+	defer x.lock.Unlock()
+	if x.closed {
 		return errClosed
-	case x.ch <- msg:
-		return nil
 	}
+	msg.offset = x.next
+	x.next++
+	x.msgs = append(x.msgs, msg)
+	x.broadcast()
+	return nil
 }
 
-func (x *memory) Close() error {
-	if !x.closed.CompareAndSwap(false, true) {
+// Close stops the producer. The consumers get the messages left before errClosed.
+func (x *Topic) Close() error {
+	x.lock.Lock()
+	if x.closed {
+		x.lock.Unlock()
 		return nil
 	}
-	close(x.done)
+	x.closed = true
+	x.broadcast()
+	x.lock.Unlock()
 	if x.fn != nil {
 		x.fn()
 	}
-	x.lock.Lock()
-	defer x.lock.Unlock()
-	close(x.ch)
+	return nil
+}
+
+// poll returns the next message of g, with a nil message the channel to wait on for one.
+// It is called with x.lock held.
+func (x *Topic) poll(g *group) (*message, <-chan struct{}, error) {
+	if g.next < x.first {
+		g.next = x.first
+	}
+	if g.next == x.next {
+		if x.closed {
+			return nil, nil, errClosed
+		}
+		return nil, x.notify, nil
+	}
+	msg := x.msgs[g.next-x.first]
+	g.next++
+	x.trim()
+	return msg, nil, nil
+}
+
+// trim drops the messages consumed by every group.
+func (x *Topic) trim() {
+	minNext := x.next
+	for _, g := range x.groups {
+		minNext = min(minNext, g.next)
+	}
+	if n := int(minNext - x.first); n > 0 {
+		clear(x.msgs[:n])
+		x.msgs = x.msgs[n:]
+		x.first = minNext
+		x.broadcast()
+	}
+}
+
+// broadcast wakes up every waiting producer and consumer. It is called with x.lock held.
+func (x *Topic) broadcast() {
+	close(x.notify)
+	x.notify = make(chan struct{})
+}
+
+type consumer struct {
+	topic      *Topic
+	groupID    string
+	group      *group
+	closeTopic bool
+	closed     bool // guarded by topic.lock
+}
+
+// poll returns the next message of the group, see Topic.poll.
+func (x *consumer) poll() (*message, <-chan struct{}, error) {
+	x.topic.lock.Lock()
+	defer x.topic.lock.Unlock()
+	if x.closed {
+		return nil, nil, errClosed
+	}
+	return x.topic.poll(x.group)
+}
+
+// next waits for the next message of the group.
+func (x *consumer) next(ctx context.Context) (*message, error) {
+	for {
+		msg, notify, err := x.poll()
+		if err != nil || msg != nil {
+			return msg, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+func (x *consumer) Subscribe(ctx context.Context, fn mq.Handler) error {
+	msg, err := x.next(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(msg)
+}
+
+func (x *consumer) SubscribeBatch(ctx context.Context, size int, maxWait time.Duration, fn mq.BatchHandler) error {
+	if size <= 0 {
+		return errs.ErrArgs.WrapMsg("invalid batch size", "size", size)
+	}
+	msg, err := x.next(ctx)
+	if err != nil {
+		return err
+	}
+	batch := append(make(mq.Batch, 0, size), msg)
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+collect:
+	for len(batch) < size {
+		msg, notify, err := x.poll()
+		if err != nil {
+			break
+		}
+		if msg != nil {
+			batch = append(batch, msg)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			// Handle what was collected rather than dropping it.
+			break collect
+		case <-timer.C:
+			break collect
+		case <-notify:
+		}
+	}
+	return fn(batch)
+}
+
+// Close closes the topic for the consumers of NewMemory and GetTopicConsumer, which share it
+// with their producer. A group consumer stops consuming, and the group is removed with its
+// position when its last consumer is closed.
+func (x *consumer) Close() error {
+	if x.closeTopic {
+		return x.topic.Close()
+	}
+	x.topic.lock.Lock()
+	defer x.topic.lock.Unlock()
+	if !x.closed {
+		x.closed = true
+		x.topic.release(x.groupID, x.group)
+	}
 	return nil
 }
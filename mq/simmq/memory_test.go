@@ -0,0 +1,167 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/mq"
+)
+
+func TestSubscribeBatch(t *testing.T) {
+	ctx := context.Background()
+	producer, consumer := NewMemory(16)
+	defer producer.Close()
+	for i := 0; i < 5; i++ {
+		if err := producer.SendMessageWithHeaders(ctx, fmt.Sprintf("k%d", i), []byte("v"), map[string]string{"i": fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batchConsumer := consumer.(mq.BatchConsumer)
+	var batches [][]string
+	for _, size := range []int{3, 3} {
+		start := time.Now()
+		err := batchConsumer.SubscribeBatch(ctx, size, time.Millisecond*50, func(batch mq.Batch) error {
+			var keys []string
+			for _, msg := range batch {
+				if msg.Headers()["i"] != fmt.Sprint(msg.Offset()) {
+					t.Fatalf("unexpected headers %v at offset %d", msg.Headers(), msg.Offset())
+				}
+				keys = append(keys, msg.Key())
+			}
+			batches = append(batches, keys)
+			batch.Commit()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batches) == 1 && time.Since(start) >= time.Millisecond*50 {
+			t.Fatal("full batch waited for maxWait")
+		}
+	}
+	if fmt.Sprint(batches) != "[[k0 k1 k2] [k3 k4]]" {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+}
+
+func TestConsumerGroups(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	topic := NewTopic(4)
+	a1, a2 := topic.NewConsumer("a"), topic.NewConsumer("a")
+	b := topic.NewConsumer("b")
+
+	// The producer sends more than the topic size, consumption must keep it going.
+	const total = 20
+	sendErr := make(chan error, 1)
+	go func() {
+		for i := 0; i < total; i++ {
+			if err := topic.SendMessage(ctx, fmt.Sprintf("k%d", i), []byte("v")); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- topic.Close()
+	}()
+
+	consumeAll := func(consumers ...mq.Consumer) map[int64]int {
+		received := make(chan int64, total*2)
+		done := make(chan struct{})
+		for _, c := range consumers {
+			go func() {
+				defer func() { done <- struct{}{} }()
+				for {
+					if err := c.Subscribe(ctx, func(msg mq.Message) error {
+						received <- msg.Offset()
+						return nil
+					}); err != nil {
+						return
+					}
+				}
+			}()
+		}
+		for range consumers {
+			<-done
+		}
+		close(received)
+		counts := make(map[int64]int)
+		for offset := range received {
+			counts[offset]++
+		}
+		return counts
+	}
+	groupB := make(chan map[int64]int, 1)
+	go func() { groupB <- consumeAll(b) }()
+	groupA := consumeAll(a1, a2)
+	if err := <-sendErr; err != nil {
+		t.Fatal(err)
+	}
+	for name, counts := range map[string]map[int64]int{"a": groupA, "b": <-groupB} {
+		if len(counts) != total {
+			t.Fatalf("group %s received %d messages, want %d", name, len(counts), total)
+		}
+		for offset, n := range counts {
+			if n != 1 {
+				t.Fatalf("group %s received offset %d %d times", name, offset, n)
+			}
+		}
+	}
+}
+
+func TestIdleGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	topic := NewTopic(2)
+	active := topic.NewConsumer("active")
+	idle := topic.NewConsumer("idle")
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := topic.SendMessage(ctx, "k", []byte("v")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	consume := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := active.Subscribe(ctx, func(msg mq.Message) error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	send(2)
+	consume(2)
+	// The idle group still holds the messages, the producer waits for it.
+	sendCtx, sendCancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer sendCancel()
+	if err := topic.SendMessage(sendCtx, "k", []byte("v")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the producer blocked, got %v", err)
+	}
+	// Closing its consumer removes the idle group, the others go on.
+	if err := idle.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := idle.Subscribe(ctx, func(msg mq.Message) error { return nil }); !errors.Is(err, errClosed) {
+		t.Fatalf("expected closed consumer, got %v", err)
+	}
+	send(2)
+	consume(2)
+	send(2)
+	consume(2)
+}
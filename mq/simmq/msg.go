@@ -14,12 +14,18 @@
 
 package simmq
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type message struct {
-	ctx   context.Context
-	key   string
-	value []byte
+	ctx       context.Context
+	key       string
+	value     []byte
+	headers   map[string]string
+	offset    int64
+	timestamp time.Time
 }
 
 func (m *message) Context() context.Context {
@@ -34,6 +40,23 @@ func (m *message) Value() []byte {
 	return m.value
 }
 
+func (m *message) Headers() map[string]string {
+	return m.headers
+}
+
+func (m *message) Partition() int32 {
+	return 0
+}
+
+// Offset returns the sequence number of the message in its topic, the same for every group.
+func (m *message) Offset() int64 {
+	return m.offset
+}
+
+func (m *message) Timestamp() time.Time {
+	return m.timestamp
+}
+
 func (m *message) Mark() {
 }
 
@@ -0,0 +1,114 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typed
+
+import (
+	"encoding/json"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types written by the codecs of this package.
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+	ContentTypeMsgpack  = "application/msgpack"
+)
+
+// Codec converts the values of a typed producer and consumer to message values.
+type Codec[T any] interface {
+	// ContentType is written in the HeaderContentType header of every message.
+	ContentType() string
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// Proto returns the protobuf codec of the message type T, used as Proto[pb.Msg]() for *pb.Msg values.
+func Proto[T any, PT interface {
+	*T
+	proto.Message
+}]() Codec[PT] {
+	return protoCodec[T, PT]{}
+}
+
+type protoCodec[T any, PT interface {
+	*T
+	proto.Message
+}] struct{}
+
+func (protoCodec[T, PT]) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protoCodec[T, PT]) Marshal(v PT) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (protoCodec[T, PT]) Unmarshal(data []byte) (PT, error) {
+	v := PT(new(T))
+	if err := proto.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// JSON returns the encoding/json codec of T.
+func JSON[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// msgpackHandle is safe for concurrent use once configured.
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// Msgpack returns the MessagePack codec of T. Struct fields are keyed by the "codec", then the
+// "json" tag, then their name.
+func Msgpack[T any]() Codec[T] {
+	return msgpackCodec[T]{}
+}
+
+type msgpackCodec[T any] struct{}
+
+func (msgpackCodec[T]) ContentType() string {
+	return ContentTypeMsgpack
+}
+
+func (msgpackCodec[T]) Marshal(v T) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, msgpackHandle).Encode(v)
+	return data, err
+}
+
+func (msgpackCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&v)
+	return v, err
+}
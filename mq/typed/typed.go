@@ -0,0 +1,166 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package typed sends and receives Go values over any mq backend, converted by a Codec.
+package typed
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/mq"
+)
+
+// HeaderContentType is the header holding the content type of the codec a message was sent with.
+const HeaderContentType = "content-type"
+
+// DecodeError is passed to the error handler for a message the codec could not decode.
+type DecodeError struct {
+	Key         string
+	ContentType string // content type of the message, empty if it has none
+	Expected    string // content type of the codec
+	Err         error  // nil on a content type mismatch
+}
+
+func (e *DecodeError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("message %q has content type %q, expected %q", e.Key, e.ContentType, e.Expected)
+	}
+	return fmt.Sprintf("decode message %q as %s: %v", e.Key, e.Expected, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NewProducer returns a producer sending the values of type T encoded by codec.
+func NewProducer[T any](producer mq.Producer, codec Codec[T]) *Producer[T] {
+	return &Producer[T]{producer: producer, codec: codec}
+}
+
+// Producer sends values of type T.
+type Producer[T any] struct {
+	producer mq.Producer
+	codec    Codec[T]
+}
+
+func (p *Producer[T]) Send(ctx context.Context, key string, v T) error {
+	return p.SendWithHeaders(ctx, key, v, nil)
+}
+
+// SendWithHeaders sends v with headers, to which HeaderContentType is added.
+func (p *Producer[T]) SendWithHeaders(ctx context.Context, key string, v T, headers map[string]string) error {
+	value, err := p.codec.Marshal(v)
+	if err != nil {
+		return errs.WrapMsg(err, "marshal message failed", "key", key, "contentType", p.codec.ContentType())
+	}
+	headers = maps.Clone(headers)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	headers[HeaderContentType] = p.codec.ContentType()
+	return p.producer.SendMessageWithHeaders(ctx, key, value, headers)
+}
+
+// Close closes the underlying producer.
+func (p *Producer[T]) Close() error {
+	return p.producer.Close()
+}
+
+// Message is a received message with its decoded value.
+type Message[T any] struct {
+	mq.Message
+	Data T
+}
+
+type Handler[T any] func(msg *Message[T]) error
+
+// ErrorHandler handles a message that could not be decoded, err being a *DecodeError.
+type ErrorHandler func(msg mq.Message, err error)
+
+// ConsumerOption configures a typed Consumer.
+type ConsumerOption func(*consumerOptions)
+
+type consumerOptions struct {
+	onError ErrorHandler
+}
+
+// WithErrorHandler sets the handler of the messages that could not be decoded, which are
+// then marked and skipped. By default, they are logged and skipped.
+func WithErrorHandler(fn ErrorHandler) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.onError = fn
+	}
+}
+
+// NewConsumer returns a consumer decoding the values of type T with codec.
+// Messages without HeaderContentType are decoded too, those with another content type are not.
+func NewConsumer[T any](consumer mq.Consumer, codec Codec[T], opts ...ConsumerOption) *Consumer[T] {
+	c := &Consumer[T]{consumer: consumer, codec: codec}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+// Consumer receives values of type T.
+type Consumer[T any] struct {
+	consumer mq.Consumer
+	codec    Codec[T]
+	opts     consumerOptions
+}
+
+// Subscribe decodes the next message and handles it. A message that cannot be decoded goes to
+// the error handler instead of fn, and nil is returned so that consumption goes on.
+func (c *Consumer[T]) Subscribe(ctx context.Context, fn Handler[T]) error {
+	return c.consumer.Subscribe(ctx, func(msg mq.Message) error {
+		v, err := c.decode(msg)
+		if err != nil {
+			c.onError(msg, err)
+			msg.Mark()
+			return nil
+		}
+		return fn(&Message[T]{Message: msg, Data: v})
+	})
+}
+
+func (c *Consumer[T]) decode(msg mq.Message) (T, error) {
+	expected := c.codec.ContentType()
+	contentType := msg.Headers()[HeaderContentType]
+	if contentType != "" && contentType != expected {
+		var zero T
+		return zero, &DecodeError{Key: msg.Key(), ContentType: contentType, Expected: expected}
+	}
+	v, err := c.codec.Unmarshal(msg.Value())
+	if err != nil {
+		return v, &DecodeError{Key: msg.Key(), ContentType: contentType, Expected: expected, Err: err}
+	}
+	return v, nil
+}
+
+func (c *Consumer[T]) onError(msg mq.Message, err error) {
+	if c.opts.onError != nil {
+		c.opts.onError(msg, err)
+		return
+	}
+	log.ZError(msg.Context(), "skip undecodable message", err, "key", msg.Key(), "offset", msg.Offset())
+}
+
+// Close closes the underlying consumer.
+func (c *Consumer[T]) Close() error {
+	return c.consumer.Close()
+}
@@ -0,0 +1,92 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typed
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smartim/tools/mq"
+	"github.com/smartim/tools/mq/simmq"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type event struct {
+	UserID string `json:"userID"`
+	Seq    int64  `json:"seq"`
+}
+
+func roundTrip[T any](t *testing.T, codec Codec[T], v T, check func(got T) bool) {
+	t.Helper()
+	ctx := context.Background()
+	producer, consumer := simmq.NewMemory(4)
+	defer producer.Close()
+	if err := NewProducer(producer, codec).SendWithHeaders(ctx, "key", v, map[string]string{"trace": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	err := NewConsumer(consumer, codec).Subscribe(ctx, func(msg *Message[T]) error {
+		if msg.Headers()[HeaderContentType] != codec.ContentType() || msg.Headers()["trace"] != "1" {
+			t.Fatalf("unexpected headers %v", msg.Headers())
+		}
+		if !check(msg.Data) {
+			t.Fatalf("unexpected value %v", msg.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	e := event{UserID: "u1", Seq: 42}
+	roundTrip(t, JSON[event](), e, func(got event) bool { return got == e })
+	roundTrip(t, Msgpack[event](), e, func(got event) bool { return got == e })
+	roundTrip(t, Proto[wrapperspb.StringValue](), wrapperspb.String("hello"), func(got *wrapperspb.StringValue) bool {
+		return got.GetValue() == "hello"
+	})
+}
+
+func TestDecodeError(t *testing.T) {
+	ctx := context.Background()
+	producer, consumer := simmq.NewMemory(4)
+	defer producer.Close()
+	var failed []*DecodeError
+	c := NewConsumer(consumer, JSON[event](), WithErrorHandler(func(msg mq.Message, err error) {
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("unexpected error %v", err)
+		}
+		failed = append(failed, decodeErr)
+	}))
+	if err := producer.SendMessage(ctx, "invalid", []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewProducer(producer, Msgpack[event]()).Send(ctx, "msgpack", event{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Subscribe(ctx, func(msg *Message[event]) error {
+			t.Fatalf("handler called for %s", msg.Key())
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(failed) != 2 || failed[0].Err == nil || failed[1].Err != nil || failed[1].ContentType != ContentTypeMsgpack {
+		t.Fatalf("unexpected decode errors %v", failed)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walmq
+
+import "time"
+
+// Fsync policies of Config.Sync.
+const (
+	// SyncAlways fsyncs every message and offset commit before returning, nothing acknowledged is lost.
+	SyncAlways = "always"
+	// SyncInterval fsyncs every Config.SyncInterval, a crash loses at most that much.
+	SyncInterval = "interval"
+	// SyncNever leaves flushing to the operating system, only a process crash is survived.
+	SyncNever = "never"
+)
+
+// Initial offsets of Config.InitialOffset.
+const (
+	// OffsetOldest makes a new consumer group read the messages still on disk.
+	OffsetOldest = "oldest"
+	// OffsetNewest makes a new consumer group only read the messages sent after it is created.
+	OffsetNewest = "newest"
+)
+
+const (
+	defaultSegmentSize  = 64 * 1024 * 1024
+	defaultSyncInterval = time.Second
+)
+
+type Config struct {
+	// Dir is the root directory, every topic is stored in its own sub directory.
+	Dir string `yaml:"dir"`
+
+	// SegmentSize is the size after which a new log file is started, 64MB by default.
+	SegmentSize int64 `yaml:"segmentSize"`
+
+	// Sync is the fsync policy: SyncAlways, SyncInterval (default) or SyncNever.
+	Sync string `yaml:"sync"`
+
+	// SyncInterval is the fsync period of SyncInterval, 1 second by default.
+	SyncInterval time.Duration `yaml:"syncInterval"`
+
+	// Retention is how long full segments are kept, whether consumed or not. Zero keeps them forever.
+	Retention time.Duration `yaml:"retention"`
+
+	// InitialOffset is where a consumer group without committed offset starts: OffsetOldest (default) or OffsetNewest.
+	InitialOffset string `yaml:"initialOffset"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.SegmentSize <= 0 {
+		c.SegmentSize = defaultSegmentSize
+	}
+	switch c.Sync {
+	case SyncAlways, SyncNever:
+	default:
+		c.Sync = SyncInterval
+	}
+	if c.SyncInterval <= 0 {
+		c.SyncInterval = defaultSyncInterval
+	}
+	if c.InitialOffset != OffsetNewest {
+		c.InitialOffset = OffsetOldest
+	}
+	return c
+}
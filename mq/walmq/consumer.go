@@ -0,0 +1,82 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walmq
+
+import (
+	"context"
+	"time"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/mq"
+)
+
+var _ mq.BatchConsumer = (*consumer)(nil)
+
+type consumer struct {
+	group *group
+}
+
+// Subscribe waits for the next message of the group and handles it.
+func (c *consumer) Subscribe(ctx context.Context, fn mq.Handler) error {
+	msg, err := c.group.next(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(msg)
+}
+
+func (c *consumer) SubscribeBatch(ctx context.Context, size int, maxWait time.Duration, fn mq.BatchHandler) error {
+	if size <= 0 {
+		return errs.ErrArgs.WrapMsg("invalid batch size", "size", size)
+	}
+	msg, err := c.group.next(ctx)
+	if err != nil {
+		return err
+	}
+	batch := append(make(mq.Batch, 0, size), msg)
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+collect:
+	for len(batch) < size {
+		notify, err := c.group.topic.wait()
+		if err != nil {
+			break
+		}
+		msg, err := c.group.poll()
+		if err != nil {
+			return err
+		}
+		if msg != nil {
+			batch = append(batch, msg)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			// Handle what was collected rather than dropping it.
+			break collect
+		case <-timer.C:
+			break collect
+		case <-c.group.topic.done:
+			break collect
+		case <-notify:
+		}
+	}
+	return fn(batch)
+}
+
+// Close commits the offsets marked so far, the group goes on for its other consumers.
+func (c *consumer) Close() error {
+	return c.group.commit()
+}
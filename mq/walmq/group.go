@@ -0,0 +1,247 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walmq
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+)
+
+// group is the position of a consumer group in the log, shared by its consumers.
+type group struct {
+	topic *Topic
+	name  string
+	path  string
+
+	readLock sync.Mutex
+	reader   reader
+
+	commitLock sync.Mutex
+	delivered  []int64        // offsets of the delivered messages not consumed yet, in order
+	done       map[int64]bool // marked offsets waiting for an earlier delivered message to be marked
+	marked     int64          // offset before which every delivered message is marked
+	committed  int64          // offset stored in the group file
+}
+
+// openGroup loads the committed offset of name, or stores the initial one for a new group.
+// It is called with t.lock held.
+func (t *Topic) openGroup(name string) (*group, error) {
+	g := &group{
+		topic: t,
+		name:  name,
+		path:  t.groupPath(name),
+		done:  make(map[int64]bool),
+	}
+	data, err := os.ReadFile(g.path)
+	switch {
+	case err == nil:
+		offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "invalid group offset", "path", g.path)
+		}
+		g.committed = offset
+	case os.IsNotExist(err):
+		if t.conf.InitialOffset == OffsetNewest {
+			g.committed = t.next
+		} else {
+			g.committed = t.segments[0]
+		}
+		if err := writeOffset(g.path, g.committed, t.conf.Sync == SyncAlways); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errs.WrapMsg(err, "read group offset failed", "path", g.path)
+	}
+	g.marked = g.committed
+	g.reader = reader{topic: t, next: g.committed}
+	return g, nil
+}
+
+// next waits for the next message of the group.
+func (g *group) next(ctx context.Context) (*message, error) {
+	for {
+		notify, err := g.topic.wait()
+		if err != nil {
+			return nil, err
+		}
+		msg, err := g.poll()
+		if err != nil || msg != nil {
+			return msg, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-g.topic.done:
+			return nil, errClosed
+		case <-notify:
+		}
+	}
+}
+
+// poll returns the next message of the group, nil if there is none yet.
+func (g *group) poll() (*message, error) {
+	g.readLock.Lock()
+	defer g.readLock.Unlock()
+	rec, err := g.reader.read()
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	g.commitLock.Lock()
+	g.delivered = append(g.delivered, rec.offset)
+	g.commitLock.Unlock()
+	return newMessage(g, rec), nil
+}
+
+// mark records the message at offset as consumed. The consumers of the group mark their
+// messages in any order, the position only moves past the messages all marked.
+func (g *group) mark(offset int64) {
+	g.commitLock.Lock()
+	defer g.commitLock.Unlock()
+	if len(g.delivered) == 0 || offset < g.delivered[0] {
+		return
+	}
+	g.done[offset] = true
+	for len(g.delivered) > 0 && g.done[g.delivered[0]] {
+		delete(g.done, g.delivered[0])
+		g.marked = g.delivered[0] + 1
+		g.delivered = g.delivered[1:]
+	}
+}
+
+// commit stores the marked offset, the messages before it are not delivered again after a restart.
+func (g *group) commit() error {
+	g.commitLock.Lock()
+	defer g.commitLock.Unlock()
+	if g.marked <= g.committed {
+		return nil
+	}
+	if err := writeOffset(g.path, g.marked, g.topic.conf.Sync == SyncAlways); err != nil {
+		return err
+	}
+	g.committed = g.marked
+	return nil
+}
+
+func (g *group) close() error {
+	g.readLock.Lock()
+	g.reader.close()
+	g.readLock.Unlock()
+	return g.commit()
+}
+
+// writeOffset replaces the group file atomically, a crash leaves either the old or the new offset.
+func writeOffset(path string, offset int64, sync bool) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errs.WrapMsg(err, "create group offset failed", "path", tmp)
+	}
+	if _, err := f.WriteString(strconv.FormatInt(offset, 10)); err != nil {
+		_ = f.Close()
+		return errs.WrapMsg(err, "write group offset failed", "path", tmp)
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return errs.WrapMsg(err, "sync group offset failed", "path", tmp)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return errs.WrapMsg(err, "close group offset failed", "path", tmp)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errs.WrapMsg(err, "rename group offset failed", "path", path)
+	}
+	return nil
+}
+
+// reader reads the log sequentially, one segment file at a time.
+type reader struct {
+	topic *Topic
+	file  *os.File
+	buf   *bufio.Reader
+	base  int64 // base offset of the open segment
+	next  int64 // offset of the next record to read
+}
+
+// read returns the next record, nil if the end of the log is reached.
+func (r *reader) read() (*record, error) {
+	end, base, err := r.topic.locate(r.next)
+	if err != nil {
+		return nil, err
+	}
+	if r.next < base {
+		log.ZWarn(context.Background(), "messages removed by retention before being consumed", nil,
+			"topic", r.topic.name, "from", r.next, "to", base)
+		r.next = base
+	}
+	if r.next >= end {
+		return nil, nil
+	}
+	if r.file == nil || r.base != base {
+		if err := r.open(base); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		rec, _, err := readRecord(r.buf)
+		if err != nil {
+			r.close()
+			if errors.Is(err, io.EOF) {
+				err = errCorrupted
+			}
+			return nil, errs.WrapMsg(err, "read segment failed", "topic", r.topic.name, "offset", r.next)
+		}
+		if rec.offset < r.next {
+			continue
+		}
+		if rec.offset != r.next {
+			r.close()
+			return nil, errs.WrapMsg(errCorrupted, "unexpected offset", "topic", r.topic.name, "offset", rec.offset, "expected", r.next)
+		}
+		r.next++
+		return rec, nil
+	}
+}
+
+func (r *reader) open(base int64) error {
+	r.close()
+	f, err := os.Open(r.topic.segmentPath(base))
+	if err != nil {
+		return errs.WrapMsg(err, "open segment failed", "topic", r.topic.name, "base", base)
+	}
+	r.file = f
+	r.buf = bufio.NewReader(f)
+	r.base = base
+	return nil
+}
+
+func (r *reader) close() {
+	if r.file == nil {
+		return
+	}
+	_ = r.file.Close()
+	r.file = nil
+	r.buf = nil
+}
@@ -0,0 +1,80 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walmq
+
+import (
+	"context"
+	"time"
+
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/mcontext"
+)
+
+func newMessage(g *group, rec *record) *message {
+	return &message{
+		ctx:   mcontext.WithMustInfoCtx(rec.ctxValues),
+		rec:   rec,
+		group: g,
+	}
+}
+
+type message struct {
+	ctx   context.Context
+	rec   *record
+	group *group
+}
+
+func (m *message) Context() context.Context {
+	return m.ctx
+}
+
+func (m *message) Key() string {
+	return m.rec.key
+}
+
+func (m *message) Value() []byte {
+	return m.rec.value
+}
+
+func (m *message) Headers() map[string]string {
+	return m.rec.headers
+}
+
+func (m *message) Partition() int32 {
+	return 0
+}
+
+func (m *message) Offset() int64 {
+	return m.rec.offset
+}
+
+func (m *message) Timestamp() time.Time {
+	return time.Unix(0, m.rec.timestamp)
+}
+
+// Mark marks the message as consumed. The group position only moves past the messages marked
+// by every consumer of the group, so that a message left unmarked by a consumer is delivered
+// again after a restart, even if later ones were committed by the others.
+func (m *message) Mark() {
+	m.group.mark(m.rec.offset)
+}
+
+// Commit marks the message and stores the group position on disk.
+func (m *message) Commit() {
+	m.Mark()
+	if err := m.group.commit(); err != nil {
+		log.ZError(m.ctx, "commit wal mq offset failed", err, "topic", m.group.topic.name, "group", m.group.name, "offset", m.rec.offset)
+	}
+}
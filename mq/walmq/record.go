@@ -0,0 +1,186 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walmq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// recordHeaderSize is the body length and the body checksum, both uint32.
+	recordHeaderSize = 8
+
+	// maxRecordSize bounds the body length, a larger one can only be a corrupted header.
+	maxRecordSize = 256 * 1024 * 1024
+
+	// ctxValueCount is the number of context values of a record, see mcontext.WithMustInfoCtx.
+	ctxValueCount = 4
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// errCorrupted is returned for a record that is truncated or fails its checksum.
+	errCorrupted = errors.New("corrupted record")
+)
+
+// record is a message as stored in a segment:
+//
+//	uint32 body length | uint32 crc32c of body | body
+//
+// where body is the offset and unix nano timestamp as int64, followed by the key, the context
+// values, the headers and the value, all but the value prefixed with their uvarint length.
+type record struct {
+	offset    int64
+	timestamp int64
+	key       string
+	ctxValues []string
+	headers   map[string]string
+	value     []byte
+}
+
+func (r *record) encode() []byte {
+	size := recordHeaderSize + 16 + binary.MaxVarintLen64*(3+ctxValueCount+2*len(r.headers)) + len(r.key) + len(r.value)
+	for _, v := range r.ctxValues {
+		size += len(v)
+	}
+	for k, v := range r.headers {
+		size += len(k) + len(v)
+	}
+	buf := make([]byte, recordHeaderSize, size)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(r.offset))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(r.timestamp))
+	buf = appendString(buf, r.key)
+	for i := 0; i < ctxValueCount; i++ {
+		var v string
+		if i < len(r.ctxValues) {
+			v = r.ctxValues[i]
+		}
+		buf = appendString(buf, v)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(r.headers)))
+	for k, v := range r.headers {
+		buf = appendString(buf, k)
+		buf = appendString(buf, v)
+	}
+	buf = append(buf, r.value...)
+	body := buf[recordHeaderSize:]
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(body, crcTable))
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// readRecord reads the next record and returns its size on disk. It returns io.EOF at the
+// end of the data and errCorrupted for a torn or damaged record.
+func readRecord(reader *bufio.Reader) (*record, int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorrupted
+		}
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < 16 || length > maxRecordSize {
+		return nil, 0, errCorrupted
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorrupted
+		}
+		return nil, 0, err
+	}
+	if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errCorrupted
+	}
+	rec, err := decodeBody(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rec, recordHeaderSize + int64(length), nil
+}
+
+func decodeBody(body []byte) (*record, error) {
+	rec := &record{
+		offset:    int64(binary.BigEndian.Uint64(body[0:8])),
+		timestamp: int64(binary.BigEndian.Uint64(body[8:16])),
+	}
+	d := decoder{buf: body[16:]}
+	rec.key = d.string()
+	rec.ctxValues = make([]string, ctxValueCount)
+	for i := range rec.ctxValues {
+		rec.ctxValues[i] = d.string()
+	}
+	if n := d.uvarint(); n > 0 && d.err == nil {
+		if n > uint64(len(d.buf)) {
+			return nil, errCorrupted
+		}
+		rec.headers = make(map[string]string, n)
+		for i := uint64(0); i < n; i++ {
+			k := d.string()
+			rec.headers[k] = d.string()
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	rec.value = d.buf
+	return rec, nil
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = errCorrupted
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
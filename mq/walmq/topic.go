@@ -0,0 +1,432 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package walmq is a persistent mq for single-node deployments. Every topic is a write-ahead
+// log of segment files in its own directory, and every consumer group keeps its committed
+// offset next to it, so that the messages not committed before a crash are delivered again
+// after a restart.
+package walmq
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/mcontext"
+	"github.com/smartim/tools/mq"
+)
+
+const (
+	segmentSuffix = ".log"
+	groupDir      = "groups"
+)
+
+var errClosed = errors.New("wal mq closed")
+
+// Open opens the log of topic in conf.Dir, creating it if needed. The records torn by a crash
+// at the end of the log are truncated. The returned Topic is the producer of the topic, and
+// its consumers are created with NewConsumer.
+func Open(conf *Config, topic string) (*Topic, error) {
+	if conf == nil || conf.Dir == "" {
+		return nil, errs.ErrArgs.WrapMsg("wal mq dir is empty")
+	}
+	if !validName(topic) {
+		return nil, errs.ErrArgs.WrapMsg("invalid topic name", "topic", topic)
+	}
+	t := &Topic{
+		conf:   conf.withDefaults(),
+		name:   topic,
+		dir:    filepath.Join(conf.Dir, topic),
+		notify: make(chan struct{}),
+		groups: make(map[string]*group),
+		done:   make(chan struct{}),
+	}
+	if err := os.MkdirAll(filepath.Join(t.dir, groupDir), 0o755); err != nil {
+		return nil, errs.WrapMsg(err, "create wal mq dir failed", "dir", t.dir)
+	}
+	if err := t.recover(); err != nil {
+		return nil, err
+	}
+	if t.conf.Sync == SyncInterval {
+		t.wg.Add(1)
+		go t.syncLoop()
+	}
+	return t, nil
+}
+
+// Topic is the write-ahead log of a topic and its mq.Producer.
+type Topic struct {
+	conf Config
+	name string
+	dir  string
+
+	lock       sync.Mutex
+	segments   []int64 // base offsets, oldest first, the last one being the active segment
+	active     *os.File
+	activeSize int64
+	next       int64 // offset of the next message
+	dirty      bool  // whether the active segment has writes not synced yet
+	notify     chan struct{}
+	groups     map[string]*group
+	closed     bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (t *Topic) SendMessage(ctx context.Context, key string, value []byte) error {
+	return t.SendMessageWithHeaders(ctx, key, value, nil)
+}
+
+// SendMessageWithHeaders appends the message to the log. With SyncAlways, it returns once the
+// message is on disk.
+func (t *Topic) SendMessageWithHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	operationID, opUserID, platform, connID, err := mcontext.GetCtxInfos(ctx)
+	if err != nil {
+		return err
+	}
+	return t.append(&record{
+		timestamp: time.Now().UnixNano(),
+		key:       key,
+		ctxValues: []string{operationID, opUserID, platform, connID},
+		headers:   maps.Clone(headers),
+		value:     value,
+	})
+}
+
+// NewConsumer returns a consumer of group. The consumers of a group share its position, each
+// message being delivered to only one of them, while every group receives every message.
+// A group without committed offset starts as set by Config.InitialOffset.
+func (t *Topic) NewConsumer(group string) (mq.Consumer, error) {
+	if !validName(group) {
+		return nil, errs.ErrArgs.WrapMsg("invalid group name", "group", group)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return nil, errClosed
+	}
+	g, ok := t.groups[group]
+	if !ok {
+		var err error
+		if g, err = t.openGroup(group); err != nil {
+			return nil, err
+		}
+		t.groups[group] = g
+	}
+	return &consumer{group: g}, nil
+}
+
+// Close commits the offsets marked by the consumers, syncs the log and closes it.
+func (t *Topic) Close() error {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	groups := make([]*group, 0, len(t.groups))
+	for _, g := range t.groups {
+		groups = append(groups, g)
+	}
+	t.lock.Unlock()
+	t.wg.Wait()
+
+	var errList []error
+	for _, g := range groups {
+		if err := g.close(); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if err := t.active.Sync(); err != nil {
+		errList = append(errList, errs.WrapMsg(err, "sync segment failed", "topic", t.name))
+	}
+	if err := t.active.Close(); err != nil {
+		errList = append(errList, errs.WrapMsg(err, "close segment failed", "topic", t.name))
+	}
+	return errors.Join(errList...)
+}
+
+func (t *Topic) append(rec *record) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return errClosed
+	}
+	rec.offset = t.next
+	data := rec.encode()
+	if t.activeSize > 0 && t.activeSize+int64(len(data)) > t.conf.SegmentSize {
+		if err := t.roll(); err != nil {
+			return err
+		}
+	}
+	if _, err := t.active.Write(data); err != nil {
+		// A partial record would hide the following ones, drop it.
+		if truncErr := t.active.Truncate(t.activeSize); truncErr != nil {
+			log.ZError(context.Background(), "truncate partial record failed", truncErr, "topic", t.name, "offset", rec.offset)
+		}
+		return errs.WrapMsg(err, "write segment failed", "topic", t.name, "offset", rec.offset)
+	}
+	t.activeSize += int64(len(data))
+	t.next++
+	close(t.notify)
+	t.notify = make(chan struct{})
+	if t.conf.Sync == SyncAlways {
+		if err := t.active.Sync(); err != nil {
+			return errs.WrapMsg(err, "sync segment failed", "topic", t.name, "offset", rec.offset)
+		}
+		return nil
+	}
+	t.dirty = true
+	return nil
+}
+
+// roll starts a new active segment at the next offset. The previous one is synced whatever
+// the policy, so that only the active segment can ever hold torn records.
+func (t *Topic) roll() error {
+	f, err := openSegment(t.segmentPath(t.next))
+	if err != nil {
+		return err
+	}
+	if err := t.active.Sync(); err != nil {
+		_ = f.Close()
+		return errs.WrapMsg(err, "sync segment failed", "topic", t.name)
+	}
+	if err := t.active.Close(); err != nil {
+		log.ZWarn(context.Background(), "close segment failed", err, "topic", t.name)
+	}
+	if err := syncDir(t.dir); err != nil {
+		log.ZWarn(context.Background(), "sync wal mq dir failed", err, "dir", t.dir)
+	}
+	t.segments = append(t.segments, t.next)
+	t.active = f
+	t.activeSize = 0
+	t.dirty = false
+	t.removeExpired()
+	return nil
+}
+
+// removeExpired deletes the full segments older than Config.Retention.
+func (t *Topic) removeExpired() {
+	if t.conf.Retention <= 0 {
+		return
+	}
+	deadline := time.Now().Add(-t.conf.Retention)
+	var n int
+	for n < len(t.segments)-1 {
+		path := t.segmentPath(t.segments[n])
+		info, err := os.Stat(path)
+		if err != nil && !os.IsNotExist(err) {
+			log.ZWarn(context.Background(), "stat segment failed", err, "path", path)
+			break
+		}
+		if err == nil {
+			if info.ModTime().After(deadline) {
+				break
+			}
+			if err := os.Remove(path); err != nil {
+				log.ZWarn(context.Background(), "remove expired segment failed", err, "path", path)
+				break
+			}
+		}
+		n++
+	}
+	t.segments = t.segments[n:]
+}
+
+func (t *Topic) syncLoop() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(t.conf.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.sync()
+		}
+	}
+}
+
+func (t *Topic) sync() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.dirty || t.closed {
+		return
+	}
+	if err := t.active.Sync(); err != nil {
+		log.ZError(context.Background(), "sync segment failed", err, "topic", t.name)
+		return
+	}
+	t.dirty = false
+}
+
+// recover opens the active segment, truncating what follows its last valid record.
+func (t *Topic) recover() error {
+	segments, err := listSegments(t.dir)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		segments = []int64{0}
+	}
+	base := segments[len(segments)-1]
+	path := t.segmentPath(base)
+	f, err := openSegment(path)
+	if err != nil {
+		return err
+	}
+	next, size, err := scanSegment(f, base)
+	if err != nil {
+		_ = f.Close()
+		return errs.WrapMsg(err, "scan segment failed", "path", path)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errs.WrapMsg(err, "stat segment failed", "path", path)
+	}
+	if info.Size() > size {
+		log.ZWarn(context.Background(), "truncate torn records of wal mq", nil, "path", path, "size", info.Size(), "valid", size)
+		if err := f.Truncate(size); err != nil {
+			_ = f.Close()
+			return errs.WrapMsg(err, "truncate segment failed", "path", path)
+		}
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return errs.WrapMsg(err, "sync segment failed", "path", path)
+		}
+	}
+	t.segments = segments
+	t.active = f
+	t.activeSize = size
+	t.next = next
+	return nil
+}
+
+// locate returns the next offset of the log, and the base offset of the segment holding offset.
+// The base is greater than offset when the segment was removed by the retention.
+func (t *Topic) locate(offset int64) (next int64, base int64, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return 0, 0, errClosed
+	}
+	i := sort.Search(len(t.segments), func(i int) bool { return t.segments[i] > offset })
+	if i == 0 {
+		return t.next, t.segments[0], nil
+	}
+	return t.next, t.segments[i-1], nil
+}
+
+// wait returns a channel closed on the next append.
+func (t *Topic) wait() (<-chan struct{}, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return nil, errClosed
+	}
+	return t.notify, nil
+}
+
+func (t *Topic) segmentPath(base int64) string {
+	return filepath.Join(t.dir, fmt.Sprintf("%020d%s", base, segmentSuffix))
+}
+
+func (t *Topic) groupPath(group string) string {
+	return filepath.Join(t.dir, groupDir, group)
+}
+
+func openSegment(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "open segment failed", "path", path)
+	}
+	return f, nil
+}
+
+// listSegments returns the base offsets of the segments in dir, in ascending order.
+func listSegments(dir string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "read wal mq dir failed", "dir", dir)
+	}
+	var segments []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, base)
+	}
+	slices.Sort(segments)
+	return segments, nil
+}
+
+// scanSegment reads the segment from the start and returns the offset following its last
+// valid record, and the size of the valid records.
+func scanSegment(f *os.File, base int64) (next int64, size int64, err error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	reader := bufio.NewReader(f)
+	next = base
+	for {
+		rec, n, err := readRecord(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, errCorrupted) {
+				return next, size, nil
+			}
+			return 0, 0, err
+		}
+		if rec.offset != next {
+			return next, size, nil
+		}
+		next++
+		size += n
+	}
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
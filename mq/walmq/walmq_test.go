@@ -0,0 +1,189 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package walmq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/mcontext"
+	"github.com/smartim/tools/mq"
+)
+
+func consume(t *testing.T, ctx context.Context, c mq.Consumer, fn func(msg mq.Message)) {
+	t.Helper()
+	if err := c.Subscribe(ctx, func(msg mq.Message) error {
+		fn(msg)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCrashReplay(t *testing.T) {
+	conf := &Config{Dir: t.TempDir(), SegmentSize: 256, Sync: SyncNever}
+	ctx, cancel := context.WithTimeout(mcontext.SetOperationID(context.Background(), "op1"), time.Second*5)
+	defer cancel()
+
+	topic, err := Open(conf, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := topic.NewConsumer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		headers := map[string]string{"index": fmt.Sprint(i)}
+		if err := topic.SendMessageWithHeaders(ctx, fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("value %d", i)), headers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if segments, _ := listSegments(topic.dir); len(segments) < 2 {
+		t.Fatalf("%d segments, want several", len(segments))
+	}
+	for i := 0; i < 6; i++ {
+		consume(t, ctx, consumer, func(msg mq.Message) {
+			if msg.Offset() != int64(i) || msg.Key() != fmt.Sprintf("k%d", i) || msg.Headers()["index"] != fmt.Sprint(i) {
+				t.Fatalf("unexpected message %d: %s %v", msg.Offset(), msg.Key(), msg.Headers())
+			}
+			if id := mcontext.GetOperationID(msg.Context()); id != "op1" {
+				t.Fatalf("unexpected operationID %q", id)
+			}
+			// Messages 3 to 5 are marked but the process crashes before committing them.
+			if i == 2 {
+				msg.Commit()
+			} else {
+				msg.Mark()
+			}
+		})
+	}
+
+	// Simulate a crash in the middle of an append: the topic is not closed, and the last
+	// segment ends with a torn record.
+	f, err := os.OpenFile(topic.segmentPath(topic.segments[len(topic.segments)-1]), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 0, 0, 100, 1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	topic, err = Open(conf, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer topic.Close()
+	if err := topic.SendMessage(ctx, "k10", []byte("value 10")); err != nil {
+		t.Fatal(err)
+	}
+	if consumer, err = topic.NewConsumer("a"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i <= 10; i++ {
+		consume(t, ctx, consumer, func(msg mq.Message) {
+			if msg.Offset() != int64(i) || msg.Key() != fmt.Sprintf("k%d", i) || string(msg.Value()) != fmt.Sprintf("value %d", i) {
+				t.Fatalf("unexpected message %d: %s=%s, want k%d", msg.Offset(), msg.Key(), msg.Value(), i)
+			}
+			msg.Mark()
+		})
+	}
+
+	// Every group receives every message.
+	other, err := topic.NewConsumer("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.(mq.BatchConsumer).SubscribeBatch(ctx, 20, time.Millisecond*50, func(batch mq.Batch) error {
+		if len(batch) != 11 {
+			t.Fatalf("batch of %d messages, want 11", len(batch))
+		}
+		batch.Commit()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is left for a, and Subscribe waits for new messages.
+	waitCtx, waitCancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer waitCancel()
+	if err := consumer.Subscribe(waitCtx, func(msg mq.Message) error {
+		t.Fatalf("unexpected message %d", msg.Offset())
+		return nil
+	}); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestGroupUnmarked(t *testing.T) {
+	conf := &Config{Dir: t.TempDir(), Sync: SyncNever}
+	ctx, cancel := context.WithTimeout(mcontext.SetOperationID(context.Background(), "op1"), time.Second*5)
+	defer cancel()
+
+	topic, err := Open(conf, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := topic.NewConsumer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := topic.NewConsumer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := topic.SendMessage(ctx, fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("value %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first consumer is still handling message 0 when the second one commits message 1.
+	consume(t, ctx, first, func(msg mq.Message) {})
+	consume(t, ctx, second, func(msg mq.Message) {
+		if msg.Offset() != 1 {
+			t.Fatalf("unexpected message %d", msg.Offset())
+		}
+		msg.Commit()
+	})
+	if data, err := os.ReadFile(topic.groupPath("a")); err != nil || string(data) != "0" {
+		t.Fatalf("group offset %q committed past an unmarked message: %v", data, err)
+	}
+
+	// The process crashes before the first consumer commits.
+	topic, err = Open(conf, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer topic.Close()
+	consumer, err := topic.NewConsumer("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		consume(t, ctx, consumer, func(msg mq.Message) {
+			if msg.Offset() != int64(i) {
+				t.Fatalf("unexpected message %d, want %d", msg.Offset(), i)
+			}
+			msg.Commit()
+		})
+	}
+	if data, err := os.ReadFile(topic.groupPath("a")); err != nil || string(data) != "2" {
+		t.Fatalf("unexpected group offset %q: %v", data, err)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -39,6 +40,15 @@ const (
 	maxNumSize  int64 = 10000
 )
 
+const maxListKeys = 1000
+
+var (
+	_ s3.Interface           = (*Aws)(nil)
+	_ s3.ObjectStream        = (*Aws)(nil)
+	_ s3.EncryptedPartSigner = (*Aws)(nil)
+	_ s3.EncryptedStatter    = (*Aws)(nil)
+)
+
 type Config struct {
 	Region          string
 	Bucket          string
@@ -111,11 +121,24 @@ func (a *Aws) IsNotFound(err error) bool {
 }
 
 func (a *Aws) PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *s3.PutOption) (*s3.PresignedPutResult, error) {
-	res, err := a.presign.PresignPutObject(ctx, &aws3.PutObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(name)}, aws3.WithPresignExpires(expire), withDisableHTTPPresignerHeaderV4(nil))
+	enc, err := opt.GetEncryption()
 	if err != nil {
 		return nil, err
 	}
-	return &s3.PresignedPutResult{URL: res.URL}, nil
+	params := &aws3.PutObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(name)}
+	setPutObjectEncryption(params, enc)
+	setPutObjectMetadata(params, opt)
+	res, err := a.presign.PresignPutObject(ctx, params, aws3.WithPresignExpires(expire), withDisableHTTPPresignerHeaderV4(nil))
+	if err != nil {
+		return nil, err
+	}
+	// The encryption, metadata and tagging headers are signed, the client has to send them as returned.
+	header := res.SignedHeader.Clone()
+	header.Del("Host")
+	if len(header) == 0 {
+		header = nil
+	}
+	return &s3.PresignedPutResult{URL: res.URL, Header: header}, nil
 }
 
 func (a *Aws) DeleteObject(ctx context.Context, name string) error {
@@ -123,6 +146,60 @@ func (a *Aws) DeleteObject(ctx context.Context, name string) error {
 	return err
 }
 
+func (a *Aws) DeleteObjects(ctx context.Context, names []string) error {
+	for start := 0; start < len(names); start += maxListKeys {
+		end := min(start+maxListKeys, len(names))
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, name := range names[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(name)})
+		}
+		res, err := a.client.DeleteObjects(ctx, &aws3.DeleteObjectsInput{
+			Bucket: aws.String(a.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(res.Errors) > 0 {
+			e := res.Errors[0]
+			return fmt.Errorf("DeleteObjects %s failed: %s %s", aws.ToString(e.Key), aws.ToString(e.Code), aws.ToString(e.Message))
+		}
+	}
+	return nil
+}
+
+func (a *Aws) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+	params := &aws3.ListObjectsV2Input{
+		Bucket:  aws.String(a.bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(int32(limit)),
+	}
+	if continuationToken != "" {
+		params.ContinuationToken = aws.String(continuationToken)
+	}
+	res, err := a.client.ListObjectsV2(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	info := &s3.ListObjectsResult{
+		Objects:               make([]s3.ObjectInfo, 0, len(res.Contents)),
+		NextContinuationToken: aws.ToString(res.NextContinuationToken),
+		IsTruncated:           aws.ToBool(res.IsTruncated),
+	}
+	for _, object := range res.Contents {
+		info.Objects = append(info.Objects, s3.ObjectInfo{
+			ETag:         a.formatETag(aws.ToString(object.ETag)),
+			Key:          aws.ToString(object.Key),
+			Size:         aws.ToInt64(object.Size),
+			LastModified: aws.ToTime(object.LastModified),
+		})
+	}
+	return info, nil
+}
+
 func (a *Aws) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
 	res, err := a.client.CopyObject(ctx, &aws3.CopyObjectInput{
 		Bucket:     aws.String(a.bucket),
@@ -142,7 +219,14 @@ func (a *Aws) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyO
 }
 
 func (a *Aws) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, error) {
-	res, err := a.client.HeadObject(ctx, &aws3.HeadObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(name)})
+	return a.StatObjectEncrypted(ctx, name, nil)
+}
+
+// StatObjectEncrypted stats an object written with the SSE-C key of enc.
+func (a *Aws) StatObjectEncrypted(ctx context.Context, name string, enc *s3.Encryption) (*s3.ObjectInfo, error) {
+	params := &aws3.HeadObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(name)}
+	setHeadObjectEncryption(params, enc)
+	res, err := a.client.HeadObject(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -153,9 +237,11 @@ func (a *Aws) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, erro
 		return nil, errors.New("GetObjectAttributes object size is nil")
 	}
 	info := &s3.ObjectInfo{
-		ETag: a.formatETag(*res.ETag),
-		Key:  name,
-		Size: *res.ContentLength,
+		ETag:        a.formatETag(*res.ETag),
+		Key:         name,
+		Size:        *res.ContentLength,
+		ContentType: aws.ToString(res.ContentType),
+		Metadata:    s3.LowerMetadata(res.Metadata),
 	}
 	if res.LastModified == nil {
 		info.LastModified = time.Unix(0, 0)
@@ -165,8 +251,36 @@ func (a *Aws) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, erro
 	return info, nil
 }
 
+func (a *Aws) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		_, err := a.client.DeleteObjectTagging(ctx, &aws3.DeleteObjectTaggingInput{Bucket: aws.String(a.bucket), Key: aws.String(name)})
+		return err
+	}
+	_, err := a.client.PutObjectTagging(ctx, &aws3.PutObjectTaggingInput{
+		Bucket:  aws.String(a.bucket),
+		Key:     aws.String(name),
+		Tagging: &types.Tagging{TagSet: tagSet(tags)},
+	})
+	return err
+}
+
+func (a *Aws) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	res, err := a.client.GetObjectTagging(ctx, &aws3.GetObjectTaggingInput{Bucket: aws.String(a.bucket), Key: aws.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	return tagMap(res.TagSet), nil
+}
+
 func (a *Aws) InitiateMultipartUpload(ctx context.Context, name string, opt *s3.PutOption) (*s3.InitiateMultipartUploadResult, error) {
-	res, err := a.client.CreateMultipartUpload(ctx, &aws3.CreateMultipartUploadInput{Bucket: aws.String(a.bucket), Key: aws.String(name)})
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	params := &aws3.CreateMultipartUploadInput{Bucket: aws.String(a.bucket), Key: aws.String(name)}
+	setMultipartUploadEncryption(params, enc)
+	setMultipartUploadMetadata(params, opt)
+	res, err := a.client.CreateMultipartUpload(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -261,6 +375,15 @@ func (a *Aws) ListUploadedParts(ctx context.Context, uploadID string, name strin
 }
 
 func (a *Aws) AuthSign(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int) (*s3.AuthSignResult, error) {
+	return a.AuthSignEncrypted(ctx, uploadID, name, expire, partNumbers, nil)
+}
+
+func (a *Aws) AuthSignEncrypted(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int, enc *s3.Encryption) (*s3.AuthSignResult, error) {
+	if enc != nil {
+		if err := enc.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	res := &s3.AuthSignResult{
 		Parts: make([]s3.SignPart, 0, len(partNumbers)),
 	}
@@ -269,6 +392,7 @@ func (a *Aws) AuthSign(ctx context.Context, uploadID string, name string, expire
 		Key:      aws.String(name),
 		UploadId: aws.String(uploadID),
 	}
+	setUploadPartEncryption(params, enc)
 	opt := aws3.WithPresignExpires(expire)
 	for _, number := range partNumbers {
 		params.PartNumber = aws.Int32(int32(number))
@@ -311,6 +435,61 @@ func (a *Aws) AccessURL(ctx context.Context, name string, expire time.Duration,
 	return res.URL, nil
 }
 
+func (a *Aws) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	params := &aws3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(name),
+	}
+	if rng := opt.Range(); rng != "" {
+		params.Range = aws.String(rng)
+	}
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	setGetObjectEncryption(params, enc)
+	res, err := a.client.GetObject(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (a *Aws) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	if size < 0 {
+		return nil, errors.New("aws put object requires a known size")
+	}
+	params := &aws3.PutObjectInput{
+		Bucket:        aws.String(a.bucket),
+		Key:           aws.String(name),
+		Body:          reader,
+		ContentLength: aws.Int64(size),
+	}
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	if opt != nil && opt.ContentType != "" {
+		params.ContentType = aws.String(opt.ContentType)
+	}
+	setPutObjectEncryption(params, enc)
+	setPutObjectMetadata(params, opt)
+	// The body may not be seekable, so the payload is sent unsigned instead of hashed up front.
+	res, err := a.client.PutObject(ctx, params, aws3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+	if err != nil {
+		return nil, err
+	}
+	if res.ETag == nil || *res.ETag == "" {
+		return nil, errors.New("PutObject etag is nil")
+	}
+	return &s3.ObjectInfo{
+		ETag:         a.formatETag(*res.ETag),
+		Key:          name,
+		Size:         size,
+		LastModified: time.Now(),
+	}, nil
+}
+
 func (a *Aws) FormData(ctx context.Context, name string, size int64, contentType string, duration time.Duration) (*s3.FormData, error) {
 	return nil, errors.New("aws does not currently support form data file uploads")
 }
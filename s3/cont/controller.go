@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,24 +32,31 @@ import (
 	"github.com/smartim/tools/log"
 )
 
-func New(cache S3Cache, impl s3.Interface) *Controller {
-	return &Controller{
+func New(cache S3Cache, impl s3.Interface, opts ...Option) *Controller {
+	c := &Controller{
 		cache: cache,
 		impl:  impl,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 type Controller struct {
-	cache S3Cache
-	impl  s3.Interface
+	cache         S3Cache
+	impl          s3.Interface
+	store         UploadStore
+	verify        bool
+	hashAlgorithm string
 }
 
 func (c *Controller) Engine() string {
 	return c.impl.Engine()
 }
 
-func (c *Controller) HashPath(md5 string) string {
-	return path.Join(hashPath, md5)
+func (c *Controller) HashPath(hash string) string {
+	return path.Join(hashPath, hash)
 }
 
 func (c *Controller) NowPath() string {
@@ -89,14 +97,33 @@ func (c *Controller) InitiateUpload(ctx context.Context, hash string, size int64
 }
 
 func (c *Controller) InitiateUploadContentType(ctx context.Context, hash string, size int64, expire time.Duration, maxParts int, contentType string) (*InitiateUploadResult, error) {
+	return c.InitiateUploadWithReq(ctx, &InitiateUploadReq{
+		Hash:        hash,
+		Size:        size,
+		Expire:      expire,
+		MaxParts:    maxParts,
+		ContentType: contentType,
+	})
+}
+
+func (c *Controller) InitiateUploadWithReq(ctx context.Context, req *InitiateUploadReq) (*InitiateUploadResult, error) {
 	defer log.ZDebug(ctx, "return")
+	hash, size, expire, maxParts, contentType := req.Hash, req.Size, req.Expire, req.MaxParts, req.ContentType
+	enc, err := (&s3.PutOption{Encryption: req.Encryption}).GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	sseC := enc != nil && enc.Type == s3.SSEC
+	if sseC && c.verify {
+		return nil, errs.ErrArgs.WrapMsg("SSE-C uploads can't be verified, their content is only readable with the customer key")
+	}
 	if size < 0 {
 		return nil, errors.New("invalid size")
 	}
 	if hashBytes, err := hex.DecodeString(hash); err != nil {
 		return nil, err
-	} else if len(hashBytes) != md5.Size {
-		return nil, errors.New("invalid md5")
+	} else if len(hashBytes) != c.hashSize() {
+		return nil, errors.New("invalid " + c.algorithm())
 	}
 	partSize, err := c.impl.PartSize(ctx, size)
 	if err != nil {
@@ -114,20 +141,25 @@ func (c *Controller) InitiateUploadContentType(ctx context.Context, hash string,
 	} else if !c.impl.IsNotFound(err) {
 		return nil, err
 	}
-	if size <= partSize {
+	// A presigned upload is stat on completion, which an SSE-C object only allows with its key,
+	// so SSE-C uploads always go through a multipart upload.
+	if size <= partSize && !sseC {
 		// Pre-signed upload
 		key := path.Join(tempPath, c.NowPath(), fmt.Sprintf("%s_%d_%s.presigned", hash, size, c.UUID()))
-		result, err := c.impl.PresignedPutObject(ctx, key, expire, &s3.PutOption{ContentType: contentType})
+		result, err := c.impl.PresignedPutObject(ctx, key, expire, &s3.PutOption{ContentType: contentType, Encryption: enc})
 		if err != nil {
 			return nil, err
 		}
 		return &InitiateUploadResult{
 			UploadID: newMultipartUploadID(multipartUploadID{
-				Type: UploadTypePresigned,
-				ID:   "",
-				Key:  key,
-				Size: size,
-				Hash: hash,
+				Type:        UploadTypePresigned,
+				ID:          "",
+				Key:         key,
+				Size:        size,
+				Hash:        hash,
+				Expire:      time.Now().Add(expire).UnixMilli(),
+				ContentType: contentType,
+				Encryption:  encryptionID(enc),
 			}),
 			PartSize: partSize,
 			Sign: &s3.AuthSignResult{
@@ -142,10 +174,25 @@ func (c *Controller) InitiateUploadContentType(ctx context.Context, hash string,
 		}, nil
 	} else {
 		// Fragment upload
-		upload, err := c.impl.InitiateMultipartUpload(ctx, c.HashPath(hash), &s3.PutOption{ContentType: contentType})
+		key := c.HashPath(hash)
+		if c.verify {
+			// A verified upload is only published to HashPath once its content matches the hash.
+			key = path.Join(tempPath, c.NowPath(), fmt.Sprintf("%s_%d_%s.multipart", hash, size, c.UUID()))
+		}
+		upload, err := c.impl.InitiateMultipartUpload(ctx, key, &s3.PutOption{ContentType: contentType, Encryption: enc})
 		if err != nil {
 			return nil, err
 		}
+		if c.store != nil {
+			record := &UploadRecord{UploadID: upload.UploadID, Key: upload.Key, Expire: time.Now().Add(expire)}
+			if err := c.store.AddUpload(ctx, record); err != nil {
+				// An untracked upload would never be reaped, give it up right away.
+				if err := c.impl.AbortMultipartUpload(ctx, upload.UploadID, upload.Key); err != nil {
+					log.ZWarn(ctx, "abort untracked multipart upload", err, "uploadID", upload.UploadID, "key", upload.Key)
+				}
+				return nil, err
+			}
+		}
 		if maxParts < 0 {
 			maxParts = partNumber
 		}
@@ -155,18 +202,21 @@ func (c *Controller) InitiateUploadContentType(ctx context.Context, hash string,
 			for i := 0; i < maxParts; i++ {
 				partNumbers[i] = i + 1
 			}
-			authSign, err = c.impl.AuthSign(ctx, upload.UploadID, upload.Key, time.Hour*24, partNumbers)
+			authSign, err = c.authSign(ctx, upload.UploadID, upload.Key, time.Hour*24, partNumbers, enc)
 			if err != nil {
 				return nil, err
 			}
 		}
 		return &InitiateUploadResult{
 			UploadID: newMultipartUploadID(multipartUploadID{
-				Type: UploadTypeMultipart,
-				ID:   upload.UploadID,
-				Key:  upload.Key,
-				Size: size,
-				Hash: hash,
+				Type:        UploadTypeMultipart,
+				ID:          upload.UploadID,
+				Key:         upload.Key,
+				Size:        size,
+				Hash:        hash,
+				Expire:      time.Now().Add(expire).UnixMilli(),
+				ContentType: contentType,
+				Encryption:  encryptionID(enc),
 			}),
 			PartSize: partSize,
 			Sign:     authSign,
@@ -180,8 +230,12 @@ func (c *Controller) CompleteUpload(ctx context.Context, uploadID string, partHa
 	if err != nil {
 		return nil, err
 	}
-	if md5Sum := md5.Sum([]byte(strings.Join(partHashs, partSeparator))); hex.EncodeToString(md5Sum[:]) != upload.Hash {
-		return nil, errors.New("md5 mismatching")
+	// The SHA-256 of the content is only known once it is read back by the verification, which
+	// then must pass before the object is published.
+	if c.hashAlgorithm != HashSHA256 {
+		if md5Sum := md5.Sum([]byte(strings.Join(partHashs, partSeparator))); hex.EncodeToString(md5Sum[:]) != upload.Hash {
+			return nil, errors.New("md5 mismatching")
+		}
 	}
 	if info, err := c.StatObject(ctx, c.HashPath(upload.Hash)); err == nil {
 		return &UploadResult{
@@ -208,12 +262,30 @@ func (c *Controller) CompleteUpload(ctx context.Context, uploadID string, partHa
 				ETag:       part,
 			}
 		}
+		c.setPartHashs(ctx, upload, partHashs)
 		// todo: Validation size
 		result, err := c.impl.CompleteMultipartUpload(ctx, upload.ID, upload.Key, parts)
 		if err != nil {
 			return nil, err
 		}
+		if c.store != nil {
+			// A stale record is harmless, the reaper drops it when the upload is gone.
+			if err := c.store.DelUpload(ctx, upload.ID); err != nil {
+				log.ZWarn(ctx, "delete upload record", err, "uploadID", upload.ID)
+			}
+		}
 		targetKey = result.Key
+		if c.verify {
+			cleanObject[result.Key] = struct{}{}
+			if err := c.verifyObject(ctx, result.Key, upload); err != nil {
+				return nil, err
+			}
+			hashCopyInfo, err := c.impl.CopyObject(ctx, result.Key, c.HashPath(upload.Hash))
+			if err != nil {
+				return nil, err
+			}
+			targetKey = hashCopyInfo.Key
+		}
 	case UploadTypePresigned:
 		uploadInfo, err := c.StatObject(ctx, upload.Key)
 		if err != nil {
@@ -223,9 +295,13 @@ func (c *Controller) CompleteUpload(ctx context.Context, uploadID string, partHa
 		if uploadInfo.Size != upload.Size {
 			return nil, errors.New("upload size mismatching")
 		}
-		md5Sum := md5.Sum([]byte(strings.Join([]string{uploadInfo.ETag}, partSeparator)))
-		if md5val := hex.EncodeToString(md5Sum[:]); md5val != upload.Hash {
-			return nil, errs.ErrArgs.WrapMsg(fmt.Sprintf("md5 mismatching %s != %s", md5val, upload.Hash))
+		if c.hashAlgorithm != HashSHA256 {
+			md5Sum := md5.Sum([]byte(strings.Join([]string{uploadInfo.ETag}, partSeparator)))
+			if md5val := hex.EncodeToString(md5Sum[:]); md5val != upload.Hash {
+				return nil, errs.ErrArgs.WrapMsg(fmt.Sprintf("md5 mismatching %s != %s", md5val, upload.Hash))
+			}
+		} else if len(partHashs) != 1 || !strings.EqualFold(partHashs[0], uploadInfo.ETag) {
+			return nil, errs.ErrArgs.WrapMsg("md5 mismatching", "etag", uploadInfo.ETag, "partHashs", partHashs)
 		}
 		// Prevents concurrent operations at this time that cause files to be overwritten
 		copyInfo, err := c.impl.CopyObject(ctx, uploadInfo.Key, upload.Key+"."+c.UUID())
@@ -236,6 +312,11 @@ func (c *Controller) CompleteUpload(ctx context.Context, uploadID string, partHa
 		if copyInfo.ETag != uploadInfo.ETag {
 			return nil, errors.New("[concurrency]copy md5 mismatching")
 		}
+		if c.verify {
+			if err := c.verifyObject(ctx, copyInfo.Key, upload); err != nil {
+				return nil, err
+			}
+		}
 		hashCopyInfo, err := c.impl.CopyObject(ctx, copyInfo.Key, c.HashPath(upload.Hash))
 		if err != nil {
 			return nil, err
@@ -262,6 +343,9 @@ func (c *Controller) AuthSign(ctx context.Context, uploadID string, partNumbers
 	}
 	switch upload.Type {
 	case UploadTypeMultipart:
+		if upload.isSSEC() {
+			return nil, errs.ErrArgs.WrapMsg("SSE-C upload parts must be signed with AuthSignEncrypted")
+		}
 		return c.impl.AuthSign(ctx, upload.ID, upload.Key, time.Hour*24, partNumbers)
 	case UploadTypePresigned:
 		return nil, errors.New("presigned id not support auth sign")
@@ -270,6 +354,85 @@ func (c *Controller) AuthSign(ctx context.Context, uploadID string, partNumbers
 	}
 }
 
+// AuthSignParts signs the given parts of a multipart upload like AuthSign, and records their
+// hash by part number so that GetUploadStatus only reports the parts stored with that hash.
+func (c *Controller) AuthSignParts(ctx context.Context, uploadID string, partHashs map[int]string) (*s3.AuthSignResult, error) {
+	upload, err := parseMultipartUploadID(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Type != UploadTypeMultipart {
+		return c.AuthSign(ctx, uploadID, nil)
+	}
+	partNumbers := make([]int, 0, len(partHashs))
+	for partNumber := range partHashs {
+		partNumbers = append(partNumbers, partNumber)
+	}
+	sort.Ints(partNumbers)
+	if upload.isSSEC() {
+		return nil, errs.ErrArgs.WrapMsg("SSE-C upload parts must be signed with AuthSignEncrypted")
+	}
+	if c.store != nil {
+		if err := c.store.SetPartHashs(ctx, upload.ID, time.UnixMilli(upload.Expire), partHashs); err != nil {
+			return nil, err
+		}
+	}
+	return c.impl.AuthSign(ctx, upload.ID, upload.Key, time.Hour*24, partNumbers)
+}
+
+// AuthSignEncrypted signs the given parts of an SSE-C multipart upload with the customer key
+// it was initiated with.
+func (c *Controller) AuthSignEncrypted(ctx context.Context, uploadID string, partNumbers []int, enc *s3.Encryption) (*s3.AuthSignResult, error) {
+	upload, err := parseMultipartUploadID(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Type != UploadTypeMultipart || !upload.isSSEC() {
+		return c.AuthSign(ctx, uploadID, partNumbers)
+	}
+	if enc, err = (&s3.PutOption{Encryption: enc}).GetEncryption(); err != nil {
+		return nil, err
+	} else if enc == nil || enc.Type != s3.SSEC {
+		return nil, errs.ErrArgs.WrapMsg("SSE-C upload parts need the customer key")
+	}
+	return c.authSign(ctx, upload.ID, upload.Key, time.Hour*24, partNumbers, enc)
+}
+
+// authSign signs the parts of a multipart upload, together with the customer key of SSE-C ones.
+func (c *Controller) authSign(ctx context.Context, uploadID string, key string, expire time.Duration, partNumbers []int, enc *s3.Encryption) (*s3.AuthSignResult, error) {
+	if enc == nil || enc.Type != s3.SSEC {
+		return c.impl.AuthSign(ctx, uploadID, key, expire, partNumbers)
+	}
+	signer, ok := c.impl.(s3.EncryptedPartSigner)
+	if !ok {
+		return nil, s3.NotSupportedEncryption(c.impl.Engine(), enc)
+	}
+	return signer.AuthSignEncrypted(ctx, uploadID, key, expire, partNumbers, enc)
+}
+
+// encryptionID returns the encryption recorded in an upload id, without the SSE-C customer key.
+func encryptionID(enc *s3.Encryption) *s3.Encryption {
+	if enc == nil {
+		return nil
+	}
+	return &s3.Encryption{Type: enc.Type, KMSKeyID: enc.KMSKeyID}
+}
+
+// setPartHashs records the part hashs given to CompleteUpload, so that a failed completion can
+// be resumed from the parts matching them.
+func (c *Controller) setPartHashs(ctx context.Context, upload *multipartUploadID, partHashs []string) {
+	if c.store == nil {
+		return
+	}
+	hashs := make(map[int]string, len(partHashs))
+	for i, hash := range partHashs {
+		hashs[i+1] = hash
+	}
+	if err := c.store.SetPartHashs(ctx, upload.ID, time.UnixMilli(upload.Expire), hashs); err != nil {
+		log.ZWarn(ctx, "set part hashs", err, "uploadID", upload.ID)
+	}
+}
+
 func (c *Controller) IsNotFound(err error) bool {
 	return c.impl.IsNotFound(err) || errs.ErrRecordNotFound.Is(err)
 }
@@ -289,3 +452,44 @@ func (c *Controller) FormData(ctx context.Context, name string, size int64, cont
 func (c *Controller) DeleteObject(ctx context.Context, name string) error {
 	return c.impl.DeleteObject(ctx, name)
 }
+
+// SweepPrefix deletes the objects under prefix last modified before the given time
+// and returns how many were removed. It is meant for collecting orphaned uploads.
+func (c *Controller) SweepPrefix(ctx context.Context, prefix string, before time.Time) (int, error) {
+	var (
+		token   string
+		deleted int
+	)
+	for {
+		res, err := c.impl.ListObjects(ctx, prefix, token, 0)
+		if err != nil {
+			return deleted, err
+		}
+		names := make([]string, 0, len(res.Objects))
+		for _, object := range res.Objects {
+			// Some backends may not report a modification time, never treat those as expired.
+			if !object.LastModified.IsZero() && object.LastModified.Before(before) {
+				names = append(names, object.Key)
+			}
+		}
+		if len(names) > 0 {
+			if err := c.impl.DeleteObjects(ctx, names); err != nil {
+				return deleted, err
+			}
+			if err := c.cache.DelS3Key(ctx, c.impl.Engine(), names...); err != nil {
+				return deleted, err
+			}
+			deleted += len(names)
+			log.ZDebug(ctx, "sweep prefix deleted objects", "prefix", prefix, "count", len(names))
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return deleted, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// SweepTemp deletes presigned uploads that were never completed before the given time.
+func (c *Controller) SweepTemp(ctx context.Context, before time.Time) (int, error) {
+	return c.SweepPrefix(ctx, tempPath, before)
+}
@@ -0,0 +1,105 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/cont"
+	"github.com/smartim/tools/s3/local"
+	"github.com/smartim/tools/s3/local/localtest"
+)
+
+// encryptedLocal records the encryption the controller asks the backend for, and stores the
+// objects in plain, which the local backend only supports.
+type encryptedLocal struct {
+	*local.Local
+	presigned []*s3.Encryption
+	initiated []*s3.Encryption
+	signed    []*s3.Encryption
+}
+
+var _ s3.EncryptedPartSigner = (*encryptedLocal)(nil)
+
+func (e *encryptedLocal) PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *s3.PutOption) (*s3.PresignedPutResult, error) {
+	e.presigned = append(e.presigned, opt.Encryption)
+	return e.Local.PresignedPutObject(ctx, name, expire, &s3.PutOption{ContentType: opt.ContentType})
+}
+
+func (e *encryptedLocal) InitiateMultipartUpload(ctx context.Context, name string, opt *s3.PutOption) (*s3.InitiateMultipartUploadResult, error) {
+	e.initiated = append(e.initiated, opt.Encryption)
+	return e.Local.InitiateMultipartUpload(ctx, name, &s3.PutOption{ContentType: opt.ContentType})
+}
+
+func (e *encryptedLocal) AuthSignEncrypted(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int, enc *s3.Encryption) (*s3.AuthSignResult, error) {
+	e.signed = append(e.signed, enc)
+	return e.Local.AuthSign(ctx, uploadID, name, expire, partNumbers)
+}
+
+func TestUploadEncryption(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	impl := &encryptedLocal{Local: l}
+	ctl := cont.New(localtest.DirectCache{Impl: impl}, impl)
+	data := []byte("encrypted")
+	hash := localtest.MD5Hex([]byte(localtest.MD5Hex(data)))
+
+	kms := &s3.Encryption{Type: s3.SSEKMS, KMSKeyID: "tenant"}
+	res, err := ctl.InitiateUploadWithReq(ctx, &cont.InitiateUploadReq{Hash: hash, Size: int64(len(data)), Expire: time.Hour, MaxParts: -1, Encryption: kms})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The presigned upload is signed again with the same encryption when resumed.
+	if _, err := ctl.GetUploadStatus(ctx, res.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	if len(impl.presigned) != 2 || impl.presigned[0] != kms || impl.presigned[1].Type != kms.Type || impl.presigned[1].KMSKeyID != kms.KMSKeyID {
+		t.Fatalf("unexpected presigned encryption %+v", impl.presigned)
+	}
+
+	sseC := &s3.Encryption{Type: s3.SSEC, CustomerKey: bytes.Repeat([]byte{'k'}, 32)}
+	res, err = ctl.InitiateUploadWithReq(ctx, &cont.InitiateUploadReq{Hash: hash, Size: int64(len(data)), Expire: time.Hour, MaxParts: -1, Encryption: sseC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(impl.presigned) != 2 || len(impl.initiated) != 1 || impl.initiated[0] != sseC {
+		t.Fatalf("SSE-C upload not multipart, presigned %+v initiated %+v", impl.presigned, impl.initiated)
+	}
+	if len(impl.signed) != 1 || impl.signed[0] != sseC {
+		t.Fatalf("unexpected signed encryption %+v", impl.signed)
+	}
+	if _, err := ctl.AuthSign(ctx, res.UploadID, []int{1}); !errors.Is(err, errs.ErrArgs) {
+		t.Fatalf("SSE-C parts signed without the key: %v", err)
+	}
+	sign, err := ctl.AuthSignEncrypted(ctx, res.UploadID, []int{1}, sseC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localtest.UploadParts(t, sign, [][]byte{data})
+	if _, err := ctl.CompleteUpload(ctx, res.UploadID, []string{localtest.MD5Hex(data)}); err != nil {
+		t.Fatal(err)
+	}
+
+	verified := cont.New(localtest.DirectCache{Impl: impl}, impl, cont.WithVerification())
+	if _, err := verified.InitiateUploadWithReq(ctx, &cont.InitiateUploadReq{Hash: hash, Size: int64(len(data)), Expire: time.Hour, MaxParts: -1, Encryption: sseC}); !errors.Is(err, errs.ErrArgs) {
+		t.Fatalf("SSE-C upload verified: %v", err)
+	}
+}
@@ -27,3 +27,20 @@ type HashAlreadyExistsError struct {
 func (e *HashAlreadyExistsError) Error() string {
 	return fmt.Sprintf("hash already exists: %s", e.Object.Key)
 }
+
+// HashMismatchError is returned by CompleteUpload when the verified content of an upload does
+// not match the declared hash. The object has been deleted.
+type HashMismatchError struct {
+	// Key is the deleted object.
+	Key string
+	// Algorithm is HashMD5 or HashSHA256.
+	Algorithm string
+	// Expected is the declared hash.
+	Expected string
+	// Actual is the hash of the stored content.
+	Actual string
+}
+
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("%s mismatching %s != %s: %s", e.Algorithm, e.Actual, e.Expected, e.Key)
+}
@@ -18,6 +18,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+
+	"github.com/smartim/tools/s3"
 )
 
 type multipartUploadID struct {
@@ -26,6 +28,11 @@ type multipartUploadID struct {
 	Key  string `json:"c,omitempty"`
 	Size int64  `json:"d,omitempty"`
 	Hash string `json:"e,omitempty"`
+	// Expire is the session expiry in unix milliseconds, absent from ids issued by older versions.
+	Expire      int64  `json:"f,omitempty"`
+	ContentType string `json:"g,omitempty"`
+	// Encryption is the encryption the upload was initiated with, without the SSE-C customer key.
+	Encryption *s3.Encryption `json:"h,omitempty"`
 }
 
 func newMultipartUploadID(id multipartUploadID) string {
@@ -36,6 +43,11 @@ func newMultipartUploadID(id multipartUploadID) string {
 	return base64.StdEncoding.EncodeToString(data)
 }
 
+// isSSEC reports whether the parts of the upload must be sent with an SSE-C customer key.
+func (u *multipartUploadID) isSSEC() bool {
+	return u.Encryption != nil && u.Encryption.Type == s3.SSEC
+}
+
 func parseMultipartUploadID(id string) (*multipartUploadID, error) {
 	data, err := base64.StdEncoding.DecodeString(id)
 	if err != nil {
@@ -0,0 +1,113 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smartim/tools/log"
+)
+
+const (
+	// reapBatchSize is the number of expired uploads fetched from the store at a time.
+	reapBatchSize = 100
+
+	// reapRetryDelay postpones uploads that could not be aborted to a later round.
+	reapRetryDelay = time.Minute * 10
+)
+
+// Option configures a Controller.
+type Option func(*Controller)
+
+// WithUploadStore makes the Controller record the multipart uploads it starts in store,
+// which allows ReapUploads to abort the ones that are never completed, and GetUploadStatus to
+// check the stored parts against the part hashes given to AuthSignParts and CompleteUpload.
+func WithUploadStore(store UploadStore) Option {
+	return func(c *Controller) {
+		c.store = store
+	}
+}
+
+// ReapResult reports the outcome of a ReapUploads round.
+type ReapResult struct {
+	// Aborted is the number of expired uploads aborted.
+	Aborted int `json:"aborted"`
+	// Failed is the number of expired uploads that could not be aborted, they are retried later.
+	Failed int `json:"failed"`
+}
+
+// ReapUploads aborts the tracked multipart uploads that expired before the given time.
+// Uploads that no longer exist on the backend are dropped from the store without being counted.
+func (c *Controller) ReapUploads(ctx context.Context, before time.Time) (*ReapResult, error) {
+	if c.store == nil {
+		return nil, errors.New("upload store not configured")
+	}
+	var res ReapResult
+	retry := before
+	if now := time.Now(); now.After(retry) {
+		retry = now
+	}
+	retry = retry.Add(reapRetryDelay)
+	for {
+		records, err := c.store.ExpiredUploads(ctx, before, reapBatchSize)
+		if err != nil {
+			return &res, err
+		}
+		for _, record := range records {
+			if err := c.impl.AbortMultipartUpload(ctx, record.UploadID, record.Key); err == nil {
+				res.Aborted++
+			} else if !c.impl.IsNotFound(err) {
+				res.Failed++
+				log.ZWarn(ctx, "abort expired multipart upload", err, "uploadID", record.UploadID, "key", record.Key)
+				record.Expire = retry
+				if err := c.store.AddUpload(ctx, record); err != nil {
+					return &res, err
+				}
+				continue
+			}
+			if err := c.store.DelUpload(ctx, record.UploadID); err != nil {
+				return &res, err
+			}
+		}
+		if len(records) < reapBatchSize {
+			return &res, nil
+		}
+	}
+}
+
+// RunUploadReaper calls ReapUploads for the uploads expired by then every interval until ctx is done.
+// report, if not nil, receives the result of every round.
+func (c *Controller) RunUploadReaper(ctx context.Context, interval time.Duration, report func(res *ReapResult, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := c.ReapUploads(ctx, time.Now())
+			if err != nil {
+				log.ZError(ctx, "reap expired multipart uploads", err)
+			} else if res.Aborted > 0 || res.Failed > 0 {
+				log.ZInfo(ctx, "reap expired multipart uploads", "aborted", res.Aborted, "failed", res.Failed)
+			}
+			if report != nil {
+				report(res, err)
+			}
+		}
+	}
+}
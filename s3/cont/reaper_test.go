@@ -0,0 +1,71 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/s3/cont"
+	"github.com/smartim/tools/s3/local/localtest"
+)
+
+func TestUploadReaper(t *testing.T) {
+	ctx := context.Background()
+	l, root := localtest.NewServer(t)
+	ctl := cont.New(localtest.DirectCache{Impl: l}, l, cont.WithUploadStore(cont.NewMemoryUploadStore()))
+	initiate := func(data []byte, expire time.Duration) (*cont.InitiateUploadResult, []string) {
+		parts := [][]byte{data[:minPartSize], data[minPartSize:]}
+		partHashs := []string{localtest.MD5Hex(parts[0]), localtest.MD5Hex(parts[1])}
+		res, err := ctl.InitiateUpload(ctx, localtest.MD5Hex([]byte(strings.Join(partHashs, ","))), int64(len(data)), expire, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		localtest.UploadParts(t, res.Sign, parts)
+		return res, partHashs
+	}
+	completed, partHashs := initiate(append(bytes.Repeat([]byte{'a'}, int(minPartSize)), 'a'), time.Minute)
+	if _, err := ctl.CompleteUpload(ctx, completed.UploadID, partHashs); err != nil {
+		t.Fatal(err)
+	}
+	initiate(append(bytes.Repeat([]byte{'b'}, int(minPartSize)), 'b'), time.Minute)
+	initiate(append(bytes.Repeat([]byte{'c'}, int(minPartSize)), 'c'), time.Hour)
+	res, err := ctl.ReapUploads(ctx, time.Now().Add(time.Minute*2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Aborted != 1 || res.Failed != 0 {
+		t.Fatalf("unexpected reap result %+v", res)
+	}
+	res, err = ctl.ReapUploads(ctx, time.Now().Add(time.Hour*2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Aborted != 1 || res.Failed != 0 {
+		t.Fatalf("unexpected reap result %+v", res)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "multipart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("%d multipart uploads left", len(entries))
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/smartim/tools/s3"
+)
+
+const (
+	// listPartsLimit is the page size used to list the uploaded parts.
+	listPartsLimit = 1000
+
+	// signExpire is the validity of part signatures, as in InitiateUpload.
+	signExpire = time.Hour * 24
+)
+
+// GetUploadStatus returns the progress of an upload so that an interrupted client can resume it.
+// The parts stored by the backend are reported with their hash, the ETag, and only the missing
+// parts are signed again, for no longer than the session lasts. A part is only done if its ETag
+// matches the hash recorded by AuthSignParts or CompleteUpload, when an UploadStore is set, and
+// a presigned upload if its ETag matches the MD5 of the upload.
+func (c *Controller) GetUploadStatus(ctx context.Context, uploadID string) (*UploadStatus, error) {
+	upload, err := parseMultipartUploadID(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	partSize, err := c.impl.PartSize(ctx, upload.Size)
+	if err != nil {
+		return nil, err
+	}
+	status := &UploadStatus{
+		UploadID: uploadID,
+		Size:     upload.Size,
+		PartSize: partSize,
+		Done:     []s3.UploadedPart{},
+		Missing:  []int{},
+	}
+	expire := signExpire
+	if upload.Expire > 0 {
+		status.Expire = time.UnixMilli(upload.Expire)
+		expire = min(expire, time.Until(status.Expire))
+	}
+	switch upload.Type {
+	case UploadTypeMultipart:
+		status.PartNumber = int(upload.Size / partSize)
+		if upload.Size%partSize > 0 {
+			status.PartNumber++
+		}
+		uploaded, err := c.uploadedParts(ctx, upload)
+		if err != nil {
+			return nil, err
+		}
+		var partHashs map[int]string
+		if c.store != nil {
+			if partHashs, err = c.store.PartHashs(ctx, upload.ID); err != nil {
+				return nil, err
+			}
+		}
+		for partNumber := 1; partNumber <= status.PartNumber; partNumber++ {
+			size := partSize
+			if partNumber == status.PartNumber && upload.Size%partSize > 0 {
+				size = upload.Size % partSize
+			}
+			// A part of an unexpected size or hash was written by an older attempt and must be sent again.
+			if part, ok := uploaded[partNumber]; ok && part.Size == size && matchETag(part.ETag, partHashs[partNumber]) {
+				status.Done = append(status.Done, part)
+			} else {
+				status.Missing = append(status.Missing, partNumber)
+			}
+		}
+		// The parts of an SSE-C upload are signed with the customer key by AuthSignEncrypted.
+		if len(status.Missing) > 0 && expire > 0 && !upload.isSSEC() {
+			status.Sign, err = c.impl.AuthSign(ctx, upload.ID, upload.Key, expire, status.Missing)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case UploadTypePresigned:
+		status.PartNumber = 1
+		info, err := c.impl.StatObject(ctx, upload.Key)
+		if err == nil && info.Size == upload.Size && c.matchPresigned(info.ETag, upload.Hash) {
+			status.Done = append(status.Done, s3.UploadedPart{
+				PartNumber:   1,
+				LastModified: info.LastModified,
+				ETag:         info.ETag,
+				Size:         info.Size,
+			})
+			return status, nil
+		} else if err != nil && !c.impl.IsNotFound(err) {
+			return nil, err
+		}
+		status.Missing = append(status.Missing, 1)
+		if expire > 0 {
+			result, err := c.impl.PresignedPutObject(ctx, upload.Key, expire, &s3.PutOption{ContentType: upload.ContentType, Encryption: upload.Encryption})
+			if err != nil {
+				return nil, err
+			}
+			status.Sign = &s3.AuthSignResult{
+				Parts: []s3.SignPart{
+					{
+						PartNumber: 1,
+						URL:        result.URL,
+						Header:     result.Header,
+					},
+				},
+			}
+		}
+	default:
+		return nil, errors.New("invalid upload id type")
+	}
+	return status, nil
+}
+
+// uploadedParts lists every part of a multipart upload stored by the backend, by part number.
+func (c *Controller) uploadedParts(ctx context.Context, upload *multipartUploadID) (map[int]s3.UploadedPart, error) {
+	parts := make(map[int]s3.UploadedPart)
+	var marker int
+	for {
+		res, err := c.impl.ListUploadedParts(ctx, upload.ID, upload.Key, marker, listPartsLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range res.UploadedParts {
+			parts[part.PartNumber] = part
+		}
+		if len(res.UploadedParts) < listPartsLimit || res.NextPartNumberMarker <= marker {
+			return parts, nil
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+// matchETag reports whether etag is the expected hash, any etag matches when none is expected.
+func matchETag(etag string, hash string) bool {
+	return hash == "" || strings.EqualFold(strings.Trim(etag, `"`), hash)
+}
+
+// matchPresigned reports whether etag is the one of the presigned upload with the given hash,
+// which can only be checked for HashMD5.
+func (c *Controller) matchPresigned(etag string, hash string) bool {
+	if c.hashAlgorithm == HashSHA256 {
+		return true
+	}
+	md5Sum := md5.Sum([]byte(strings.Trim(etag, `"`)))
+	return hex.EncodeToString(md5Sum[:]) == hash
+}
@@ -0,0 +1,100 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/cont"
+	"github.com/smartim/tools/s3/local/localtest"
+)
+
+func TestUploadStatus(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	ctl := cont.New(localtest.DirectCache{Impl: l}, l, cont.WithUploadStore(cont.NewMemoryUploadStore()))
+	parts := [][]byte{bytes.Repeat([]byte{'a'}, int(minPartSize)), bytes.Repeat([]byte{'b'}, int(minPartSize)), []byte("tail")}
+	partHashs := []string{localtest.MD5Hex(parts[0]), localtest.MD5Hex(parts[1]), localtest.MD5Hex(parts[2])}
+	hash := localtest.MD5Hex([]byte(strings.Join(partHashs, ",")))
+	res, err := ctl.InitiateUpload(ctx, hash, minPartSize*2+4, time.Hour, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The connection is lost after the first and the last part.
+	localtest.UploadParts(t, &s3.AuthSignResult{URL: res.Sign.URL, Query: res.Sign.Query, Header: res.Sign.Header,
+		Parts: []s3.SignPart{res.Sign.Parts[0], res.Sign.Parts[2]}}, [][]byte{parts[0], parts[2]})
+	status, err := ctl.GetUploadStatus(ctx, res.UploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PartNumber != 3 || len(status.Done) != 2 || len(status.Missing) != 1 || status.Missing[0] != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if etag := strings.Trim(status.Done[1].ETag, `"`); status.Done[1].PartNumber != 3 || etag != partHashs[2] {
+		t.Fatalf("unexpected done part %+v", status.Done[1])
+	}
+	if until := time.Until(status.Expire); until <= 0 || until > time.Hour {
+		t.Fatalf("unexpected expire %s", status.Expire)
+	}
+	if status.Sign == nil || len(status.Sign.Parts) != 1 || status.Sign.Parts[0].PartNumber != 2 {
+		t.Fatalf("unexpected sign %+v", status.Sign)
+	}
+	// A part of the right size but another content is not done.
+	sign, err := ctl.AuthSignParts(ctx, res.UploadID, map[int]string{2: partHashs[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	localtest.UploadParts(t, sign, [][]byte{bytes.Repeat([]byte{'c'}, int(minPartSize))})
+	if status, err = ctl.GetUploadStatus(ctx, res.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Missing) != 1 || status.Missing[0] != 2 {
+		t.Fatalf("unexpected status with a corrupted part %+v", status)
+	}
+	localtest.UploadParts(t, status.Sign, [][]byte{parts[1]})
+	if status, err = ctl.GetUploadStatus(ctx, res.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Missing) != 0 || status.Sign != nil {
+		t.Fatalf("unexpected status after resume %+v", status)
+	}
+	if _, err := ctl.CompleteUpload(ctx, res.UploadID, partHashs); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("small object")
+	res, err = ctl.InitiateUpload(ctx, localtest.MD5Hex([]byte(localtest.MD5Hex(data))), int64(len(data)), time.Hour, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err = ctl.GetUploadStatus(ctx, res.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Missing) != 1 || status.Sign == nil {
+		t.Fatalf("unexpected presigned status %+v", status)
+	}
+	localtest.UploadParts(t, status.Sign, [][]byte{data})
+	if status, err = ctl.GetUploadStatus(ctx, res.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Done) != 1 || len(status.Missing) != 0 {
+		t.Fatalf("unexpected presigned status after upload %+v", status)
+	}
+}
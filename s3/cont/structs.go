@@ -14,7 +14,32 @@
 
 package cont
 
-import "github.com/smartim/tools/s3"
+import (
+	"time"
+
+	"github.com/smartim/tools/s3"
+)
+
+type InitiateUploadReq struct {
+	// Hash is the hex encoded hash of the whole object, the object is stored under HashPath(Hash).
+	Hash string `json:"hash"`
+
+	// Size is the size of the whole object.
+	Size int64 `json:"size"`
+
+	// Expire is how long the upload session lasts.
+	Expire time.Duration `json:"expire"`
+
+	// MaxParts limits the number of parts signed right away, -1 signs them all.
+	MaxParts int `json:"maxParts"`
+
+	// ContentType is stored with the object.
+	ContentType string `json:"contentType"`
+
+	// Encryption, if not nil, encrypts the object at rest. The parts of an SSE-C upload are signed
+	// with the customer key, which the client must send with every part.
+	Encryption *s3.Encryption `json:"encryption"`
+}
 
 type InitiateUploadResult struct {
 	// UploadID uniquely identifies the upload session for tracking and management purposes.
@@ -32,3 +57,29 @@ type UploadResult struct {
 	Size int64  `json:"size"`
 	Key  string `json:"key"`
 }
+
+type UploadStatus struct {
+	// UploadID is the upload the status describes.
+	UploadID string `json:"uploadID"`
+
+	// Size is the declared size of the whole object.
+	Size int64 `json:"size"`
+
+	// PartSize is the size of every part but the last one.
+	PartSize int64 `json:"partSize"`
+
+	// PartNumber is the total number of parts of the upload.
+	PartNumber int `json:"partNumber"`
+
+	// Done lists the parts stored by the backend with their hash, which clients can compare with their own.
+	Done []s3.UploadedPart `json:"done"`
+
+	// Missing lists the numbers of the parts still to upload.
+	Missing []int `json:"missing"`
+
+	// Expire is when the upload session expires, zero if it is not known.
+	Expire time.Time `json:"expire"`
+
+	// Sign contains the signatures of the missing parts only, nil when none is missing.
+	Sign *s3.AuthSignResult `json:"sign"`
+}
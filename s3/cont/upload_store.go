@@ -0,0 +1,255 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/smartim/tools/errs"
+)
+
+// UploadRecord describes a multipart upload started by the Controller that has not completed yet.
+type UploadRecord struct {
+	// UploadID is the upload id assigned by the storage backend.
+	UploadID string `json:"uploadID"`
+	// Key is the object key the upload writes to.
+	Key string `json:"key"`
+	// Expire is the time after which the upload is considered abandoned.
+	Expire time.Time `json:"expire"`
+}
+
+// UploadStore keeps track of in-flight multipart uploads so that abandoned ones can be aborted.
+type UploadStore interface {
+	// AddUpload saves the record, replacing any record with the same upload id.
+	AddUpload(ctx context.Context, record *UploadRecord) error
+	// DelUpload removes the record, deleting a missing record is not an error.
+	DelUpload(ctx context.Context, uploadID string) error
+	// ExpiredUploads returns at most limit records that expired before the given time, oldest first.
+	ExpiredUploads(ctx context.Context, before time.Time, limit int) ([]*UploadRecord, error)
+	// SetPartHashs saves the expected hash of the given parts of an upload, kept until expire
+	// or until DelUpload.
+	SetPartHashs(ctx context.Context, uploadID string, expire time.Time, partHashs map[int]string) error
+	// PartHashs returns the hashes saved by SetPartHashs by part number, empty if there is none.
+	PartHashs(ctx context.Context, uploadID string) (map[int]string, error)
+}
+
+// NewMemoryUploadStore returns an UploadStore that keeps records in process memory.
+// It is only suitable for a single instance, records are lost on restart.
+func NewMemoryUploadStore() UploadStore {
+	return &memoryUploadStore{
+		records:   make(map[string]UploadRecord),
+		partHashs: make(map[string]map[int]string),
+	}
+}
+
+type memoryUploadStore struct {
+	lock      sync.Mutex
+	records   map[string]UploadRecord
+	partHashs map[string]map[int]string
+}
+
+func (m *memoryUploadStore) AddUpload(ctx context.Context, record *UploadRecord) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.records[record.UploadID] = *record
+	return nil
+}
+
+func (m *memoryUploadStore) DelUpload(ctx context.Context, uploadID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.records, uploadID)
+	delete(m.partHashs, uploadID)
+	return nil
+}
+
+func (m *memoryUploadStore) ExpiredUploads(ctx context.Context, before time.Time, limit int) ([]*UploadRecord, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var res []*UploadRecord
+	for _, record := range m.records {
+		if record.Expire.Before(before) {
+			record := record
+			res = append(res, &record)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Expire.Before(res[j].Expire)
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (m *memoryUploadStore) SetPartHashs(ctx context.Context, uploadID string, expire time.Time, partHashs map[int]string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	hashs, ok := m.partHashs[uploadID]
+	if !ok {
+		hashs = make(map[int]string, len(partHashs))
+		m.partHashs[uploadID] = hashs
+	}
+	for partNumber, hash := range partHashs {
+		hashs[partNumber] = hash
+	}
+	return nil
+}
+
+func (m *memoryUploadStore) PartHashs(ctx context.Context, uploadID string) (map[int]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	res := make(map[int]string, len(m.partHashs[uploadID]))
+	for partNumber, hash := range m.partHashs[uploadID] {
+		res[partNumber] = hash
+	}
+	return res, nil
+}
+
+// NewRedisUploadStore returns an UploadStore backed by Redis, so that any instance can reap
+// uploads started by another. Records are indexed by expiry in the sorted set prefix+":expire"
+// and stored as JSON in the hash prefix+":data". The part hashes of an upload are stored in the
+// hash prefix+":parts:"+uploadID, which expires with the upload.
+func NewRedisUploadStore(client redis.UniversalClient, prefix string) UploadStore {
+	if prefix == "" {
+		prefix = "s3:upload"
+	}
+	return &redisUploadStore{
+		client:      client,
+		zsetKey:     prefix + ":expire",
+		dataKey:     prefix + ":data",
+		partsPrefix: prefix + ":parts:",
+	}
+}
+
+type redisUploadStore struct {
+	client      redis.UniversalClient
+	zsetKey     string
+	dataKey     string
+	partsPrefix string
+}
+
+func (r *redisUploadStore) AddUpload(ctx context.Context, record *UploadRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, r.dataKey, record.UploadID, data)
+	pipe.ZAdd(ctx, r.zsetKey, redis.Z{Score: float64(record.Expire.UnixMilli()), Member: record.UploadID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errs.WrapMsg(err, "failed to add upload record", "uploadID", record.UploadID)
+	}
+	return nil
+}
+
+func (r *redisUploadStore) DelUpload(ctx context.Context, uploadID string) error {
+	pipe := r.client.TxPipeline()
+	pipe.ZRem(ctx, r.zsetKey, uploadID)
+	pipe.HDel(ctx, r.dataKey, uploadID)
+	pipe.Del(ctx, r.partsPrefix+uploadID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errs.WrapMsg(err, "failed to delete upload record", "uploadID", uploadID)
+	}
+	return nil
+}
+
+func (r *redisUploadStore) ExpiredUploads(ctx context.Context, before time.Time, limit int) ([]*UploadRecord, error) {
+	var res []*UploadRecord
+	for {
+		// The records already read are still indexed, they are skipped.
+		var offset, count int
+		if limit > 0 {
+			offset, count = len(res), limit-len(res)
+		}
+		ids, err := r.client.ZRangeByScore(ctx, r.zsetKey, &redis.ZRangeBy{
+			Min:    "-inf",
+			Max:    "(" + strconv.FormatInt(before.UnixMilli(), 10),
+			Offset: int64(offset),
+			Count:  int64(count),
+		}).Result()
+		if err != nil {
+			return nil, errs.WrapMsg(err, "failed to get expired uploads")
+		}
+		if len(ids) == 0 {
+			return res, nil
+		}
+		values, err := r.client.HMGet(ctx, r.dataKey, ids...).Result()
+		if err != nil {
+			return nil, errs.WrapMsg(err, "failed to get upload records")
+		}
+		var lost int
+		for i, value := range values {
+			str, ok := value.(string)
+			if !ok {
+				// The data was lost, the upload can no longer be aborted.
+				if err := r.client.ZRem(ctx, r.zsetKey, ids[i]).Err(); err != nil {
+					return nil, errs.WrapMsg(err, "failed to delete lost upload record", "uploadID", ids[i])
+				}
+				lost++
+				continue
+			}
+			var record UploadRecord
+			if err := json.Unmarshal([]byte(str), &record); err != nil {
+				return nil, errs.WrapMsg(err, "failed to unmarshal upload record", "uploadID", ids[i])
+			}
+			res = append(res, &record)
+		}
+		// The lost records are out of the index, fetch others in their place.
+		if lost == 0 || limit <= 0 {
+			return res, nil
+		}
+	}
+}
+
+func (r *redisUploadStore) SetPartHashs(ctx context.Context, uploadID string, expire time.Time, partHashs map[int]string) error {
+	if len(partHashs) == 0 {
+		return nil
+	}
+	values := make(map[string]any, len(partHashs))
+	for partNumber, hash := range partHashs {
+		values[strconv.Itoa(partNumber)] = hash
+	}
+	key := r.partsPrefix + uploadID
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, values)
+	pipe.PExpireAt(ctx, key, expire)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errs.WrapMsg(err, "failed to set part hashs", "uploadID", uploadID)
+	}
+	return nil
+}
+
+func (r *redisUploadStore) PartHashs(ctx context.Context, uploadID string) (map[int]string, error) {
+	values, err := r.client.HGetAll(ctx, r.partsPrefix+uploadID).Result()
+	if err != nil {
+		return nil, errs.WrapMsg(err, "failed to get part hashs", "uploadID", uploadID)
+	}
+	res := make(map[int]string, len(values))
+	for field, hash := range values {
+		partNumber, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "invalid part number", "uploadID", uploadID, "field", field)
+		}
+		res[partNumber] = hash
+	}
+	return res, nil
+}
@@ -0,0 +1,95 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/smartim/tools/s3/cont"
+)
+
+func TestRedisUploadStore(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer client.Close()
+	store := cont.NewRedisUploadStore(client, "test:upload")
+
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c", "d"} {
+		record := &cont.UploadRecord{UploadID: id, Key: "key/" + id, Expire: now.Add(time.Duration(i) * time.Minute)}
+		if err := store.AddUpload(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired := func(before time.Time, limit int) []string {
+		records, err := store.ExpiredUploads(ctx, before, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(records))
+		for _, record := range records {
+			ids = append(ids, record.UploadID)
+		}
+		return ids
+	}
+	// The oldest first, at most limit of them, only those expired strictly before.
+	if ids := expired(now.Add(time.Minute*2), 0); len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Fatalf("expired %v", ids)
+	}
+	if ids := expired(now.Add(time.Hour), 3); len(ids) != 3 || ids[0] != "a" || ids[2] != "c" {
+		t.Fatalf("expired with limit %v", ids)
+	}
+	if err := store.DelUpload(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DelUpload(ctx, "missing"); err != nil {
+		t.Fatal(err)
+	}
+	// A record whose data was lost is dropped from the index, and does not count in the limit.
+	if err := client.HDel(ctx, "test:upload:data", "b").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := expired(now.Add(time.Hour), 2); len(ids) != 2 || ids[0] != "c" || ids[1] != "d" {
+		t.Fatalf("expired with lost data %v", ids)
+	}
+	if n := client.ZCard(ctx, "test:upload:expire").Val(); n != 2 {
+		t.Fatalf("%d records indexed, want 2", n)
+	}
+
+	// Part hashes are merged, and deleted with the record.
+	if err := store.SetPartHashs(ctx, "c", now.Add(time.Hour), map[int]string{1: "h1", 2: "h2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetPartHashs(ctx, "c", now.Add(time.Hour), map[int]string{2: "h2b"}); err != nil {
+		t.Fatal(err)
+	}
+	if hashs, err := store.PartHashs(ctx, "c"); err != nil || len(hashs) != 2 || hashs[1] != "h1" || hashs[2] != "h2b" {
+		t.Fatalf("unexpected part hashs %v: %v", hashs, err)
+	}
+	if ttl := srv.TTL("test:upload:parts:c"); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("unexpected part hashs ttl %s", ttl)
+	}
+	if err := store.DelUpload(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if hashs, err := store.PartHashs(ctx, "c"); err != nil || len(hashs) != 0 {
+		t.Fatalf("part hashs not deleted %v: %v", hashs, err)
+	}
+}
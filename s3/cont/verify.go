@@ -0,0 +1,128 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/s3"
+)
+
+const (
+	// HashMD5 is the default dedupe key: the MD5 of the comma separated MD5 of every part.
+	HashMD5 = "md5"
+	// HashSHA256 uses the SHA-256 of the whole content as the dedupe key.
+	HashSHA256 = "sha256"
+)
+
+// WithVerification makes CompleteUpload read the completed object back and check it against
+// the declared hash before publishing it to HashPath, multipart uploads are then made to a
+// temporary key and copied once verified. A mismatching object is deleted and a
+// *HashMismatchError returned. The storage backend must implement s3.ObjectStream.
+func WithVerification() Option {
+	return func(c *Controller) {
+		c.verify = true
+	}
+}
+
+// WithHashAlgorithm sets the hash used as the dedupe key in HashPath, HashMD5 by default.
+// With HashSHA256 the hash passed to InitiateUpload is the SHA-256 of the content, which can
+// only be checked by reading it, so verification is always enabled.
+func WithHashAlgorithm(algorithm string) Option {
+	return func(c *Controller) {
+		c.hashAlgorithm = algorithm
+		if algorithm == HashSHA256 {
+			c.verify = true
+		}
+	}
+}
+
+// hashSize returns the byte length of the dedupe hash.
+func (c *Controller) hashSize() int {
+	if c.hashAlgorithm == HashSHA256 {
+		return sha256.Size
+	}
+	return md5.Size
+}
+
+// verifyObject reads the named object and returns a *HashMismatchError if its content does
+// not match the hash of the upload. The MD5 is computed over every part of partSize bytes.
+func (c *Controller) verifyObject(ctx context.Context, name string, upload *multipartUploadID) error {
+	stream, ok := c.impl.(s3.ObjectStream)
+	if !ok {
+		return errs.ErrArgs.WrapMsg("s3 engine does not support verification", "engine", c.impl.Engine())
+	}
+	reader, err := stream.GetObject(ctx, name, nil)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	var actual string
+	if c.hashAlgorithm == HashSHA256 {
+		h := sha256.New()
+		if _, err := io.Copy(h, reader); err != nil {
+			return errs.WrapMsg(err, "read object failed", "name", name)
+		}
+		actual = hex.EncodeToString(h.Sum(nil))
+	} else {
+		partSize, err := c.impl.PartSize(ctx, upload.Size)
+		if err != nil {
+			return err
+		}
+		var partHashs []string
+		for {
+			h := md5.New()
+			n, err := io.CopyN(h, reader, partSize)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return errs.WrapMsg(err, "read object failed", "name", name)
+			}
+			if n == 0 && len(partHashs) > 0 {
+				break
+			}
+			partHashs = append(partHashs, hex.EncodeToString(h.Sum(nil)))
+			if n < partSize {
+				break
+			}
+		}
+		md5Sum := md5.Sum([]byte(strings.Join(partHashs, partSeparator)))
+		actual = hex.EncodeToString(md5Sum[:])
+	}
+	if strings.EqualFold(actual, upload.Hash) {
+		return nil
+	}
+	log.ZWarn(ctx, "uploaded object hash mismatching", nil, "name", name, "expected", upload.Hash, "actual", actual)
+	if err := c.impl.DeleteObject(ctx, name); err != nil {
+		return err
+	}
+	if err := c.cache.DelS3Key(ctx, c.impl.Engine(), name); err != nil {
+		log.ZWarn(ctx, "delete s3 key cache", err, "name", name)
+	}
+	return &HashMismatchError{Key: name, Algorithm: c.algorithm(), Expected: upload.Hash, Actual: actual}
+}
+
+func (c *Controller) algorithm() string {
+	if c.hashAlgorithm == HashSHA256 {
+		return HashSHA256
+	}
+	return HashMD5
+}
@@ -0,0 +1,139 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cont_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/cont"
+	"github.com/smartim/tools/s3/local"
+	"github.com/smartim/tools/s3/local/localtest"
+)
+
+// publishLocal records the keys objects are written to by completing or copying them.
+type publishLocal struct {
+	*local.Local
+	written []string
+}
+
+func (p *publishLocal) CompleteMultipartUpload(ctx context.Context, uploadID string, name string, parts []s3.Part) (*s3.CompleteMultipartUploadResult, error) {
+	p.written = append(p.written, name)
+	return p.Local.CompleteMultipartUpload(ctx, uploadID, name, parts)
+}
+
+func (p *publishLocal) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
+	p.written = append(p.written, dst)
+	return p.Local.CopyObject(ctx, src, dst)
+}
+
+func (p *publishLocal) published(key string) bool {
+	for _, name := range p.written {
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUploadVerification(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	ctl := cont.New(localtest.DirectCache{Impl: l}, l, cont.WithVerification())
+	parts := [][]byte{bytes.Repeat([]byte{'a'}, int(minPartSize)), []byte("tail")}
+	partHashs := []string{localtest.MD5Hex(parts[0]), localtest.MD5Hex(parts[1])}
+	res, err := ctl.InitiateUpload(ctx, localtest.MD5Hex([]byte(strings.Join(partHashs, ","))), minPartSize+4, time.Minute, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localtest.UploadParts(t, res.Sign, parts)
+	upload, err := ctl.CompleteUpload(ctx, res.UploadID, partHashs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The verified multipart upload is published to HashPath from a temporary key.
+	if info, err := l.StatObject(ctx, upload.Key); err != nil || upload.Key != ctl.HashPath(upload.Hash) || info.Size != minPartSize+4 {
+		t.Fatalf("unexpected upload %+v: %v", upload, err)
+	}
+	if temp, err := l.ListObjects(ctx, "openim/temp/", "", 0); err != nil || len(temp.Objects) != 0 {
+		t.Fatalf("temporary objects left: %+v %v", temp, err)
+	}
+
+	impl := &publishLocal{Local: l}
+	ctl = cont.New(localtest.DirectCache{Impl: l}, impl, cont.WithHashAlgorithm(cont.HashSHA256))
+	data := []byte("hello sha256")
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if _, err := ctl.InitiateUpload(ctx, localtest.MD5Hex(data), int64(len(data)), time.Minute, -1); err == nil {
+		t.Fatal("expected md5 rejected as sha256 hash")
+	}
+	wrong := sha256.Sum256([]byte("other content"))
+	res, err = ctl.InitiateUpload(ctx, hex.EncodeToString(wrong[:]), int64(len(data)), time.Minute, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localtest.UploadParts(t, res.Sign, [][]byte{data})
+	if _, err := ctl.CompleteUpload(ctx, res.UploadID, []string{localtest.MD5Hex([]byte("other content"))}); err == nil {
+		t.Fatal("expected part hash rejected")
+	}
+	// The rejected upload is deleted, upload it again.
+	localtest.UploadParts(t, res.Sign, [][]byte{data})
+	_, err = ctl.CompleteUpload(ctx, res.UploadID, []string{localtest.MD5Hex(data)})
+	var mismatch *cont.HashMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Algorithm != cont.HashSHA256 || mismatch.Actual != hash {
+		t.Fatalf("expected hash mismatch, got %v", err)
+	}
+	if _, err := l.StatObject(ctx, mismatch.Key); !l.IsNotFound(err) {
+		t.Fatalf("mismatching object not deleted: %v", err)
+	}
+	if impl.published(ctl.HashPath(hex.EncodeToString(wrong[:]))) {
+		t.Fatal("mismatching object published")
+	}
+
+	// A mismatching multipart upload is never published either.
+	big := bytes.Repeat([]byte{'b'}, minPartSize+1)
+	res, err = ctl.InitiateUpload(ctx, hex.EncodeToString(wrong[:]), int64(len(big)), time.Minute, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bigParts := [][]byte{big[:minPartSize], big[minPartSize:]}
+	localtest.UploadParts(t, res.Sign, bigParts)
+	_, err = ctl.CompleteUpload(ctx, res.UploadID, []string{localtest.MD5Hex(bigParts[0]), localtest.MD5Hex(bigParts[1])})
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected hash mismatch, got %v", err)
+	}
+	if impl.published(ctl.HashPath(hex.EncodeToString(wrong[:]))) {
+		t.Fatal("mismatching multipart object published")
+	}
+	res, err = ctl.InitiateUpload(ctx, hash, int64(len(data)), time.Minute, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localtest.UploadParts(t, res.Sign, [][]byte{data})
+	upload, err = ctl.CompleteUpload(ctx, res.UploadID, []string{localtest.MD5Hex(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.Key != ctl.HashPath(hash) {
+		t.Fatalf("unexpected key %s", upload.Key)
+	}
+}
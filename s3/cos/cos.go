@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -41,6 +42,8 @@ const (
 	maxNumSize  int64 = 1000
 )
 
+const maxListKeys = 1000
+
 const (
 	imagePng  = "png"
 	imageJpg  = "jpg"
@@ -51,7 +54,12 @@ const (
 
 const successCode = http.StatusOK
 
-var _ s3.Interface = (*Cos)(nil)
+var (
+	_ s3.Interface           = (*Cos)(nil)
+	_ s3.ObjectStream        = (*Cos)(nil)
+	_ s3.EncryptedPartSigner = (*Cos)(nil)
+	_ s3.EncryptedStatter    = (*Cos)(nil)
+)
 
 type Config struct {
 	BucketURL    string
@@ -101,7 +109,11 @@ func (c *Cos) PartLimit() (*s3.PartLimit, error) {
 }
 
 func (c *Cos) InitiateMultipartUpload(ctx context.Context, name string, opt *s3.PutOption) (*s3.InitiateMultipartUploadResult, error) {
-	result, _, err := c.client.Object.InitiateMultipartUpload(ctx, name, nil)
+	header, err := putHeaderOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := c.client.Object.InitiateMultipartUpload(ctx, name, &cos.InitiateMultipartUploadOptions{ObjectPutHeaderOptions: header})
 	if err != nil {
 		return nil, err
 	}
@@ -152,6 +164,15 @@ func (c *Cos) PartSize(ctx context.Context, size int64) (int64, error) {
 }
 
 func (c *Cos) AuthSign(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int) (*s3.AuthSignResult, error) {
+	return c.AuthSignEncrypted(ctx, uploadID, name, expire, partNumbers, nil)
+}
+
+func (c *Cos) AuthSignEncrypted(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int, enc *s3.Encryption) (*s3.AuthSignResult, error) {
+	if enc != nil {
+		if err := enc.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	result := s3.AuthSignResult{
 		URL:    c.client.BaseURL.BucketURL.String() + "/" + cos.EncodeURIComponent(name),
 		Query:  url.Values{"uploadId": {uploadID}},
@@ -162,6 +183,9 @@ func (c *Cos) AuthSign(ctx context.Context, uploadID string, name string, expire
 	if err != nil {
 		return nil, err
 	}
+	for k, v := range encryptionHeader(enc, true) {
+		req.Header[k] = v
+	}
 	cos.AddAuthorizationHeader(c.credential.SecretID, c.credential.SecretKey, c.credential.SessionToken, req, cos.NewAuthTime(expire))
 	result.Header = req.Header
 	for i, partNumber := range partNumbers {
@@ -174,11 +198,20 @@ func (c *Cos) AuthSign(ctx context.Context, uploadID string, name string, expire
 }
 
 func (c *Cos) PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *s3.PutOption) (*s3.PresignedPutResult, error) {
-	rawURL, err := c.client.Object.GetPresignedURL(ctx, http.MethodPut, name, c.credential.SecretID, c.credential.SecretKey, expire, nil)
+	header, err := putHeader(opt)
+	if err != nil {
+		return nil, err
+	}
+	// The encryption, metadata and tagging headers are signed, the client has to send them as returned.
+	var presignOpt any
+	if header != nil {
+		presignOpt = &cos.PresignedURLOptions{Header: &header}
+	}
+	rawURL, err := c.client.Object.GetPresignedURL(ctx, http.MethodPut, name, c.credential.SecretID, c.credential.SecretKey, expire, presignOpt)
 	if err != nil {
 		return nil, err
 	}
-	return &s3.PresignedPutResult{URL: rawURL.String()}, nil
+	return &s3.PresignedPutResult{URL: rawURL.String(), Header: header}, nil
 }
 
 func (c *Cos) DeleteObject(ctx context.Context, name string) error {
@@ -186,11 +219,77 @@ func (c *Cos) DeleteObject(ctx context.Context, name string) error {
 	return err
 }
 
+func (c *Cos) DeleteObjects(ctx context.Context, names []string) error {
+	for start := 0; start < len(names); start += maxListKeys {
+		end := min(start+maxListKeys, len(names))
+		opt := &cos.ObjectDeleteMultiOptions{
+			Quiet:   true,
+			Objects: make([]cos.Object, 0, end-start),
+		}
+		for _, name := range names[start:end] {
+			opt.Objects = append(opt.Objects, cos.Object{Key: name})
+		}
+		result, _, err := c.client.Object.DeleteMulti(ctx, opt)
+		if err != nil {
+			return err
+		}
+		if len(result.Errors) > 0 {
+			e := result.Errors[0]
+			return fmt.Errorf("DeleteObjects %s failed: %s %s", e.Key, e.Code, e.Message)
+		}
+	}
+	return nil
+}
+
+// ListObjects uses the marker based listing of COS, the continuation token is the next marker.
+func (c *Cos) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+	result, _, err := c.client.Bucket.Get(ctx, &cos.BucketGetOptions{
+		Prefix:  prefix,
+		Marker:  continuationToken,
+		MaxKeys: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &s3.ListObjectsResult{
+		Objects:     make([]s3.ObjectInfo, len(result.Contents)),
+		IsTruncated: result.IsTruncated,
+	}
+	for i, object := range result.Contents {
+		lastModified, _ := time.Parse(time.RFC3339, object.LastModified)
+		res.Objects[i] = s3.ObjectInfo{
+			ETag:         strings.ToLower(strings.ReplaceAll(object.ETag, `"`, "")),
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: lastModified,
+		}
+	}
+	if result.IsTruncated {
+		res.NextContinuationToken = result.NextMarker
+		if res.NextContinuationToken == "" && len(result.Contents) > 0 {
+			res.NextContinuationToken = result.Contents[len(result.Contents)-1].Key
+		}
+	}
+	return res, nil
+}
+
 func (c *Cos) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, error) {
+	return c.StatObjectEncrypted(ctx, name, nil)
+}
+
+// StatObjectEncrypted stats an object written with the SSE-C key of enc.
+func (c *Cos) StatObjectEncrypted(ctx context.Context, name string, enc *s3.Encryption) (*s3.ObjectInfo, error) {
 	if name != "" && name[0] == '/' {
 		name = name[1:]
 	}
-	info, err := c.client.Object.Head(ctx, name, nil)
+	var opts *cos.ObjectHeadOptions
+	if header := encryptionHeader(enc, true); header != nil {
+		opts = &cos.ObjectHeadOptions{XOptionHeader: &header}
+	}
+	info, err := c.client.Object.Head(ctx, name, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -217,9 +316,36 @@ func (c *Cos) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, erro
 			return nil, fmt.Errorf("StatObject last-modified parse error: %w", err)
 		}
 	}
+	res.ContentType = info.Header.Get("Content-Type")
+	res.Metadata = metadataFromHeader(info.Header)
 	return res, nil
 }
 
+func (c *Cos) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		_, err := c.client.Object.DeleteTagging(ctx, name)
+		return err
+	}
+	tagSet := make([]cos.ObjectTaggingTag, 0, len(tags))
+	for key, value := range tags {
+		tagSet = append(tagSet, cos.ObjectTaggingTag{Key: key, Value: value})
+	}
+	_, err := c.client.Object.PutTagging(ctx, name, &cos.ObjectPutTaggingOptions{TagSet: tagSet})
+	return err
+}
+
+func (c *Cos) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	res, _, err := c.client.Object.GetTagging(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(res.TagSet))
+	for _, tag := range res.TagSet {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
 func (c *Cos) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
 	sourceURL := c.copyURL + src
 	result, _, err := c.client.Object.Copy(ctx, dst, sourceURL, nil)
@@ -344,6 +470,43 @@ func (c *Cos) getPresignedURL(ctx context.Context, name string, expire time.Dura
 	return c.client.Object.GetObjectURL(name), nil
 }
 
+func (c *Cos) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	opts := &cos.ObjectGetOptions{Range: opt.Range()}
+	if header := encryptionHeader(enc, true); header != nil {
+		opts.XOptionHeader = &header
+	}
+	resp, err := c.client.Object.Get(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Cos) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	if size < 0 {
+		return nil, errors.New("cos put object requires a known size")
+	}
+	header, err := putHeaderOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	header.ContentLength = size
+	resp, err := c.client.Object.Put(ctx, name, reader, &cos.ObjectPutOptions{ObjectPutHeaderOptions: header})
+	if err != nil {
+		return nil, err
+	}
+	return &s3.ObjectInfo{
+		ETag:         strings.ToLower(strings.ReplaceAll(resp.Header.Get("ETag"), `"`, "")),
+		Key:          name,
+		Size:         size,
+		LastModified: time.Now(),
+	}, nil
+}
+
 func (c *Cos) FormData(ctx context.Context, name string, size int64, contentType string, duration time.Duration) (*s3.FormData, error) {
 	// https://cloud.tencent.com/document/product/436/14690
 	now := time.Now()
@@ -0,0 +1,65 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/s3"
+)
+
+func TestReadEncrypted(t *testing.T) {
+	enc := &s3.Encryption{Type: s3.SSEC, CustomerKey: bytes.Repeat([]byte{7}, 32)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The object can only be read with the key it was written with.
+		if r.Header.Get(headerSSECKey) != enc.CustomerKeyBase64() || r.Header.Get(headerSSECKeyMD5) != enc.CustomerKeyMD5() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", "4")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("data"))
+		}
+	}))
+	defer srv.Close()
+	c, err := NewCos(Config{BucketURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.StatObject(ctx, "object"); err == nil {
+		t.Fatal("stat without the key succeeded")
+	}
+	info, err := c.StatObjectEncrypted(ctx, "object", enc)
+	if err != nil || info.Size != 4 || info.ETag != "abc" {
+		t.Fatalf("StatObjectEncrypted: %+v %v", info, err)
+	}
+	reader, err := c.GetObject(ctx, "object", &s3.GetOption{Encryption: enc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if data, err := io.ReadAll(reader); err != nil || string(data) != "data" {
+		t.Fatalf("GetObject: %q %v", data, err)
+	}
+}
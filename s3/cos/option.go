@@ -0,0 +1,109 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cos
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/smartim/tools/s3"
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+const (
+	headerSSE           = "x-cos-server-side-encryption"
+	headerSSEKMSKeyID   = "x-cos-server-side-encryption-cos-kms-key-id"
+	headerSSECAlgorithm = "x-cos-server-side-encryption-customer-algorithm"
+	headerSSECKey       = "x-cos-server-side-encryption-customer-key"
+	headerSSECKeyMD5    = "x-cos-server-side-encryption-customer-key-MD5"
+	headerMetaPrefix    = "x-cos-meta-"
+	headerTagging       = "x-cos-tagging"
+	sseAlgorithmAES256  = "AES256"
+	sseAlgorithmCosKMS  = "cos/kms"
+)
+
+// encryptionHeader returns the request headers of enc. With part set only SSE-C headers
+// are returned, other types are inherited by the parts from the multipart upload.
+func encryptionHeader(enc *s3.Encryption, part bool) http.Header {
+	if enc == nil || (part && enc.Type != s3.SSEC) {
+		return nil
+	}
+	header := make(http.Header)
+	switch enc.Type {
+	case s3.SSES3:
+		header.Set(headerSSE, sseAlgorithmAES256)
+	case s3.SSEKMS:
+		header.Set(headerSSE, sseAlgorithmCosKMS)
+		if enc.KMSKeyID != "" {
+			header.Set(headerSSEKMSKeyID, enc.KMSKeyID)
+		}
+	case s3.SSEC:
+		header.Set(headerSSECAlgorithm, sseAlgorithmAES256)
+		header.Set(headerSSECKey, enc.CustomerKeyBase64())
+		header.Set(headerSSECKeyMD5, enc.CustomerKeyMD5())
+	}
+	return header
+}
+
+// putHeader returns the encryption, metadata and tagging headers of an upload.
+func putHeader(opt *s3.PutOption) (http.Header, error) {
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	header := encryptionHeader(enc, false)
+	if opt == nil || (len(opt.Metadata) == 0 && len(opt.Tags) == 0) {
+		return header, nil
+	}
+	if header == nil {
+		header = make(http.Header)
+	}
+	for k, v := range opt.Metadata {
+		header.Set(headerMetaPrefix+k, v)
+	}
+	if len(opt.Tags) > 0 {
+		header.Set(headerTagging, s3.EncodeTags(opt.Tags))
+	}
+	return header, nil
+}
+
+func putHeaderOptions(opt *s3.PutOption) (*cos.ObjectPutHeaderOptions, error) {
+	h, err := putHeader(opt)
+	if err != nil {
+		return nil, err
+	}
+	var header cos.ObjectPutHeaderOptions
+	if opt != nil {
+		header.ContentType = opt.ContentType
+	}
+	if h != nil {
+		header.XOptionHeader = &h
+	}
+	return &header, nil
+}
+
+// metadataFromHeader returns the user metadata of a HEAD response.
+func metadataFromHeader(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for k, v := range header {
+		if len(v) > 0 && strings.HasPrefix(strings.ToLower(k), headerMetaPrefix) {
+			metadata[strings.ToLower(k[len(headerMetaPrefix):])] = v[0]
+		}
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
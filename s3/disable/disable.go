@@ -63,6 +63,14 @@ func (disableS3) DeleteObject(ctx context.Context, name string) error {
 	return errDisabled
 }
 
+func (disableS3) DeleteObjects(ctx context.Context, names []string) error {
+	return errDisabled
+}
+
+func (disableS3) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	return nil, errDisabled
+}
+
 func (disableS3) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
 	return nil, errDisabled
 }
@@ -71,6 +79,14 @@ func (disableS3) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, e
 	return nil, errDisabled
 }
 
+func (disableS3) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	return errDisabled
+}
+
+func (disableS3) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	return nil, errDisabled
+}
+
 func (disableS3) IsNotFound(err error) bool {
 	return false
 }
@@ -0,0 +1,189 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproc
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// ModeFit scales the image to fit within the box, keeping the aspect ratio.
+	ModeFit = "fit"
+	// ModeFill scales the image to cover the box, then crops the overflow around the center.
+	ModeFill = "fill"
+	// ModeCrop cuts the box out of the center of the image without scaling.
+	ModeCrop = "crop"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+	FormatGIF  = "gif"
+
+	formatJpg = "jpg"
+)
+
+const (
+	// DefaultQuality is the JPEG quality used when none is given.
+	DefaultQuality = 75
+
+	// DefaultMaxOutputSize is the largest width or height of a generated image.
+	DefaultMaxOutputSize = 4096
+	// DefaultMaxOutputPixels is the largest width x height of a generated image.
+	DefaultMaxOutputPixels = 4096 * 2048
+
+	// thumbnailSize bounds the default thumbnail of ThumbnailSize.
+	thumbnailSize = 640
+)
+
+// Decode decodes an image in any of the registered formats: JPEG, PNG, GIF, WebP, BMP and TIFF.
+func Decode(reader io.Reader) (image.Image, string, error) {
+	return image.Decode(reader)
+}
+
+// decodeConfig decodes the dimensions and format of an image without decoding the pixels.
+func decodeConfig(reader io.Reader) (image.Config, string, error) {
+	return image.DecodeConfig(reader)
+}
+
+// NormalizeFormat returns the canonical name of an output format, or "" if it can't be encoded.
+func NormalizeFormat(format string) string {
+	switch format = strings.ToLower(format); format {
+	case formatJpg:
+		return FormatJPEG
+	case FormatJPEG, FormatPNG, FormatWebP, FormatGIF:
+		return format
+	default:
+		return ""
+	}
+}
+
+// Encode writes img in the given format. quality only applies to JPEG, WebP is encoded lossless.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch NormalizeFormat(format) {
+	case FormatJPEG:
+		if quality <= 0 || quality > 100 {
+			quality = DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatWebP:
+		return nativewebp.Encode(w, img, nil)
+	case FormatGIF:
+		return gif.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported image format %q", format)
+	}
+}
+
+// ThumbnailSize returns the default thumbnail box of img, at most 640x640.
+func ThumbnailSize(img image.Image) (width, height int) {
+	bounds := img.Bounds()
+	return min(bounds.Dx(), thumbnailSize), min(bounds.Dy(), thumbnailSize)
+}
+
+// Resize returns img transformed into the width x height box by mode. A zero width or
+// height is derived from the aspect ratio, which makes ModeFill and ModeCrop behave like ModeFit.
+// The output is allocated at the size of the box, callers bound it, see CheckOutputSize.
+func Resize(img image.Image, width, height int, mode string) image.Image {
+	bounds := img.Bounds()
+	imgWidth, imgHeight := bounds.Dx(), bounds.Dy()
+	if width <= 0 && height <= 0 || imgWidth == 0 || imgHeight == 0 {
+		return img
+	}
+	if width <= 0 || height <= 0 {
+		mode = ModeFit
+	}
+	switch mode {
+	case ModeFill:
+		// Cut the largest centered area of the box's aspect ratio out of the source, then scale
+		// only that area, so nothing larger than the box is allocated.
+		cropWidth, cropHeight := imgWidth, imgHeight
+		if int64(imgWidth)*int64(height) > int64(imgHeight)*int64(width) {
+			cropWidth = max(int(int64(imgHeight)*int64(width)/int64(height)), 1)
+		} else {
+			cropHeight = max(int(int64(imgWidth)*int64(height)/int64(width)), 1)
+		}
+		return scaleImage(img, centerRect(bounds, cropWidth, cropHeight), width, height)
+	case ModeCrop:
+		return centerCrop(img, min(width, imgWidth), min(height, imgHeight))
+	default:
+		var scale float64
+		switch {
+		case width > 0 && height > 0:
+			scale = min(float64(width)/float64(imgWidth), float64(height)/float64(imgHeight))
+		case width > 0:
+			scale = float64(width) / float64(imgWidth)
+		default:
+			scale = float64(height) / float64(imgHeight)
+		}
+		return scaleImage(img, bounds, max(int(float64(imgWidth)*scale), 1), max(int(float64(imgHeight)*scale), 1))
+	}
+}
+
+// CheckOutputSize returns an error if a width x height image exceeds maxSize on a side or
+// maxPixels in total. A zero width or height is derived from the source, so only bounded by maxSize.
+func CheckOutputSize(width, height, maxSize, maxPixels int) error {
+	if width > maxSize || height > maxSize {
+		return fmt.Errorf("image size %dx%d exceeds %d", width, height, maxSize)
+	}
+	if width > 0 && height > 0 && int64(width)*int64(height) > int64(maxPixels) {
+		return fmt.Errorf("image size %dx%d exceeds %d pixels", width, height, maxPixels)
+	}
+	return nil
+}
+
+// scaleImage resamples the src area of img to width x height with the nearest neighbour.
+func scaleImage(img image.Image, src image.Rectangle, width, height int) image.Image {
+	if src == img.Bounds() && src.Dx() == width && src.Dy() == height {
+		return img
+	}
+	scaleX := float64(src.Dx()) / float64(width)
+	scaleY := float64(src.Dy()) / float64(height)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		srcY := src.Min.Y + int(float64(y)*scaleY)
+		for x := 0; x < width; x++ {
+			dst.Set(x, y, img.At(src.Min.X+int(float64(x)*scaleX), srcY))
+		}
+	}
+	return dst
+}
+
+// centerCrop cuts a width x height box out of the center of img.
+func centerCrop(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	return scaleImage(img, centerRect(bounds, width, height), width, height)
+}
+
+// centerRect returns the width x height rectangle at the center of bounds.
+func centerRect(bounds image.Rectangle, width, height int) image.Rectangle {
+	x0 := bounds.Min.X + (bounds.Dx()-width)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-height)/2
+	return image.Rect(x0, y0, x0+width, y0+height)
+}
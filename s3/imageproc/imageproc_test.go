@@ -0,0 +1,184 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/local"
+	"github.com/smartim/tools/s3/local/localtest"
+)
+
+func newTestImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestResize(t *testing.T) {
+	img := newTestImage(400, 200)
+	cases := []struct {
+		mode          string
+		width, height int
+		wantW, wantH  int
+	}{
+		{ModeFit, 100, 100, 100, 50},
+		{ModeFit, 0, 50, 100, 50},
+		{ModeFill, 100, 100, 100, 100},
+		{ModeFill, 800, 100, 800, 100},
+		{ModeFill, 4000, 1, 4000, 1},
+		{ModeCrop, 100, 100, 100, 100},
+		{ModeCrop, 500, 100, 400, 100},
+		{ModeCrop, 100, 0, 100, 50},
+	}
+	for _, c := range cases {
+		bounds := Resize(img, c.width, c.height, c.mode).Bounds()
+		if bounds.Dx() != c.wantW || bounds.Dy() != c.wantH {
+			t.Errorf("%s %dx%d: got %dx%d, want %dx%d", c.mode, c.width, c.height, bounds.Dx(), bounds.Dy(), c.wantW, c.wantH)
+		}
+	}
+}
+
+func TestResizeFillCrop(t *testing.T) {
+	img := newTestImage(400, 200)
+	// The center 200x200 of the source is scaled down to the box.
+	out := Resize(img, 100, 100, ModeFill)
+	if got, want := out.At(0, 0), img.At(100, 0); got != want {
+		t.Fatalf("top left pixel %v, want %v", got, want)
+	}
+	if got, want := out.At(99, 99), img.At(298, 198); got != want {
+		t.Fatalf("bottom right pixel %v, want %v", got, want)
+	}
+}
+
+func TestCheckOutputSize(t *testing.T) {
+	cases := []struct {
+		width, height int
+		ok            bool
+	}{
+		{100, 100, true},
+		{0, 4096, true},
+		{4097, 0, false},
+		{4096, 2048, true},
+		{4096, 2049, false},
+	}
+	for _, c := range cases {
+		err := CheckOutputSize(c.width, c.height, DefaultMaxOutputSize, DefaultMaxOutputPixels)
+		if (err == nil) != c.ok {
+			t.Errorf("%dx%d: got %v", c.width, c.height, err)
+		}
+	}
+}
+
+// countingLocal counts the objects opened for reading.
+type countingLocal struct {
+	*local.Local
+	opened int
+}
+
+func (c *countingLocal) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	c.opened++
+	return c.Local.GetObject(ctx, name, opt)
+}
+
+func TestProcessor(t *testing.T) {
+	ctx := context.Background()
+	impl := &countingLocal{Local: localtest.New(t, "http://127.0.0.1/object")}
+	p, err := New(impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := png.Encode(buf, newTestImage(400, 200)); err != nil {
+		t.Fatal(err)
+	}
+	info, err := impl.PutObject(ctx, "image.png", buf, int64(buf.Len()), &s3.PutOption{ContentType: "image/png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No transform needed, the source is returned as is.
+	variant, err := p.Thumbnail(ctx, "image.png", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variant.Key != "image.png" || variant.Width != 400 || variant.Height != 200 {
+		t.Fatalf("unexpected source variant %+v", variant)
+	}
+
+	for _, opt := range []Options{
+		{Width: 100, Height: 100, Mode: ModeFill, Format: FormatWebP},
+		{Width: 100, Format: "jpg", Quality: 60},
+	} {
+		variant, err := p.Thumbnail(ctx, "image.png", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := impl.GetObject(ctx, variant.Key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, format, err := Decode(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != variant.Format || img.Bounds().Dx() != variant.Width || img.Bounds().Dy() != variant.Height {
+			t.Fatalf("variant %+v decoded as %s %v", variant, format, img.Bounds())
+		}
+		stored, err := impl.StatObject(ctx, variant.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The cached variant is reused instead of being generated again, without reading the source.
+		opened := impl.opened
+		again, err := p.Thumbnail(ctx, "image.png", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *again != *variant {
+			t.Fatalf("variant changed: %+v != %+v", again, variant)
+		}
+		if impl.opened != opened {
+			t.Fatal("source opened for a cached variant")
+		}
+		if cached, err := impl.StatObject(ctx, variant.Key); err != nil || !cached.LastModified.Equal(stored.LastModified) {
+			t.Fatalf("variant regenerated: %v", err)
+		}
+	}
+
+	if _, err := p.Thumbnail(ctx, "image.png", Options{Width: 100000, Height: 100000, Mode: ModeFill}); err == nil {
+		t.Fatal("oversized variant accepted")
+	}
+
+	deleted, err := p.DeleteVariants(ctx, info.ETag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Fatalf("deleted %d variants, want 2", deleted)
+	}
+}
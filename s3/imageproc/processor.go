@@ -0,0 +1,340 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/log"
+	"github.com/smartim/tools/s3"
+)
+
+const (
+	// DefaultPrefix is where generated variants are stored, grouped by the source ETag.
+	DefaultPrefix = "openim/thumbnail"
+
+	// DefaultMaxSourceSize is the largest source image processed.
+	DefaultMaxSourceSize = 1024 * 1024 * 50
+)
+
+// The metadata stored with a variant, so a cached variant is described without reading the source.
+const (
+	metaWidth  = "image-width"
+	metaHeight = "image-height"
+	metaFormat = "image-format"
+)
+
+// ErrNotImage is returned when the source object can't be decoded as an image.
+var ErrNotImage = errors.New("object not image")
+
+// Options describes the variant to generate.
+type Options struct {
+	// Width and Height bound the variant, a zero value is derived from the aspect ratio.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Mode is one of ModeFit, ModeFill or ModeCrop, ModeFit by default.
+	Mode string `json:"mode"`
+	// Format is the output format, the source format is kept when empty or not encodable.
+	Format string `json:"format"`
+	// Quality is the JPEG quality between 1 and 100, DefaultQuality by default.
+	Quality int `json:"quality"`
+}
+
+// Variant is a generated image, or the source itself when no processing was needed.
+type Variant struct {
+	Key         string `json:"key"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Format      string `json:"format"`
+	ContentType string `json:"contentType"`
+}
+
+// Option configures a Processor.
+type Option func(*Processor)
+
+// WithPrefix sets where generated variants are stored.
+func WithPrefix(prefix string) Option {
+	return func(p *Processor) {
+		p.prefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// WithMaxSourceSize sets the largest source image processed.
+func WithMaxSourceSize(size int64) Option {
+	return func(p *Processor) {
+		p.maxSize = size
+	}
+}
+
+// WithMaxOutputSize sets the largest width or height and the largest width x height of a variant.
+func WithMaxOutputSize(size int, pixels int) Option {
+	return func(p *Processor) {
+		p.maxOutputSize = size
+		p.maxOutputPixels = pixels
+	}
+}
+
+// New returns a Processor generating variants on top of impl, which must implement s3.ObjectStream.
+func New(impl s3.Interface, opts ...Option) (*Processor, error) {
+	stream, ok := impl.(s3.ObjectStream)
+	if !ok {
+		return nil, errs.ErrArgs.WrapMsg("s3 engine does not support streaming", "engine", impl.Engine())
+	}
+	p := &Processor{
+		impl:            impl,
+		stream:          stream,
+		prefix:          DefaultPrefix,
+		maxSize:         DefaultMaxSourceSize,
+		maxOutputSize:   DefaultMaxOutputSize,
+		maxOutputPixels: DefaultMaxOutputPixels,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// Processor generates image variants of stored objects and stores them next to the sources.
+// Variants are keyed by the source ETag, so they are generated once per source content.
+type Processor struct {
+	impl            s3.Interface
+	stream          s3.ObjectStream
+	prefix          string
+	maxSize         int64
+	maxOutputSize   int
+	maxOutputPixels int
+}
+
+// VariantKey returns where the variant of a source with the given ETag is stored. The key only
+// depends on opt, so a cached variant is found without reading the source.
+func (p *Processor) VariantKey(etag string, opt Options) string {
+	opt = requestOptions(opt)
+	format := opt.Format
+	if format == "" {
+		format = "auto"
+	}
+	return path.Join(p.prefix, etag, fmt.Sprintf("image_%s_w%d_h%d_q%d.%s", opt.Mode, opt.Width, opt.Height, opt.Quality, format))
+}
+
+// Thumbnail returns the variant of the named image described by opt, generating it on first use.
+func (p *Processor) Thumbnail(ctx context.Context, name string, opt Options) (*Variant, error) {
+	if err := CheckOutputSize(opt.Width, opt.Height, p.maxOutputSize, p.maxOutputPixels); err != nil {
+		return nil, errs.ErrArgs.WrapMsg(err.Error(), "name", name)
+	}
+	info, err := p.impl.StatObject(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	key := p.VariantKey(info.ETag, opt)
+	if stored, err := p.impl.StatObject(ctx, key); err == nil {
+		if variant := storedVariant(key, stored.Metadata); variant != nil {
+			return variant, nil
+		}
+		log.ZWarn(ctx, "image variant without metadata, regenerating", nil, "key", key)
+	} else if !p.impl.IsNotFound(err) {
+		return nil, err
+	}
+	if info.Size > p.maxSize {
+		return nil, errs.ErrArgs.WrapMsg("image too large", "name", name, "size", info.Size)
+	}
+	reader, err := p.stream.GetObject(ctx, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// Only the header is needed to know the dimensions, the rest is read when processing.
+	buf := bytes.NewBuffer(nil)
+	cfg, format, err := decodeConfig(io.TeeReader(reader, buf))
+	if err != nil {
+		return nil, errs.WrapMsg(ErrNotImage, "decode image failed", "name", name, "err", err.Error())
+	}
+	opt = normalizeOptions(opt, cfg.Width, cfg.Height, format)
+	if opt.Width == cfg.Width && opt.Height == cfg.Height && opt.Format == NormalizeFormat(format) {
+		return newVariant(name, opt), nil
+	}
+	img, _, err := Decode(io.MultiReader(buf, reader))
+	if err != nil {
+		return nil, errs.WrapMsg(ErrNotImage, "decode image failed", "name", name, "err", err.Error())
+	}
+	thumbnail := Resize(img, opt.Width, opt.Height, opt.Mode)
+	data := bytes.NewBuffer(nil)
+	if err := Encode(data, thumbnail, opt.Format, opt.Quality); err != nil {
+		return nil, errs.WrapMsg(err, "encode failed", "format", opt.Format)
+	}
+	variant := newVariant(key, opt)
+	putOpt := &s3.PutOption{
+		ContentType: variant.ContentType,
+		Metadata: map[string]string{
+			metaWidth:  strconv.Itoa(variant.Width),
+			metaHeight: strconv.Itoa(variant.Height),
+			metaFormat: variant.Format,
+		},
+	}
+	if _, err := p.stream.PutObject(ctx, key, data, int64(data.Len()), putOpt); err != nil {
+		return nil, err
+	}
+	log.ZDebug(ctx, "image variant generated", "name", name, "key", key)
+	return variant, nil
+}
+
+// ThumbnailURL returns a URL to download the variant of the named image described by opt.
+func (p *Processor) ThumbnailURL(ctx context.Context, name string, expire time.Duration, opt Options) (string, error) {
+	variant, err := p.Thumbnail(ctx, name, opt)
+	if err != nil {
+		return "", err
+	}
+	return p.impl.AccessURL(ctx, variant.Key, expire, &s3.AccessURLOption{ContentType: variant.ContentType})
+}
+
+// DeleteVariants deletes every variant generated from a source with the given ETag.
+func (p *Processor) DeleteVariants(ctx context.Context, etag string) (int, error) {
+	if etag == "" {
+		return 0, errs.ErrArgs.WrapMsg("etag is empty")
+	}
+	var (
+		token   string
+		deleted int
+	)
+	prefix := path.Join(p.prefix, etag) + "/"
+	for {
+		res, err := p.impl.ListObjects(ctx, prefix, token, 0)
+		if err != nil {
+			return deleted, err
+		}
+		names := make([]string, len(res.Objects))
+		for i, object := range res.Objects {
+			names[i] = object.Key
+		}
+		if err := p.impl.DeleteObjects(ctx, names); err != nil {
+			return deleted, err
+		}
+		deleted += len(names)
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return deleted, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// newVariant describes the variant stored at key, opt must be normalized.
+func newVariant(key string, opt Options) *Variant {
+	return &Variant{
+		Key:         key,
+		Width:       opt.Width,
+		Height:      opt.Height,
+		Format:      opt.Format,
+		ContentType: "image/" + opt.Format,
+	}
+}
+
+// storedVariant describes the variant stored at key from its metadata, or returns nil if the
+// metadata is missing.
+func storedVariant(key string, md map[string]string) *Variant {
+	width, err := strconv.Atoi(md[metaWidth])
+	if err != nil || width <= 0 {
+		return nil
+	}
+	height, err := strconv.Atoi(md[metaHeight])
+	if err != nil || height <= 0 {
+		return nil
+	}
+	format := NormalizeFormat(md[metaFormat])
+	if format == "" {
+		return nil
+	}
+	return newVariant(key, Options{Width: width, Height: height, Format: format})
+}
+
+// requestOptions fills the defaults of opt that don't depend on the source, so that equivalent
+// requests share a variant key. An empty Format keeps the source format.
+func requestOptions(opt Options) Options {
+	opt.Width, opt.Height = max(opt.Width, 0), max(opt.Height, 0)
+	switch opt.Mode {
+	case ModeFill, ModeCrop:
+		if opt.Width == 0 || opt.Height == 0 {
+			opt.Mode = ModeFit
+		}
+	default:
+		opt.Mode = ModeFit
+	}
+	opt.Format = NormalizeFormat(opt.Format)
+	if opt.Format == "" || opt.Format == FormatJPEG {
+		if opt.Quality <= 0 || opt.Quality > 100 {
+			opt.Quality = DefaultQuality
+		}
+	} else {
+		opt.Quality = 0
+	}
+	return opt
+}
+
+// normalizeOptions fills the defaults of opt so that equivalent options share a variant key.
+func normalizeOptions(opt Options, width int, height int, format string) Options {
+	switch opt.Mode {
+	case ModeFill, ModeCrop:
+		if opt.Width <= 0 || opt.Height <= 0 {
+			opt.Mode = ModeFit
+		}
+	default:
+		opt.Mode = ModeFit
+	}
+	if opt.Mode != ModeFill {
+		// Never upscale when fitting or cropping.
+		if opt.Width > width {
+			opt.Width = width
+		}
+		if opt.Height > height {
+			opt.Height = height
+		}
+	}
+	if opt.Mode == ModeFit {
+		if opt.Width <= 0 && opt.Height <= 0 {
+			opt.Width, opt.Height = width, height
+		}
+		// Resolve the box to the exact output size.
+		scale := 1.0
+		switch {
+		case opt.Width > 0 && opt.Height > 0:
+			scale = min(float64(opt.Width)/float64(width), float64(opt.Height)/float64(height))
+		case opt.Width > 0:
+			scale = float64(opt.Width) / float64(width)
+		default:
+			scale = float64(opt.Height) / float64(height)
+		}
+		opt.Width, opt.Height = max(int(float64(width)*scale), 1), max(int(float64(height)*scale), 1)
+	}
+	if opt.Format = NormalizeFormat(opt.Format); opt.Format == "" {
+		if opt.Format = NormalizeFormat(format); opt.Format == "" {
+			opt.Format = FormatPNG
+		}
+	}
+	if opt.Format == FormatJPEG {
+		if opt.Quality <= 0 || opt.Quality > 100 {
+			opt.Quality = DefaultQuality
+		}
+	} else {
+		opt.Quality = 0
+	}
+	return opt
+}
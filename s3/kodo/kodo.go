@@ -45,8 +45,15 @@ const (
 	maxNumSize  = 10000
 )
 
+const maxListKeys = 1000
+
 const successCode = http.StatusOK
 
+var (
+	_ s3.Interface    = (*Kodo)(nil)
+	_ s3.ObjectStream = (*Kodo)(nil)
+)
+
 type Config struct {
 	Endpoint        string
 	Bucket          string
@@ -113,10 +120,18 @@ func (k *Kodo) PartLimit() (*s3.PartLimit, error) {
 }
 
 func (k *Kodo) InitiateMultipartUpload(ctx context.Context, name string, opt *s3.PutOption) (*s3.InitiateMultipartUploadResult, error) {
-	result, err := k.Client.CreateMultipartUpload(ctx, &awss3.CreateMultipartUploadInput{
+	if err := k.checkPutOption(opt); err != nil {
+		return nil, err
+	}
+	params := &awss3.CreateMultipartUploadInput{
 		Bucket: aws.String(k.Region),
 		Key:    aws.String(name),
-	})
+	}
+	if opt != nil {
+		params.Metadata = opt.Metadata
+		params.Tagging = tagging(opt.Tags)
+	}
+	result, err := k.Client.CreateMultipartUpload(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -194,14 +209,28 @@ func (k *Kodo) AuthSign(ctx context.Context, uploadID string, name string, expir
 }
 
 func (k *Kodo) PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *s3.PutOption) (*s3.PresignedPutResult, error) {
-	object, err := k.PresignClient.PresignPutObject(ctx, &awss3.PutObjectInput{
+	if err := k.checkPutOption(opt); err != nil {
+		return nil, err
+	}
+	params := &awss3.PutObjectInput{
 		Bucket: aws.String(k.Region),
 		Key:    aws.String(name),
-	}, awss3.WithPresignExpires(expire), withDisableHTTPPresignerHeaderV4(nil))
+	}
+	if opt != nil {
+		params.Metadata = opt.Metadata
+		params.Tagging = tagging(opt.Tags)
+	}
+	object, err := k.PresignClient.PresignPutObject(ctx, params, awss3.WithPresignExpires(expire), withDisableHTTPPresignerHeaderV4(nil))
 	if err != nil {
 		return nil, err
 	}
-	return &s3.PresignedPutResult{URL: object.URL}, nil
+	// The metadata and tagging headers are signed, the client has to send them as returned.
+	header := object.SignedHeader.Clone()
+	header.Del("Host")
+	if len(header) == 0 {
+		header = nil
+	}
+	return &s3.PresignedPutResult{URL: object.URL, Header: header}, nil
 }
 
 func (k *Kodo) DeleteObject(ctx context.Context, name string) error {
@@ -212,6 +241,60 @@ func (k *Kodo) DeleteObject(ctx context.Context, name string) error {
 	return err
 }
 
+func (k *Kodo) DeleteObjects(ctx context.Context, names []string) error {
+	for start := 0; start < len(names); start += maxListKeys {
+		end := min(start+maxListKeys, len(names))
+		objects := make([]awss3types.ObjectIdentifier, 0, end-start)
+		for _, name := range names[start:end] {
+			objects = append(objects, awss3types.ObjectIdentifier{Key: aws.String(name)})
+		}
+		result, err := k.Client.DeleteObjects(ctx, &awss3.DeleteObjectsInput{
+			Bucket: aws.String(k.Region),
+			Delete: &awss3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(result.Errors) > 0 {
+			e := result.Errors[0]
+			return fmt.Errorf("DeleteObjects %s failed: %s %s", aws.ToString(e.Key), aws.ToString(e.Code), aws.ToString(e.Message))
+		}
+	}
+	return nil
+}
+
+func (k *Kodo) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+	params := &awss3.ListObjectsV2Input{
+		Bucket:  aws.String(k.Region),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(int32(limit)),
+	}
+	if continuationToken != "" {
+		params.ContinuationToken = aws.String(continuationToken)
+	}
+	result, err := k.Client.ListObjectsV2(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	res := &s3.ListObjectsResult{
+		Objects:               make([]s3.ObjectInfo, len(result.Contents)),
+		NextContinuationToken: aws.ToString(result.NextContinuationToken),
+		IsTruncated:           aws.ToBool(result.IsTruncated),
+	}
+	for i, object := range result.Contents {
+		res.Objects[i] = s3.ObjectInfo{
+			ETag:         strings.ToLower(strings.ReplaceAll(aws.ToString(object.ETag), `"`, ``)),
+			Key:          aws.ToString(object.Key),
+			Size:         aws.ToInt64(object.Size),
+			LastModified: aws.ToTime(object.LastModified),
+		}
+	}
+	return res, nil
+}
+
 func (k *Kodo) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
 	result, err := k.Client.CopyObject(ctx, &awss3.CopyObjectInput{
 		Bucket:     aws.String(k.Region),
@@ -238,9 +321,40 @@ func (k *Kodo) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, err
 	res := &s3.ObjectInfo{Key: name}
 	res.Size = aws.ToInt64(info.ContentLength)
 	res.ETag = strings.ToLower(strings.ReplaceAll(aws.ToString(info.ETag), `"`, ``))
+	res.ContentType = aws.ToString(info.ContentType)
+	res.Metadata = s3.LowerMetadata(info.Metadata)
 	return res, nil
 }
 
+func (k *Kodo) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		_, err := k.Client.DeleteObjectTagging(ctx, &awss3.DeleteObjectTaggingInput{Bucket: aws.String(k.Region), Key: aws.String(name)})
+		return err
+	}
+	tagSet := make([]awss3types.Tag, 0, len(tags))
+	for key, value := range tags {
+		tagSet = append(tagSet, awss3types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	_, err := k.Client.PutObjectTagging(ctx, &awss3.PutObjectTaggingInput{
+		Bucket:  aws.String(k.Region),
+		Key:     aws.String(name),
+		Tagging: &awss3types.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
+func (k *Kodo) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	res, err := k.Client.GetObjectTagging(ctx, &awss3.GetObjectTaggingInput{Bucket: aws.String(k.Region), Key: aws.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(res.TagSet))
+	for _, tag := range res.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
 func (k *Kodo) IsNotFound(err error) bool {
 	if err != nil {
 		var errorType *awss3types.NotFound
@@ -314,6 +428,58 @@ func (k *Kodo) AccessURL(ctx context.Context, name string, expire time.Duration,
 	return res.URL, nil
 }
 
+func (k *Kodo) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	if enc, err := opt.GetEncryption(); err != nil {
+		return nil, err
+	} else if enc != nil {
+		return nil, s3.NotSupportedEncryption(k.Engine(), enc)
+	}
+	params := &awss3.GetObjectInput{
+		Bucket: aws.String(k.Region),
+		Key:    aws.String(name),
+	}
+	if rng := opt.Range(); rng != "" {
+		params.Range = aws.String(rng)
+	}
+	result, err := k.Client.GetObject(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return result.Body, nil
+}
+
+func (k *Kodo) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	if err := k.checkPutOption(opt); err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, errors.New("kodo put object requires a known size")
+	}
+	params := &awss3.PutObjectInput{
+		Bucket:        aws.String(k.Region),
+		Key:           aws.String(name),
+		Body:          reader,
+		ContentLength: aws.Int64(size),
+	}
+	if opt != nil {
+		if opt.ContentType != "" {
+			params.ContentType = aws.String(opt.ContentType)
+		}
+		params.Metadata = opt.Metadata
+		params.Tagging = tagging(opt.Tags)
+	}
+	result, err := k.Client.PutObject(ctx, params, awss3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.ObjectInfo{
+		ETag:         strings.ToLower(strings.ReplaceAll(aws.ToString(result.ETag), `"`, ``)),
+		Key:          name,
+		Size:         size,
+		LastModified: time.Now(),
+	}, nil
+}
+
 func (k *Kodo) SetObjectContentType(ctx context.Context, name string, contentType string) error {
 	//set object content-type
 	_, err := k.Client.CopyObject(ctx, &awss3.CopyObjectInput{
@@ -401,3 +567,18 @@ func (d *disableHTTPPresignerHeaderV4) setOption(u *url.URL) {
 	}
 	u.RawQuery = query.Encode()
 }
+
+// checkPutOption rejects the options kodo can't honour through its S3 compatible API.
+func (k *Kodo) checkPutOption(opt *s3.PutOption) error {
+	if opt != nil && opt.Encryption != nil {
+		return s3.NotSupportedEncryption(k.Engine(), opt.Encryption)
+	}
+	return nil
+}
+
+func tagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return aws.String(s3.EncodeTags(tags))
+}
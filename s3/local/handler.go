@@ -0,0 +1,283 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smartim/tools/errs"
+)
+
+// ServeHTTP serves the URLs produced by AuthSign, PresignedPutObject, AccessURL and
+// FormData. Requests are expected to keep the path of Config.Endpoint, so Local can
+// be mounted directly, e.g. mux.Handle("/object/", l) for http://host/object.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Access-Control-Allow-Origin", "*")
+	header.Set("Access-Control-Allow-Methods", "GET, HEAD, PUT, POST, OPTIONS")
+	header.Set("Access-Control-Allow-Headers", "*")
+	header.Set("Access-Control-Expose-Headers", "ETag")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, l.prefix+"/") {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, l.prefix+"/")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		l.serveGet(w, r, name)
+	case http.MethodPut:
+		if r.URL.Query().Has("uploadId") {
+			l.servePutPart(w, r, name)
+		} else {
+			l.servePut(w, r, name)
+		}
+	case http.MethodPost:
+		l.servePost(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (l *Local) serveGet(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	if !l.publicRead {
+		// HEAD is accepted with a GET signature, like a plain download.
+		if err := l.verifyQuery(http.MethodGet, name, query); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	meta, f, err := l.openObject(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	contentType := meta.ContentType
+	if v := query.Get("response-content-type"); v != "" {
+		contentType = v
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if v := query.Get("response-content-disposition"); v != "" {
+		w.Header().Set("Content-Disposition", v)
+	}
+	w.Header().Set("ETag", `"`+meta.ETag+`"`)
+	http.ServeContent(w, r, "", meta.LastModified, f)
+}
+
+func (l *Local) servePut(w http.ResponseWriter, r *http.Request, name string) {
+	if err := l.verifyQuery(http.MethodPut, name, r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	attrs, err := queryAttrs(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// A single upload is bounded like a part, the length of a chunked request is unknown.
+	meta, err := l.putObject(name, http.MaxBytesReader(w, r.Body, maxPartSize), r.ContentLength, attrs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+meta.ETag+`"`)
+	w.WriteHeader(successCode)
+}
+
+func (l *Local) servePutPart(w http.ResponseWriter, r *http.Request, name string) {
+	query := r.URL.Query()
+	if err := l.verifyQuery(http.MethodPut, name, query); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	uploadID := query.Get("uploadId")
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNumber < 1 || int64(partNumber) > maxNumSize {
+		http.Error(w, "invalid part number", http.StatusBadRequest)
+		return
+	}
+	if _, err := l.getUpload(uploadID, name); err != nil {
+		writeError(w, err)
+		return
+	}
+	part, err := l.putPart(uploadID, partNumber, http.MaxBytesReader(w, r.Body, maxPartSize))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+part.ETag+`"`)
+	w.WriteHeader(successCode)
+}
+
+// servePost handles browser form uploads. As with S3 POST uploads, every form field
+// must precede the file field so the body can be streamed to disk.
+func (l *Local) servePost(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, "file field missing", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, 1024*64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+		l.servePostFile(w, part, fields)
+		return
+	}
+}
+
+func (l *Local) servePostFile(w http.ResponseWriter, file *multipart.Part, fields map[string]string) {
+	name := fields["key"]
+	if !l.verify(fields["signature"], http.MethodPost, name, url.Values{"policy": {fields["policy"]}}) {
+		http.Error(w, errSignatureMismatch.Error(), http.StatusForbidden)
+		return
+	}
+	policyJson, err := base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var policy formPolicy
+	if err := json.Unmarshal(policyJson, &policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if time.Now().Unix() > policy.Expiration {
+		http.Error(w, errSignatureExpired.Error(), http.StatusForbidden)
+		return
+	}
+	if policy.Key != name || (policy.ContentType != "" && policy.ContentType != fields["Content-Type"]) {
+		http.Error(w, "form fields do not match the policy", http.StatusForbidden)
+		return
+	}
+	var body io.Reader = file
+	if policy.MaxSize > 0 {
+		body = &limitedReader{r: file, n: policy.MaxSize}
+	}
+	meta, err := l.putObject(name, body, -1, objectAttrs{ContentType: fields["Content-Type"]})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+meta.ETag+`"`)
+	w.WriteHeader(successCode)
+}
+
+func (l *Local) openObject(name string) (*objectMeta, *os.File, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	meta, err := l.readObjectMeta(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(l.objectPath(name) + dataExt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta, f, nil
+}
+
+// queryAttrs returns the metadata, tags and content type signed into a presigned upload URL.
+// The Content-Type header of the request is not signed, so it is ignored.
+func queryAttrs(query url.Values) (objectAttrs, error) {
+	attrs := objectAttrs{ContentType: query.Get(queryContentType)}
+	for k, v := range query {
+		if strings.HasPrefix(k, queryMetaPrefix) && len(v) > 0 {
+			if attrs.Metadata == nil {
+				attrs.Metadata = make(map[string]string)
+			}
+			attrs.Metadata[strings.TrimPrefix(k, queryMetaPrefix)] = v[0]
+		}
+	}
+	if tagging := query.Get(queryTagging); tagging != "" {
+		tags, err := url.ParseQuery(tagging)
+		if err != nil {
+			return attrs, err
+		}
+		attrs.Tags = make(map[string]string, len(tags))
+		for k := range tags {
+			attrs.Tags[k] = tags.Get(k)
+		}
+	}
+	return attrs, nil
+}
+
+var errEntityTooLarge = errors.New("entity too large")
+
+// limitedReader fails instead of truncating once more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errEntityTooLarge
+	}
+	return n, err
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, errEntityTooLarge), errors.As(err, &maxBytesErr):
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+	case errs.ErrArgs.Is(err):
+		http.Error(w, errs.Unwrap(err).Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,917 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package local implements s3.Interface on top of the local filesystem.
+// Signed URLs are served by Local itself, which is an http.Handler, so
+// development and CI environments work without any object storage service.
+package local
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/s3"
+)
+
+const (
+	minPartSize int64 = 1024 * 1024 * 1        // 1MB
+	maxPartSize int64 = 1024 * 1024 * 1024 * 5 // 5GB
+	maxNumSize  int64 = 10000
+)
+
+const maxListKeys = 1000
+
+const successCode = http.StatusOK
+
+const (
+	objectDir    = "objects"
+	multipartDir = "multipart"
+	tempDir      = "temp"
+
+	dataExt = ".data"
+	metaExt = ".json"
+)
+
+var (
+	_ s3.Interface    = (*Local)(nil)
+	_ s3.ObjectStream = (*Local)(nil)
+)
+
+type Config struct {
+	// Root is the directory holding objects and in-progress multipart uploads.
+	Root string
+	// Endpoint is the external URL under which Local is served as an http.Handler,
+	// e.g. http://127.0.0.1:10002/object.
+	Endpoint string
+	// SecretKey signs the URLs handed out to clients. A random key is used when empty,
+	// which invalidates outstanding URLs on restart.
+	SecretKey  string
+	PublicRead bool
+}
+
+func NewLocal(conf Config) (*Local, error) {
+	if conf.Root == "" {
+		return nil, errs.Wrap(errors.New("local root is empty"))
+	}
+	u, err := url.Parse(conf.Endpoint)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "parse local endpoint failed", "endpoint", conf.Endpoint)
+	}
+	secret := []byte(conf.SecretKey)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, errs.Wrap(err)
+		}
+	}
+	root, err := filepath.Abs(conf.Root)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	for _, dir := range []string{objectDir, multipartDir, tempDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return nil, errs.WrapMsg(err, "create local storage dir failed", "dir", dir)
+		}
+	}
+	return &Local{
+		root:       root,
+		endpoint:   strings.TrimSuffix(conf.Endpoint, "/"),
+		prefix:     strings.TrimSuffix(u.Path, "/"),
+		secret:     secret,
+		publicRead: conf.PublicRead,
+	}, nil
+}
+
+// Local stores every object as a data file plus a JSON metadata file, addressed by
+// the SHA-1 of the object name so that arbitrary names never collide on disk.
+type Local struct {
+	root       string
+	endpoint   string
+	prefix     string
+	secret     []byte
+	publicRead bool
+	// lock guards the visibility of object data and metadata. Files are written to
+	// the temp dir first and only renamed into place while holding the write lock.
+	lock sync.RWMutex
+}
+
+// objectAttrs are the attributes given when an object is uploaded.
+type objectAttrs struct {
+	ContentType string            `json:"contentType,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+type objectMeta struct {
+	Key  string `json:"key"`
+	ETag string `json:"etag"`
+	Size int64  `json:"size"`
+	objectAttrs
+	LastModified time.Time `json:"lastModified"`
+}
+
+type uploadMeta struct {
+	Key string `json:"key"`
+	objectAttrs
+	Initiated time.Time `json:"initiated"`
+}
+
+type partMeta struct {
+	PartNumber   int       `json:"partNumber"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+func (l *Local) Engine() string {
+	return "local"
+}
+
+func (l *Local) PartLimit() (*s3.PartLimit, error) {
+	return &s3.PartLimit{
+		MinPartSize: minPartSize,
+		MaxPartSize: maxPartSize,
+		MaxNumSize:  maxNumSize,
+	}, nil
+}
+
+func (l *Local) PartSize(ctx context.Context, size int64) (int64, error) {
+	if size <= 0 {
+		return 0, errors.New("size must be greater than 0")
+	}
+	if size > maxPartSize*maxNumSize {
+		return 0, fmt.Errorf("local size must be less than the maximum allowed limit")
+	}
+	if size <= minPartSize*maxNumSize {
+		return minPartSize, nil
+	}
+	partSize := size / maxNumSize
+	if size%maxNumSize != 0 {
+		partSize++
+	}
+	return partSize, nil
+}
+
+func (l *Local) InitiateMultipartUpload(ctx context.Context, name string, opt *s3.PutOption) (*s3.InitiateMultipartUploadResult, error) {
+	if err := l.checkPutOption(opt); err != nil {
+		return nil, err
+	}
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	uploadID := hex.EncodeToString(id)
+	meta := uploadMeta{
+		Key:         name,
+		objectAttrs: newObjectAttrs(opt),
+		Initiated:   time.Now(),
+	}
+	dir := l.uploadPath(uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if err := l.writeJSON(filepath.Join(dir, "upload"+metaExt), &meta); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return &s3.InitiateMultipartUploadResult{
+		Bucket:   l.Engine(),
+		Key:      name,
+		UploadID: uploadID,
+	}, nil
+}
+
+func (l *Local) CompleteMultipartUpload(ctx context.Context, uploadID string, name string, parts []s3.Part) (*s3.CompleteMultipartUploadResult, error) {
+	upload, err := l.getUpload(uploadID, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, errs.ErrArgs.WrapMsg("no parts to complete")
+	}
+	tmp, err := l.createTemp()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	var (
+		size    int64
+		partSum = md5.New()
+	)
+	for i, part := range parts {
+		if i > 0 && part.PartNumber <= parts[i-1].PartNumber {
+			return nil, errs.ErrArgs.WrapMsg("parts must be in ascending order", "partNumber", part.PartNumber)
+		}
+		sum, n, err := l.appendPart(tmp, uploadID, part.PartNumber)
+		if err != nil {
+			return nil, err
+		}
+		if etag := hex.EncodeToString(sum); etag != formatETag(part.ETag) {
+			return nil, errs.ErrArgs.WrapMsg("part etag mismatching", "partNumber", part.PartNumber, "etag", etag, "expected", part.ETag)
+		}
+		partSum.Write(sum)
+		size += n
+	}
+	meta := objectMeta{
+		Key:          name,
+		ETag:         hex.EncodeToString(partSum.Sum(nil)) + "-" + strconv.Itoa(len(parts)),
+		Size:         size,
+		objectAttrs:  upload.objectAttrs,
+		LastModified: time.Now(),
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if err := l.commitObject(tmp.Name(), &meta); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(l.uploadPath(uploadID)); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	return &s3.CompleteMultipartUploadResult{
+		Location: l.objectURL(name),
+		Bucket:   l.Engine(),
+		Key:      name,
+		ETag:     meta.ETag,
+	}, nil
+}
+
+func (l *Local) appendPart(w io.Writer, uploadID string, partNumber int) ([]byte, int64, error) {
+	f, err := os.Open(l.partPath(uploadID, partNumber) + dataExt)
+	if err != nil {
+		return nil, 0, errs.WrapMsg(err, "open part failed", "partNumber", partNumber)
+	}
+	defer f.Close()
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(w, h), f)
+	if err != nil {
+		return nil, 0, errs.Wrap(err)
+	}
+	return h.Sum(nil), n, nil
+}
+
+func (l *Local) AuthSign(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int) (*s3.AuthSignResult, error) {
+	if _, err := l.getUpload(uploadID, name); err != nil {
+		return nil, err
+	}
+	result := s3.AuthSignResult{
+		URL:   l.objectURL(name),
+		Query: url.Values{"uploadId": {uploadID}},
+		Parts: make([]s3.SignPart, len(partNumbers)),
+	}
+	expires := time.Now().Add(expire)
+	for i, partNumber := range partNumbers {
+		query := url.Values{
+			"uploadId":   {uploadID},
+			"partNumber": {strconv.Itoa(partNumber)},
+		}
+		l.signQuery(http.MethodPut, name, expires, query)
+		query.Del("uploadId")
+		result.Parts[i] = s3.SignPart{
+			PartNumber: partNumber,
+			Query:      query,
+		}
+	}
+	return &result, nil
+}
+
+func (l *Local) PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *s3.PutOption) (*s3.PresignedPutResult, error) {
+	if err := l.checkPutOption(opt); err != nil {
+		return nil, err
+	}
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	var header http.Header
+	if opt != nil && opt.ContentType != "" {
+		header = http.Header{
+			"Content-Type": []string{opt.ContentType},
+		}
+	}
+	// Metadata, tags and content type are carried by the signed query so they can't be altered.
+	query := make(url.Values)
+	if opt != nil {
+		if opt.ContentType != "" {
+			query.Set(queryContentType, opt.ContentType)
+		}
+		for k, v := range s3.LowerMetadata(opt.Metadata) {
+			query.Set(queryMetaPrefix+k, v)
+		}
+		if len(opt.Tags) > 0 {
+			query.Set(queryTagging, s3.EncodeTags(opt.Tags))
+		}
+	}
+	l.signQuery(http.MethodPut, name, time.Now().Add(expire), query)
+	return &s3.PresignedPutResult{
+		URL:    l.objectURL(name) + "?" + query.Encode(),
+		Header: header,
+	}, nil
+}
+
+func (l *Local) DeleteObject(ctx context.Context, name string) error {
+	name, err := formatName(name)
+	if err != nil {
+		return err
+	}
+	p := l.objectPath(name)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := os.Remove(p + metaExt); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errs.Wrap(err)
+	}
+	if err := os.Remove(p + dataExt); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errs.Wrap(err)
+	}
+	return nil
+}
+
+func (l *Local) DeleteObjects(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if err := l.DeleteObject(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListObjects scans every metadata file, which is fine for the development sized
+// data sets this backend is meant for. The continuation token is the last key returned.
+func (l *Local) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+	var objects []objectMeta
+	l.lock.RLock()
+	err := filepath.WalkDir(filepath.Join(l.root, objectDir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, metaExt) {
+			return nil
+		}
+		var meta objectMeta
+		if err := readJSON(p, &meta); err != nil {
+			return err
+		}
+		if strings.HasPrefix(meta.Key, prefix) && meta.Key > continuationToken {
+			objects = append(objects, meta)
+		}
+		return nil
+	})
+	l.lock.RUnlock()
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	res := &s3.ListObjectsResult{}
+	if len(objects) > limit {
+		objects = objects[:limit]
+		res.IsTruncated = true
+		res.NextContinuationToken = objects[limit-1].Key
+	}
+	res.Objects = make([]s3.ObjectInfo, len(objects))
+	for i, meta := range objects {
+		res.Objects[i] = s3.ObjectInfo{
+			ETag:         meta.ETag,
+			Key:          meta.Key,
+			Size:         meta.Size,
+			LastModified: meta.LastModified,
+		}
+	}
+	return res, nil
+}
+
+func (l *Local) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
+	src, err := formatName(src)
+	if err != nil {
+		return nil, err
+	}
+	dst, err = formatName(dst)
+	if err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(l.root, tempDir, newTempName())
+	defer os.Remove(tmp)
+	meta, err := func() (*objectMeta, error) {
+		l.lock.RLock()
+		defer l.lock.RUnlock()
+		meta, err := l.readObjectMeta(src)
+		if err != nil {
+			return nil, err
+		}
+		// Data files are never modified in place, so a hard link is a safe copy.
+		if err := os.Link(l.objectPath(src)+dataExt, tmp); err != nil {
+			if err := copyFile(l.objectPath(src)+dataExt, tmp); err != nil {
+				return nil, err
+			}
+		}
+		return meta, nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+	meta.Key = dst
+	meta.LastModified = time.Now()
+	if err := l.commitObject(tmp, meta); err != nil {
+		return nil, err
+	}
+	return &s3.CopyObjectInfo{
+		Key:  dst,
+		ETag: meta.ETag,
+	}, nil
+}
+
+func (l *Local) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, error) {
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	meta, err := l.readObjectMeta(name)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.ObjectInfo{
+		ETag:         meta.ETag,
+		Key:          meta.Key,
+		Size:         meta.Size,
+		LastModified: meta.LastModified,
+		ContentType:  meta.ContentType,
+		Metadata:     meta.Metadata,
+		Tags:         meta.Tags,
+	}, nil
+}
+
+func (l *Local) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	name, err := formatName(name)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	meta, err := l.readObjectMeta(name)
+	if err != nil {
+		return err
+	}
+	meta.Tags = nil
+	if len(tags) > 0 {
+		meta.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			meta.Tags[k] = v
+		}
+	}
+	return l.writeJSON(l.objectPath(name)+metaExt, meta)
+}
+
+func (l *Local) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	meta, err := l.readObjectMeta(name)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(meta.Tags))
+	for k, v := range meta.Tags {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+func (l *Local) IsNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+func (l *Local) AbortMultipartUpload(ctx context.Context, uploadID string, name string) error {
+	if _, err := l.getUpload(uploadID, name); err != nil {
+		return err
+	}
+	return errs.Wrap(os.RemoveAll(l.uploadPath(uploadID)))
+}
+
+func (l *Local) ListUploadedParts(ctx context.Context, uploadID string, name string, partNumberMarker int, maxParts int) (*s3.ListUploadedPartsResult, error) {
+	if _, err := l.getUpload(uploadID, name); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(l.uploadPath(uploadID))
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	partNumbers := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		partNumber, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), metaExt))
+		if err != nil || !strings.HasSuffix(entry.Name(), metaExt) {
+			continue
+		}
+		if partNumber > partNumberMarker {
+			partNumbers = append(partNumbers, partNumber)
+		}
+	}
+	sort.Ints(partNumbers)
+	res := &s3.ListUploadedPartsResult{
+		Key:      name,
+		UploadID: uploadID,
+		MaxParts: maxParts,
+	}
+	if maxParts > 0 && len(partNumbers) > maxParts {
+		partNumbers = partNumbers[:maxParts]
+		res.NextPartNumberMarker = partNumbers[len(partNumbers)-1]
+	}
+	res.UploadedParts = make([]s3.UploadedPart, 0, len(partNumbers))
+	for _, partNumber := range partNumbers {
+		var part partMeta
+		if err := readJSON(l.partPath(uploadID, partNumber)+metaExt, &part); err != nil {
+			return nil, err
+		}
+		res.UploadedParts = append(res.UploadedParts, s3.UploadedPart{
+			PartNumber:   part.PartNumber,
+			LastModified: part.LastModified,
+			ETag:         part.ETag,
+			Size:         part.Size,
+		})
+	}
+	return res, nil
+}
+
+func (l *Local) AccessURL(ctx context.Context, name string, expire time.Duration, opt *s3.AccessURLOption) (string, error) {
+	name, err := formatName(name)
+	if err != nil {
+		return "", err
+	}
+	query := make(url.Values)
+	if opt != nil {
+		if opt.ContentType != "" {
+			query.Set("response-content-type", opt.ContentType)
+		}
+		if opt.Filename != "" {
+			query.Set("response-content-disposition", `attachment; filename*=UTF-8''`+url.PathEscape(opt.Filename))
+		}
+	}
+	if !l.publicRead {
+		if expire <= 0 {
+			expire = time.Hour * 24 * 365 * 99 // 99 years
+		} else if expire < time.Second {
+			expire = time.Second
+		}
+		l.signQuery(http.MethodGet, name, time.Now().Add(expire), query)
+	}
+	if len(query) == 0 {
+		return l.objectURL(name), nil
+	}
+	return l.objectURL(name) + "?" + query.Encode(), nil
+}
+
+func (l *Local) FormData(ctx context.Context, name string, size int64, contentType string, duration time.Duration) (*s3.FormData, error) {
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(duration)
+	policy := formPolicy{
+		Expiration:  expires.Unix(),
+		Key:         name,
+		MaxSize:     size,
+		ContentType: contentType,
+	}
+	policyJson, err := json.Marshal(policy)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "Marshal json error")
+	}
+	policyStr := base64.StdEncoding.EncodeToString(policyJson)
+	fd := &s3.FormData{
+		URL:     l.endpoint + "/",
+		File:    "file",
+		Expires: expires,
+		FormData: map[string]string{
+			"key":       name,
+			"policy":    policyStr,
+			"signature": l.sign(http.MethodPost, name, url.Values{"policy": {policyStr}}),
+		},
+		SuccessCodes: []int{successCode},
+	}
+	if contentType != "" {
+		fd.FormData["Content-Type"] = contentType
+	}
+	return fd, nil
+}
+
+func (l *Local) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	if enc, err := opt.GetEncryption(); err != nil {
+		return nil, err
+	} else if enc != nil {
+		return nil, s3.NotSupportedEncryption(l.Engine(), enc)
+	}
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	meta, f, err := l.openObject(name)
+	if err != nil {
+		return nil, err
+	}
+	if opt == nil || (opt.Offset <= 0 && opt.Length <= 0) {
+		return f, nil
+	}
+	if opt.Offset < 0 || opt.Offset >= meta.Size {
+		_ = f.Close()
+		return nil, errs.ErrArgs.WrapMsg("invalid range", "offset", opt.Offset, "size", meta.Size)
+	}
+	length := meta.Size - opt.Offset
+	if opt.Length > 0 && opt.Length < length {
+		length = opt.Length
+	}
+	return &sectionReadCloser{
+		Reader: io.NewSectionReader(f, opt.Offset, length),
+		Closer: f,
+	}, nil
+}
+
+func (l *Local) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	if err := l.checkPutOption(opt); err != nil {
+		return nil, err
+	}
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := l.putObject(name, reader, size, newObjectAttrs(opt))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.ObjectInfo{
+		ETag:         meta.ETag,
+		Key:          meta.Key,
+		Size:         meta.Size,
+		LastModified: meta.LastModified,
+	}, nil
+}
+
+type sectionReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (l *Local) objectURL(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return l.endpoint + "/" + strings.Join(segments, "/")
+}
+
+func (l *Local) objectPath(name string) string {
+	sum := sha1.Sum([]byte(name))
+	h := hex.EncodeToString(sum[:])
+	return filepath.Join(l.root, objectDir, h[:2], h)
+}
+
+func (l *Local) uploadPath(uploadID string) string {
+	return filepath.Join(l.root, multipartDir, uploadID)
+}
+
+func (l *Local) partPath(uploadID string, partNumber int) string {
+	return filepath.Join(l.uploadPath(uploadID), strconv.Itoa(partNumber))
+}
+
+func (l *Local) getUpload(uploadID string, name string) (*uploadMeta, error) {
+	if id, err := hex.DecodeString(uploadID); err != nil || len(id) != 16 {
+		return nil, errs.ErrArgs.WrapMsg("invalid upload id", "uploadID", uploadID)
+	}
+	name, err := formatName(name)
+	if err != nil {
+		return nil, err
+	}
+	var upload uploadMeta
+	if err := readJSON(filepath.Join(l.uploadPath(uploadID), "upload"+metaExt), &upload); err != nil {
+		return nil, err
+	}
+	if upload.Key != name {
+		return nil, errs.ErrArgs.WrapMsg("upload id does not belong to object", "uploadID", uploadID, "name", name)
+	}
+	return &upload, nil
+}
+
+func (l *Local) readObjectMeta(name string) (*objectMeta, error) {
+	var meta objectMeta
+	if err := readJSON(l.objectPath(name)+metaExt, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// commitObject moves the temp data file into place and publishes its metadata.
+func (l *Local) commitObject(tmp string, meta *objectMeta) error {
+	p := l.objectPath(meta.Key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errs.Wrap(err)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	metaTmp := filepath.Join(l.root, tempDir, newTempName())
+	if err := os.WriteFile(metaTmp, data, 0o644); err != nil {
+		return errs.Wrap(err)
+	}
+	defer os.Remove(metaTmp)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := os.Rename(tmp, p+dataExt); err != nil {
+		return errs.Wrap(err)
+	}
+	return errs.Wrap(os.Rename(metaTmp, p+metaExt))
+}
+
+// putObject writes r as the full content of name. When size is not negative the
+// body must match it exactly.
+func (l *Local) putObject(name string, r io.Reader, size int64, attrs objectAttrs) (*objectMeta, error) {
+	tmp, err := l.createTemp()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if size >= 0 && n != size {
+		return nil, errs.ErrArgs.WrapMsg("object size mismatching", "size", n, "expected", size)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	meta := objectMeta{
+		Key:          name,
+		ETag:         hex.EncodeToString(h.Sum(nil)),
+		Size:         n,
+		objectAttrs:  attrs,
+		LastModified: time.Now(),
+	}
+	if err := l.commitObject(tmp.Name(), &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (l *Local) putPart(uploadID string, partNumber int, r io.Reader) (*partMeta, error) {
+	tmp, err := l.createTemp()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	part := partMeta{
+		PartNumber:   partNumber,
+		ETag:         hex.EncodeToString(h.Sum(nil)),
+		Size:         n,
+		LastModified: time.Now(),
+	}
+	p := l.partPath(uploadID, partNumber)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := os.Rename(tmp.Name(), p+dataExt); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	if err := l.writeJSON(p+metaExt, &part); err != nil {
+		return nil, err
+	}
+	return &part, nil
+}
+
+func (l *Local) createTemp() (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Join(l.root, tempDir), "upload-*")
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	return f, nil
+}
+
+func (l *Local) writeJSON(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	tmp := filepath.Join(l.root, tempDir, newTempName())
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return errs.Wrap(err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		_ = os.Remove(tmp)
+		return errs.Wrap(err)
+	}
+	return nil
+}
+
+func readJSON(name string, v any) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	return errs.Wrap(json.Unmarshal(data, v))
+}
+
+func copyFile(src string, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	defer r.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return errs.Wrap(err)
+	}
+	return errs.Wrap(w.Close())
+}
+
+func newTempName() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func formatName(name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return "", errs.ErrArgs.WrapMsg("object name is empty")
+	}
+	return name, nil
+}
+
+func formatETag(etag string) string {
+	return strings.ToLower(strings.Trim(etag, `"`))
+}
+
+// checkPutOption rejects the options the local filesystem can't honour.
+func (l *Local) checkPutOption(opt *s3.PutOption) error {
+	if opt != nil && opt.Encryption != nil {
+		return s3.NotSupportedEncryption(l.Engine(), opt.Encryption)
+	}
+	return nil
+}
+
+func newObjectAttrs(opt *s3.PutOption) objectAttrs {
+	if opt == nil {
+		return objectAttrs{}
+	}
+	return objectAttrs{
+		ContentType: opt.ContentType,
+		Metadata:    s3.LowerMetadata(opt.Metadata),
+		Tags:        opt.Tags,
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/cont"
+	"github.com/smartim/tools/s3/local/localtest"
+)
+
+func TestControllerPresigned(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	ctl := cont.New(localtest.DirectCache{Impl: l}, l)
+	data := []byte("hello local storage")
+	partHash := localtest.MD5Hex(data)
+	hash := localtest.MD5Hex([]byte(partHash))
+	res, err := ctl.InitiateUploadContentType(ctx, hash, int64(len(data)), time.Minute, -1, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	localtest.UploadParts(t, res.Sign, [][]byte{data})
+	upload, err := ctl.CompleteUpload(ctx, res.UploadID, []string{partHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawURL, err := ctl.AccessURL(ctx, upload.Key, time.Minute, &s3.AccessURLOption{Filename: "a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := localtest.DoRequest(t, http.MethodGet, rawURL, http.Header{"Range": {"bytes=6-10"}}, nil)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "local" {
+		t.Fatalf("range get status %d body %q", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Fatalf("content type %q", ct)
+	}
+	resp = localtest.DoRequest(t, http.MethodGet, strings.Replace(rawURL, "signature=", "signature=0", 1), nil, nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("tampered signature status %d", resp.StatusCode)
+	}
+}
+
+func TestControllerMultipart(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	ctl := cont.New(localtest.DirectCache{Impl: l}, l)
+	parts := [][]byte{bytes.Repeat([]byte{'a'}, int(localtest.MinPartSize)), []byte("tail")}
+	partHashs := []string{localtest.MD5Hex(parts[0]), localtest.MD5Hex(parts[1])}
+	hash := localtest.MD5Hex([]byte(strings.Join(partHashs, ",")))
+	res, err := ctl.InitiateUpload(ctx, hash, localtest.MinPartSize+4, time.Minute, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Sign.Parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(res.Sign.Parts))
+	}
+	localtest.UploadParts(t, res.Sign, parts)
+	upload, err := ctl.CompleteUpload(ctx, res.UploadID, partHashs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := l.StatObject(ctx, upload.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != localtest.MinPartSize+4 {
+		t.Fatalf("object size %d", info.Size)
+	}
+	if _, err := ctl.InitiateUpload(ctx, hash, localtest.MinPartSize+4, time.Minute, -1); err == nil {
+		t.Fatal("expected hash already exists")
+	}
+}
+
+func TestMultipartAbort(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	upload, err := l.InitiateMultipartUpload(ctx, "abort/object", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign, err := l.AuthSign(ctx, upload.UploadID, upload.Key, time.Minute, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	localtest.UploadParts(t, sign, [][]byte{[]byte("one"), []byte("two")})
+	list, err := l.ListUploadedParts(ctx, upload.UploadID, upload.Key, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.UploadedParts) != 1 || list.NextPartNumberMarker != 1 {
+		t.Fatalf("unexpected list result %+v", list)
+	}
+	if err := l.AbortMultipartUpload(ctx, upload.UploadID, upload.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ListUploadedParts(ctx, upload.UploadID, upload.Key, 0, 10); !l.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestFormData(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	fd, err := l.FormData(ctx, "form/object", 8, "text/plain", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(content string) int {
+		body := bytes.NewBuffer(nil)
+		mw := multipart.NewWriter(body)
+		for k, v := range fd.FormData {
+			_ = mw.WriteField(k, v)
+		}
+		fw, _ := mw.CreateFormFile(fd.File, "object.txt")
+		_, _ = fw.Write([]byte(content))
+		_ = mw.Close()
+		return localtest.DoRequest(t, http.MethodPost, fd.URL, http.Header{"Content-Type": {mw.FormDataContentType()}}, body.Bytes()).StatusCode
+	}
+	if code := post("too large content"); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized post status %d", code)
+	}
+	if code := post("content"); code != http.StatusOK {
+		t.Fatalf("post status %d", code)
+	}
+	info, err := l.StatObject(ctx, "form/object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ETag != localtest.MD5Hex([]byte("content")) {
+		t.Fatalf("unexpected etag %s", info.ETag)
+	}
+}
+
+func TestObjectStream(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	var stream s3.ObjectStream = l
+	data := []byte("0123456789")
+	info, err := stream.PutObject(ctx, "stream/object", bytes.NewReader(data), int64(len(data)), &s3.PutOption{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ETag != localtest.MD5Hex(data) || info.Size != int64(len(data)) {
+		t.Fatalf("unexpected put result %+v", info)
+	}
+	for _, c := range []struct {
+		opt    *s3.GetOption
+		expect string
+	}{
+		{nil, "0123456789"},
+		{&s3.GetOption{Offset: 3, Length: 4}, "3456"},
+		{&s3.GetOption{Offset: 7}, "789"},
+		{&s3.GetOption{Offset: 8, Length: 10}, "89"},
+	} {
+		reader, err := stream.GetObject(ctx, "stream/object", c.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != c.expect {
+			t.Fatalf("range %+v read %q, expected %q", c.opt, body, c.expect)
+		}
+	}
+	if _, err := stream.GetObject(ctx, "stream/missing", nil); !l.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestListAndSweep(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	for _, name := range []string{"sweep/a", "sweep/b", "sweep/c", "keep/d"} {
+		if _, err := l.PutObject(ctx, name, strings.NewReader(name), -1, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var (
+		keys  []string
+		token string
+	)
+	for {
+		res, err := l.ListObjects(ctx, "sweep/", token, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, object := range res.Objects {
+			keys = append(keys, object.Key)
+		}
+		if !res.IsTruncated {
+			break
+		}
+		token = res.NextContinuationToken
+	}
+	if strings.Join(keys, ",") != "sweep/a,sweep/b,sweep/c" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	ctl := cont.New(localtest.DirectCache{Impl: l}, l)
+	if n, err := ctl.SweepPrefix(ctx, "sweep/", time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("sweep recent objects deleted %d, err %v", n, err)
+	}
+	if n, err := ctl.SweepPrefix(ctx, "sweep/", time.Now().Add(time.Second)); err != nil || n != 3 {
+		t.Fatalf("sweep deleted %d, err %v", n, err)
+	}
+	res, err := l.ListObjects(ctx, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Objects) != 1 || res.Objects[0].Key != "keep/d" {
+		t.Fatalf("unexpected remaining objects %+v", res.Objects)
+	}
+}
+
+func TestEncryptionNotSupported(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	opt := &s3.PutOption{Encryption: &s3.Encryption{Type: s3.SSES3}}
+	if _, err := l.InitiateMultipartUpload(ctx, "sse/object", opt); !errors.Is(err, s3.ErrEncryptionNotSupported) {
+		t.Fatalf("expected encryption not supported, got %v", err)
+	}
+	if _, err := l.PresignedPutObject(ctx, "sse/object", time.Minute, opt); !errors.Is(err, s3.ErrEncryptionNotSupported) {
+		t.Fatalf("expected encryption not supported, got %v", err)
+	}
+	getOpt := &s3.GetOption{Encryption: &s3.Encryption{Type: s3.SSEC, CustomerKey: bytes.Repeat([]byte{1}, 32)}}
+	if _, err := l.GetObject(ctx, "sse/object", getOpt); !errors.Is(err, s3.ErrEncryptionNotSupported) {
+		t.Fatalf("expected encryption not supported, got %v", err)
+	}
+}
+
+func TestPresignedPutContentType(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	res, err := l.PresignedPutObject(ctx, "typed.txt", time.Minute, &s3.PutOption{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The content type signed into the URL wins over the unsigned request header.
+	resp := localtest.DoRequest(t, http.MethodPut, res.URL, http.Header{"Content-Type": {"text/html"}}, []byte("typed"))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put status %d", resp.StatusCode)
+	}
+	rawURL, err := l.AccessURL(ctx, "typed.txt", time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := localtest.DoRequest(t, http.MethodGet, rawURL, nil, nil).Header.Get("Content-Type"); ct != "text/plain" {
+		t.Fatalf("content type %q", ct)
+	}
+	tampered := strings.Replace(res.URL, "content-type=text%2Fplain", "content-type=text%2Fhtml", 1)
+	if resp := localtest.DoRequest(t, http.MethodPut, tampered, nil, []byte("typed")); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("tampered content type status %d", resp.StatusCode)
+	}
+}
+
+func TestObjectMetadataAndTags(t *testing.T) {
+	ctx := context.Background()
+	l, _ := localtest.NewServer(t)
+	opt := &s3.PutOption{
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"Uploader-ID": "u1"},
+		Tags:        map[string]string{"retention": "30d"},
+	}
+	if _, err := l.PutObject(ctx, "meta/put", strings.NewReader("data"), 4, opt); err != nil {
+		t.Fatal(err)
+	}
+	info, err := l.StatObject(ctx, "meta/put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Metadata["uploader-id"] != "u1" || info.Tags["retention"] != "30d" {
+		t.Fatalf("unexpected object info %+v", info)
+	}
+	if err := l.SetObjectTags(ctx, "meta/put", map[string]string{"retention": "7d", "tenant": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := l.GetObjectTags(ctx, "meta/put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags["retention"] != "7d" || tags["tenant"] != "a" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	res, err := l.PresignedPutObject(ctx, "meta/presigned", time.Minute, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(res.URL, "=u1", "=u2", 1)
+	if resp := localtest.DoRequest(t, http.MethodPut, tampered, res.Header, []byte("data")); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("tampered metadata status %d", resp.StatusCode)
+	}
+	if resp := localtest.DoRequest(t, http.MethodPut, res.URL, res.Header, []byte("data")); resp.StatusCode != http.StatusOK {
+		t.Fatalf("presigned put status %d", resp.StatusCode)
+	}
+	info, err = l.StatObject(ctx, "meta/presigned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Metadata["uploader-id"] != "u1" || info.Tags["retention"] != "30d" {
+		t.Fatalf("unexpected presigned object info %+v", info)
+	}
+}
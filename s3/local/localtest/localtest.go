@@ -0,0 +1,128 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package localtest provides helpers for the tests running against the local backend, which
+// can be served over HTTP to stand for a remote storage.
+package localtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/local"
+)
+
+// MinPartSize is the smallest part accepted by the local backend.
+const MinPartSize = 1024 * 1024
+
+// DirectCache is a cont.S3Cache reading every object info from Impl.
+type DirectCache struct {
+	Impl s3.Interface
+}
+
+func (c DirectCache) GetKey(ctx context.Context, engine string, key string) (*s3.ObjectInfo, error) {
+	return c.Impl.StatObject(ctx, key)
+}
+
+func (c DirectCache) DelS3Key(ctx context.Context, engine string, keys ...string) error {
+	return nil
+}
+
+// New returns a local backend storing its files in a temporary directory, advertising
+// endpoint in its URLs without serving them.
+func New(t testing.TB, endpoint string) *local.Local {
+	l, err := local.NewLocal(local.Config{Root: t.TempDir(), Endpoint: endpoint, SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// NewServer returns a local backend served over HTTP, and the directory storing its files.
+func NewServer(t testing.TB) (l *local.Local, root string) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	root = t.TempDir()
+	l, err := local.NewLocal(local.Config{
+		Root:      root,
+		Endpoint:  srv.URL + "/object",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle("/object/", l)
+	return l, root
+}
+
+// MD5Hex returns the hex encoded MD5 of data, the ETag of a part.
+func MD5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DoRequest sends a request, the response body is closed with the test.
+func DoRequest(t testing.TB, method string, rawURL string, header http.Header, body []byte) *http.Response {
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// UploadParts uploads parts to the URLs of sign, in order, and checks their ETag.
+func UploadParts(t testing.TB, sign *s3.AuthSignResult, parts [][]byte) {
+	for i, part := range sign.Parts {
+		rawURL := part.URL
+		if rawURL == "" {
+			rawURL = sign.URL
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := u.Query()
+		for k, v := range sign.Query {
+			query[k] = v
+		}
+		for k, v := range part.Query {
+			query[k] = v
+		}
+		u.RawQuery = query.Encode()
+		resp := DoRequest(t, http.MethodPut, u.String(), part.Header, parts[i])
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("upload part %d status %d", part.PartNumber, resp.StatusCode)
+		}
+		if etag := strings.Trim(resp.Header.Get("ETag"), `"`); etag != MD5Hex(parts[i]) {
+			t.Fatalf("part %d etag %s", part.PartNumber, etag)
+		}
+	}
+}
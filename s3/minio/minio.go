@@ -31,9 +31,12 @@ import (
 
 	"github.com/minio/minio-go/v7"
 	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/imageproc"
 
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/minio/minio-go/v7/pkg/signer"
+	minioTags "github.com/minio/minio-go/v7/pkg/tags"
 	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/log"
 )
@@ -48,16 +51,23 @@ const (
 	maxNumSize  int64 = 10000
 )
 
+const maxListKeys = 1000
+
 const (
 	maxImageWidth      = 1024
 	maxImageHeight     = 1024
 	maxImageSize       = 1024 * 1024 * 50
-	imageThumbnailPath = "openim/thumbnail"
+	imageThumbnailPath = imageproc.DefaultPrefix
 )
 
 const successCode = http.StatusOK
 
-var _ s3.Interface = (*Minio)(nil)
+var (
+	_ s3.Interface           = (*Minio)(nil)
+	_ s3.ObjectStream        = (*Minio)(nil)
+	_ s3.EncryptedPartSigner = (*Minio)(nil)
+	_ s3.EncryptedStatter    = (*Minio)(nil)
+)
 
 type Config struct {
 	Bucket          string
@@ -209,7 +219,11 @@ func (m *Minio) InitiateMultipartUpload(ctx context.Context, name string, opt *s
 	if err := m.initMinio(ctx); err != nil {
 		return nil, err
 	}
-	uploadID, err := m.core.NewMultipartUpload(ctx, m.bucket, name, minio.PutObjectOptions{})
+	opts, err := putObjectOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	uploadID, err := m.core.NewMultipartUpload(ctx, m.bucket, name, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -262,9 +276,21 @@ func (m *Minio) PartSize(ctx context.Context, size int64) (int64, error) {
 }
 
 func (m *Minio) AuthSign(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int) (*s3.AuthSignResult, error) {
+	return m.AuthSignEncrypted(ctx, uploadID, name, expire, partNumbers, nil)
+}
+
+func (m *Minio) AuthSignEncrypted(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int, enc *s3.Encryption) (*s3.AuthSignResult, error) {
 	if err := m.initMinio(ctx); err != nil {
 		return nil, err
 	}
+	sse, err := serverSide(enc)
+	if err != nil {
+		return nil, err
+	}
+	// Only SSE-C has to be repeated on part uploads, the others are set when the upload is initiated.
+	if sse != nil && sse.Type() != encrypt.SSEC {
+		sse = nil
+	}
 	creds, err := m.opts.Creds.Get()
 	if err != nil {
 		return nil, err
@@ -281,6 +307,9 @@ func (m *Minio) AuthSign(ctx context.Context, uploadID string, name string, expi
 			return nil, err
 		}
 		request.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+		if sse != nil {
+			sse.Marshal(request.Header)
+		}
 		request = signer.SignV4Trailer(*request, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, m.location, nil)
 		result.Parts[i] = s3.SignPart{
 			PartNumber: partNumber,
@@ -298,14 +327,24 @@ func (m *Minio) PresignedPutObject(ctx context.Context, name string, expire time
 	if err := m.initMinio(ctx); err != nil {
 		return nil, err
 	}
-	rawURL, err := m.sign.PresignedPutObject(ctx, m.bucket, name, expire)
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	sse, err := serverSide(enc)
+	if err != nil {
+		return nil, err
+	}
+	// The headers are signed, the client has to send them as returned.
+	header := putObjectHeader(opt, sse)
+	rawURL, err := m.sign.PresignHeader(ctx, http.MethodPut, m.bucket, name, expire, nil, header)
 	if err != nil {
 		return nil, err
 	}
 	if m.prefix != "" {
 		rawURL.Path = path.Join(m.prefix, rawURL.Path)
 	}
-	return &s3.PresignedPutResult{URL: rawURL.String()}, nil
+	return &s3.PresignedPutResult{URL: rawURL.String(), Header: header}, nil
 }
 
 func (m *Minio) DeleteObject(ctx context.Context, name string) error {
@@ -325,11 +364,74 @@ func (m *Minio) DeleteObject(ctx context.Context, name string) error {
 	return m.core.Client.RemoveObject(ctx, m.bucket, name, minio.RemoveObjectOptions{})
 }
 
+func (m *Minio) DeleteObjects(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if err := m.initMinio(ctx); err != nil {
+		return err
+	}
+	objectsCh := make(chan minio.ObjectInfo, len(names))
+	for _, name := range names {
+		objectsCh <- minio.ObjectInfo{Key: name}
+	}
+	close(objectsCh)
+	for res := range m.core.Client.RemoveObjects(ctx, m.bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		if res.Err != nil && !m.IsNotFound(res.Err) {
+			return errs.WrapMsg(res.Err, "remove object failed", "name", res.ObjectName)
+		}
+	}
+	if err := m.cache.DelObjectImageInfoKey(ctx, names...); err != nil {
+		log.ZError(ctx, "DelObjectImageInfoKey failed", err, "keys", names)
+	}
+	return nil
+}
+
+func (m *Minio) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	if err := m.initMinio(ctx); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+	result, err := m.core.ListObjectsV2(m.bucket, prefix, "", continuationToken, "", limit)
+	if err != nil {
+		return nil, err
+	}
+	res := &s3.ListObjectsResult{
+		Objects:               make([]s3.ObjectInfo, len(result.Contents)),
+		NextContinuationToken: result.NextContinuationToken,
+		IsTruncated:           result.IsTruncated,
+	}
+	for i, object := range result.Contents {
+		res.Objects[i] = s3.ObjectInfo{
+			ETag:         strings.ToLower(object.ETag),
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		}
+	}
+	return res, nil
+}
+
 func (m *Minio) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, error) {
+	return m.StatObjectEncrypted(ctx, name, nil)
+}
+
+// StatObjectEncrypted stats an object written with the SSE-C key of enc.
+func (m *Minio) StatObjectEncrypted(ctx context.Context, name string, enc *s3.Encryption) (*s3.ObjectInfo, error) {
 	if err := m.initMinio(ctx); err != nil {
 		return nil, err
 	}
-	info, err := m.core.Client.StatObject(ctx, m.bucket, name, minio.StatObjectOptions{})
+	var opts minio.StatObjectOptions
+	if enc != nil && enc.Type == s3.SSEC {
+		sse, err := serverSide(enc)
+		if err != nil {
+			return nil, err
+		}
+		opts.ServerSideEncryption = sse
+	}
+	info, err := m.core.Client.StatObject(ctx, m.bucket, name, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -338,9 +440,36 @@ func (m *Minio) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, er
 		Key:          info.Key,
 		Size:         info.Size,
 		LastModified: info.LastModified,
+		ContentType:  info.ContentType,
+		Metadata:     s3.LowerMetadata(info.UserMetadata),
 	}, nil
 }
 
+func (m *Minio) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	if err := m.initMinio(ctx); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return m.core.Client.RemoveObjectTagging(ctx, m.bucket, name, minio.RemoveObjectTaggingOptions{})
+	}
+	objectTags, err := minioTags.MapToObjectTags(tags)
+	if err != nil {
+		return err
+	}
+	return m.core.Client.PutObjectTagging(ctx, m.bucket, name, objectTags, minio.PutObjectTaggingOptions{})
+}
+
+func (m *Minio) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	if err := m.initMinio(ctx); err != nil {
+		return nil, err
+	}
+	objectTags, err := m.core.Client.GetObjectTagging(ctx, m.bucket, name, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return objectTags.ToMap(), nil
+}
+
 func (m *Minio) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
 	if err := m.initMinio(ctx); err != nil {
 		return nil, err
@@ -464,6 +593,54 @@ func (m *Minio) getObjectData(ctx context.Context, name string, limit int64) ([]
 	return io.ReadAll(io.LimitReader(object, limit))
 }
 
+func (m *Minio) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	if err := m.initMinio(ctx); err != nil {
+		return nil, err
+	}
+	opts := minio.GetObjectOptions{}
+	if rng := opt.Range(); rng != "" {
+		opts.Set("Range", rng)
+	}
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, err
+	}
+	if opts.ServerSideEncryption, err = serverSide(enc); err != nil {
+		return nil, err
+	}
+	object, err := m.core.Client.GetObject(ctx, m.bucket, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	// minio fetches lazily, stat first so that a missing object is reported here.
+	if _, err := object.Stat(); err != nil {
+		_ = object.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+func (m *Minio) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	if err := m.initMinio(ctx); err != nil {
+		return nil, err
+	}
+	opts, err := putObjectOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	info, err := m.core.Client.PutObject(ctx, m.bucket, name, reader, size, opts)
+	if err != nil {
+		return nil, err
+	}
+	m.delObjectImageInfoKey(ctx, name, info.Size)
+	return &s3.ObjectInfo{
+		ETag:         strings.ToLower(info.ETag),
+		Key:          info.Key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+	}, nil
+}
+
 func (m *Minio) FormData(ctx context.Context, name string, size int64, contentType string, duration time.Duration) (*s3.FormData, error) {
 	if err := m.initMinio(ctx); err != nil {
 		return nil, err
@@ -520,7 +697,7 @@ func (m *Minio) GetImageThumbnailKey(ctx context.Context, name string) (string,
 	if !info.IsImg {
 		return "", errs.New("object not image").Wrap()
 	}
-	thumbnailWidth, thumbnailHeight := getThumbnailSize(img)
+	thumbnailWidth, thumbnailHeight := imageproc.ThumbnailSize(img)
 
 	cacheKey := path.Join(imageThumbnailPath, info.Etag, fmt.Sprintf("image_w%d_h%d.%s", thumbnailWidth, thumbnailHeight, info.Format))
 	return cacheKey, nil
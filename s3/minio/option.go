@@ -0,0 +1,76 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package minio
+
+import (
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/smartim/tools/s3"
+)
+
+func serverSide(enc *s3.Encryption) (encrypt.ServerSide, error) {
+	if enc == nil {
+		return nil, nil
+	}
+	if err := enc.Validate(); err != nil {
+		return nil, err
+	}
+	switch enc.Type {
+	case s3.SSES3:
+		return encrypt.NewSSE(), nil
+	case s3.SSEKMS:
+		return encrypt.NewSSEKMS(enc.KMSKeyID, nil)
+	default:
+		return encrypt.NewSSEC(enc.CustomerKey)
+	}
+}
+
+func putObjectOptions(opt *s3.PutOption) (minio.PutObjectOptions, error) {
+	var opts minio.PutObjectOptions
+	if opt == nil {
+		return opts, nil
+	}
+	sse, err := serverSide(opt.Encryption)
+	if err != nil {
+		return opts, err
+	}
+	opts.ContentType = opt.ContentType
+	opts.ServerSideEncryption = sse
+	opts.UserMetadata = opt.Metadata
+	opts.UserTags = opt.Tags
+	return opts, nil
+}
+
+// putObjectHeader returns the headers a presigned put has to be sent with.
+func putObjectHeader(opt *s3.PutOption, sse encrypt.ServerSide) http.Header {
+	header := make(http.Header)
+	if sse != nil {
+		sse.Marshal(header)
+	}
+	if opt != nil {
+		for k, v := range opt.Metadata {
+			header.Set("X-Amz-Meta-"+k, v)
+		}
+		if len(opt.Tags) > 0 {
+			header.Set("X-Amz-Tagging", s3.EncodeTags(opt.Tags))
+		}
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return header
+}
@@ -19,9 +19,6 @@ import (
 	"context"
 	"fmt"
 	"image"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"net/url"
 	"path/filepath"
 	"strings"
@@ -31,6 +28,7 @@ import (
 	"github.com/smartim/tools/errs"
 	"github.com/smartim/tools/log"
 	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/imageproc"
 )
 
 func (m *Minio) getImageThumbnailURL(ctx context.Context, name string, expire time.Duration, opt *s3.Image) (string, error) {
@@ -78,17 +76,9 @@ func (m *Minio) getImageThumbnailURL(ctx context.Context, name string, expire ti
 				return "", err
 			}
 		}
-		thumbnail := resizeImage(img, opt.Width, opt.Height)
+		thumbnail := imageproc.Resize(img, opt.Width, opt.Height, imageproc.ModeFit)
 		buf := bytes.NewBuffer(nil)
-		switch opt.Format {
-		case formatPng:
-			err = png.Encode(buf, thumbnail)
-		case formatJpeg:
-			err = jpeg.Encode(buf, thumbnail, &jpeg.Options{Quality: 40})
-		case formatGif:
-			err = gif.Encode(buf, thumbnail, nil)
-		}
-		if err != nil {
+		if err = imageproc.Encode(buf, thumbnail, opt.Format, 40); err != nil {
 			return "", errs.WrapMsg(err, "encode failed", "type", opt.Format)
 		}
 		cacheKey := filepath.Join(imageThumbnailPath, info.Etag, fmt.Sprintf("image_w%d_h%d.%s", opt.Width, opt.Height, opt.Format))
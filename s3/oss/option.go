@@ -0,0 +1,79 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/smartim/tools/s3"
+)
+
+// putOptions returns the request options of an upload along with the headers they set,
+// which presigned uploads have to be sent with. OSS has no customer-provided keys, so SSE-C is rejected.
+func (o *OSS) putOptions(opt *s3.PutOption) ([]oss.Option, http.Header, error) {
+	enc, err := opt.GetEncryption()
+	if err != nil {
+		return nil, nil, err
+	}
+	header := make(http.Header)
+	if enc != nil {
+		switch enc.Type {
+		case s3.SSES3:
+			header.Set(oss.HTTPHeaderOssServerSideEncryption, "AES256")
+		case s3.SSEKMS:
+			header.Set(oss.HTTPHeaderOssServerSideEncryption, "KMS")
+			if enc.KMSKeyID != "" {
+				header.Set(oss.HTTPHeaderOssServerSideEncryptionKeyID, enc.KMSKeyID)
+			}
+		default:
+			return nil, nil, s3.NotSupportedEncryption(o.Engine(), enc)
+		}
+	}
+	if opt != nil {
+		if opt.ContentType != "" {
+			header.Set(oss.HTTPHeaderContentType, opt.ContentType)
+		}
+		for k, v := range opt.Metadata {
+			header.Set(oss.HTTPHeaderOssMetaPrefix+k, v)
+		}
+		if len(opt.Tags) > 0 {
+			header.Set(oss.HTTPHeaderOssTagging, s3.EncodeTags(opt.Tags))
+		}
+	}
+	if len(header) == 0 {
+		return nil, nil, nil
+	}
+	opts := make([]oss.Option, 0, len(header))
+	for k := range header {
+		opts = append(opts, oss.SetHeader(k, header.Get(k)))
+	}
+	return opts, header, nil
+}
+
+// metadataFromHeader returns the user metadata of a HEAD response.
+func metadataFromHeader(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for k, v := range header {
+		if len(v) > 0 && strings.HasPrefix(k, oss.HTTPHeaderOssMetaPrefix) {
+			metadata[strings.ToLower(k[len(oss.HTTPHeaderOssMetaPrefix):])] = v[0]
+		}
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
@@ -42,6 +42,8 @@ const (
 	maxNumSize  int64 = 10000
 )
 
+const maxListKeys = 1000
+
 const (
 	imagePng  = "png"
 	imageJpg  = "jpg"
@@ -52,7 +54,10 @@ const (
 
 const successCode = http.StatusOK
 
-var _ s3.Interface = (*OSS)(nil)
+var (
+	_ s3.Interface    = (*OSS)(nil)
+	_ s3.ObjectStream = (*OSS)(nil)
+)
 
 type Config struct {
 	Endpoint        string
@@ -109,9 +114,9 @@ func (o *OSS) PartLimit() (*s3.PartLimit, error) {
 }
 
 func (o *OSS) InitiateMultipartUpload(ctx context.Context, name string, opt *s3.PutOption) (*s3.InitiateMultipartUploadResult, error) {
-	var opts []oss.Option
-	if opt != nil && opt.ContentType != "" {
-		opts = append(opts, oss.ContentType(opt.ContentType))
+	opts, _, err := o.putOptions(opt)
+	if err != nil {
+		return nil, err
 	}
 	result, err := o.bucket.InitiateMultipartUpload(name, opts...)
 	if err != nil {
@@ -198,15 +203,10 @@ func (o *OSS) AuthSign(ctx context.Context, uploadID string, name string, expire
 }
 
 func (o *OSS) PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *s3.PutOption) (*s3.PresignedPutResult, error) {
-	var (
-		opts   []oss.Option
-		header http.Header
-	)
-	if opt != nil && opt.ContentType != "" {
-		opts = append(opts, oss.ContentType(opt.ContentType))
-		header = http.Header{
-			"Content-Type": []string{opt.ContentType},
-		}
+	// The headers are signed, the client has to send them as returned.
+	opts, header, err := o.putOptions(opt)
+	if err != nil {
+		return nil, err
 	}
 	rawURL, err := o.bucket.SignURL(name, http.MethodPut, int64(expire/time.Second), opts...)
 	if err != nil {
@@ -219,11 +219,12 @@ func (o *OSS) PresignedPutObject(ctx context.Context, name string, expire time.D
 }
 
 func (o *OSS) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, error) {
-	header, err := o.bucket.GetObjectMeta(name)
+	// Unlike GetObjectMeta, the detailed meta includes the user metadata.
+	header, err := o.bucket.GetObjectDetailedMeta(name)
 	if err != nil {
 		return nil, err
 	}
-	res := &s3.ObjectInfo{Key: name}
+	res := &s3.ObjectInfo{Key: name, ContentType: header.Get("Content-Type"), Metadata: metadataFromHeader(header)}
 	if res.ETag = strings.ToLower(strings.ReplaceAll(header.Get("ETag"), `"`, ``)); res.ETag == "" {
 		return nil, errs.Wrap(errors.New("StatObject etag not found"))
 	}
@@ -249,10 +250,83 @@ func (o *OSS) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, erro
 	return res, nil
 }
 
+func (o *OSS) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return o.bucket.DeleteObjectTagging(name)
+	}
+	tagging := oss.Tagging{Tags: make([]oss.Tag, 0, len(tags))}
+	for key, value := range tags {
+		tagging.Tags = append(tagging.Tags, oss.Tag{Key: key, Value: value})
+	}
+	return o.bucket.PutObjectTagging(name, tagging)
+}
+
+func (o *OSS) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	res, err := o.bucket.GetObjectTagging(name)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(res.Tags))
+	for _, tag := range res.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
 func (o *OSS) DeleteObject(ctx context.Context, name string) error {
 	return o.bucket.DeleteObject(name)
 }
 
+func (o *OSS) DeleteObjects(ctx context.Context, names []string) error {
+	for start := 0; start < len(names); start += maxListKeys {
+		end := min(start+maxListKeys, len(names))
+		result, err := o.bucket.DeleteObjects(names[start:end])
+		if err != nil {
+			return errs.WrapMsg(err, "DeleteObjects error")
+		}
+		if len(result.DeletedObjects) != end-start {
+			deleted := make(map[string]struct{}, len(result.DeletedObjects))
+			for _, key := range result.DeletedObjects {
+				deleted[key] = struct{}{}
+			}
+			for _, name := range names[start:end] {
+				if _, ok := deleted[name]; !ok {
+					return errs.Wrap(fmt.Errorf("DeleteObjects %s failed", name))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (o *OSS) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+	opts := []oss.Option{oss.Prefix(prefix), oss.MaxKeys(limit)}
+	if continuationToken != "" {
+		opts = append(opts, oss.ContinuationToken(continuationToken))
+	}
+	result, err := o.bucket.ListObjectsV2(opts...)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "ListObjects error")
+	}
+	res := &s3.ListObjectsResult{
+		Objects:               make([]s3.ObjectInfo, len(result.Objects)),
+		NextContinuationToken: result.NextContinuationToken,
+		IsTruncated:           result.IsTruncated,
+	}
+	for i, object := range result.Objects {
+		res.Objects[i] = s3.ObjectInfo{
+			ETag:         strings.ToLower(strings.ReplaceAll(object.ETag, `"`, ``)),
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		}
+	}
+	return res, nil
+}
+
 func (o *OSS) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
 	result, err := o.bucket.CopyObject(src, dst)
 	if err != nil {
@@ -370,6 +444,63 @@ func (o *OSS) AccessURL(ctx context.Context, name string, expire time.Duration,
 	return getURL(o.um, o.bucket.BucketName, name, params).String(), nil
 }
 
+func (o *OSS) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	if enc, err := opt.GetEncryption(); err != nil {
+		return nil, err
+	} else if enc != nil {
+		return nil, s3.NotSupportedEncryption(o.Engine(), enc)
+	}
+	opts := []oss.Option{oss.WithContext(ctx)}
+	if rng := opt.Range(); rng != "" {
+		opts = append(opts, oss.NormalizedRange(strings.TrimPrefix(rng, "bytes=")))
+	}
+	return o.bucket.GetObject(name, opts...)
+}
+
+func (o *OSS) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	opts, _, err := o.putOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	var header http.Header
+	opts = append(opts, oss.WithContext(ctx), oss.GetResponseHeader(&header))
+	if size < 0 {
+		if n, err := oss.GetReaderLen(reader); err == nil {
+			size = n
+		}
+	}
+	if size >= 0 {
+		opts = append(opts, oss.ContentLength(size))
+	} else {
+		// OSS does not report the stored size, the bytes sent are counted instead.
+		reader = &countReader{reader: reader}
+	}
+	if err := o.bucket.PutObject(name, reader, opts...); err != nil {
+		return nil, errs.WrapMsg(err, "PutObject error")
+	}
+	if body, ok := reader.(*countReader); ok {
+		size = body.n
+	}
+	return &s3.ObjectInfo{
+		ETag:         strings.ToLower(strings.ReplaceAll(header.Get("ETag"), `"`, ``)),
+		Key:          name,
+		Size:         size,
+		LastModified: time.Now(),
+	}, nil
+}
+
+// countReader counts the bytes read from reader.
+type countReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
 func (o *OSS) FormData(ctx context.Context, name string, size int64, contentType string, duration time.Duration) (*s3.FormData, error) {
 	// https://help.aliyun.com/zh/oss/developer-reference/postobject?spm=a2c4g.11186623.0.0.1cb83cebkP55nn
 	expires := time.Now().Add(duration)
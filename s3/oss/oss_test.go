@@ -0,0 +1,70 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPutObjectSize(t *testing.T) {
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = len(data)
+		w.Header().Set("ETag", `"ABC"`)
+	}))
+	defer srv.Close()
+	o, err := NewOSS(Config{Endpoint: srv.URL, Bucket: "bucket", BucketURL: srv.URL + "/bucket", AccessKeyID: "id", AccessKeySecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A reader of unknown length is counted, one with a length is measured.
+	for _, reader := range []io.Reader{io.MultiReader(strings.NewReader("hello"), strings.NewReader(" world")), strings.NewReader("hello world")} {
+		info, err := o.PutObject(context.Background(), "a.txt", reader, -1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != 11 || received != 11 || info.ETag != "abc" {
+			t.Fatalf("size %d, received %d, etag %s", info.Size, received, info.ETag)
+		}
+	}
+}
+
+func TestObjectContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never answer before the client gives up.
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	o, err := NewOSS(Config{Endpoint: srv.URL, Bucket: "bucket", BucketURL: srv.URL + "/bucket", AccessKeyID: "id", AccessKeySecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err := o.GetObject(ctx, "a.txt", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("get object returned %v", err)
+	}
+	if _, err := o.PutObject(ctx, "a.txt", strings.NewReader("hello"), 5, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("put object returned %v", err)
+	}
+}
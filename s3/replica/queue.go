@@ -0,0 +1,348 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replica
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/smartim/tools/log"
+)
+
+const (
+	opCopy   = "copy"
+	opDelete = "delete"
+	opTags   = "tags"
+)
+
+const (
+	defaultWorkers       = 4
+	defaultQueueSize     = 1024
+	defaultMaxRetries    = 5
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = time.Minute * 5
+)
+
+var (
+	// ErrQueueFull is reported to the failure handler when a replication is dropped because the queue is full.
+	ErrQueueFull = errors.New("replication queue full")
+	// ErrClosed is returned by Flush once the Replica is closed.
+	ErrClosed = errors.New("replica closed")
+)
+
+// Failure describes a replication given up on.
+type Failure struct {
+	// Op is the replicated operation: "copy", "delete" or "tags".
+	Op string
+	// Name is the object key.
+	Name string
+	// Target is the index of the secondary in the list given to New.
+	Target int
+	// Attempts is the number of attempts made.
+	Attempts int
+	// Err is the last error.
+	Err error
+}
+
+// Option configures a Replica.
+type Option func(*queue)
+
+// WithWorkers sets the number of concurrent replications, 4 by default.
+// Operations on the same key are always applied by the same worker, in order.
+func WithWorkers(n int) Option {
+	return func(q *queue) {
+		if n > 0 {
+			q.workers = n
+		}
+	}
+}
+
+// WithQueueSize sets how many replications each worker buffers, 1024 by default.
+// Replications beyond that are dropped and reported with ErrQueueFull.
+func WithQueueSize(n int) Option {
+	return func(q *queue) {
+		if n > 0 {
+			q.queueSize = n
+		}
+	}
+}
+
+// WithRetry sets how many times a failed replication is retried and the delay before the
+// first retry, doubled for every following one up to 5 minutes. 5 retries after 1s by default.
+// The later replications of the key wait for the retry, those of other keys go on meanwhile.
+func WithRetry(maxRetries int, delay time.Duration) Option {
+	return func(q *queue) {
+		if maxRetries >= 0 {
+			q.maxRetries = maxRetries
+		}
+		if delay > 0 {
+			q.retryDelay = delay
+		}
+	}
+}
+
+// WithKeyFilter only replicates the keys for which filter returns true, for example to skip
+// the temporary objects of an upload controller.
+func WithKeyFilter(filter func(name string) bool) Option {
+	return func(q *queue) {
+		q.filter = filter
+	}
+}
+
+// WithFailureHandler sets a function called with every replication given up on,
+// which can be used to record it for a later reconciliation.
+func WithFailureHandler(fn func(ctx context.Context, failure *Failure)) Option {
+	return func(q *queue) {
+		q.onFailure = fn
+	}
+}
+
+type task struct {
+	ctx     context.Context
+	op      string
+	name    string
+	tags    map[string]string
+	target  int
+	attempt int
+}
+
+// queue runs replication tasks with a fixed set of workers. Tasks are routed to a worker by
+// key so that operations on a key are applied in order, a failed task parks its key until it is
+// retried after a backoff while the worker goes on with the other keys.
+type queue struct {
+	workers    int
+	queueSize  int
+	maxRetries int
+	retryDelay time.Duration
+	filter     func(name string) bool
+	onFailure  func(ctx context.Context, failure *Failure)
+
+	targets int
+	run     func(ctx context.Context, t *task) error
+	chans   []chan *task
+	closed  chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	lock    sync.Mutex
+	pending int
+	waiters []chan struct{}
+}
+
+func newQueue(targets int, run func(ctx context.Context, t *task) error, opts ...Option) *queue {
+	q := &queue{
+		workers:    defaultWorkers,
+		queueSize:  defaultQueueSize,
+		maxRetries: defaultMaxRetries,
+		retryDelay: defaultRetryDelay,
+		targets:    targets,
+		run:        run,
+		closed:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.chans = make([]chan *task, q.workers)
+	for i := range q.chans {
+		q.chans[i] = make(chan *task, q.queueSize)
+		w := &worker{q: q, tasks: q.chans[i], retry: make(chan *task), parked: make(map[taskKey][]*task)}
+		q.wg.Add(1)
+		go w.loop()
+	}
+	return q
+}
+
+// add schedules op on the named object for every secondary without blocking the caller.
+func (q *queue) add(ctx context.Context, op string, name string, tags map[string]string) {
+	if q.filter != nil && !q.filter(name) {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	for target := 0; target < q.targets; target++ {
+		t := &task{ctx: ctx, op: op, name: name, tags: tags, target: target}
+		q.incr()
+		select {
+		case <-q.closed:
+			q.decr()
+		case q.chanOf(name) <- t:
+		default:
+			q.fail(t, ErrQueueFull)
+		}
+	}
+}
+
+func (q *queue) chanOf(name string) chan *task {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return q.chans[h.Sum32()%uint32(len(q.chans))]
+}
+
+// taskKey identifies the operations applied in order: those on a key for a secondary.
+type taskKey struct {
+	name   string
+	target int
+}
+
+// worker runs the tasks of the keys routed to it. It is only used by its own goroutine.
+type worker struct {
+	q     *queue
+	tasks chan *task
+	// retry receives the failed tasks once their backoff elapsed.
+	retry chan *task
+	// parked holds, by key waiting for the retry of a failed task, the tasks received meanwhile.
+	parked map[taskKey][]*task
+}
+
+func (w *worker) loop() {
+	defer w.q.wg.Done()
+	for {
+		select {
+		case <-w.q.closed:
+			return
+		case t := <-w.tasks:
+			if key := (taskKey{name: t.name, target: t.target}); w.isParked(key) {
+				w.parked[key] = append(w.parked[key], t)
+				continue
+			}
+			w.run(t)
+		case t := <-w.retry:
+			w.run(t)
+		}
+	}
+}
+
+func (w *worker) isParked(key taskKey) bool {
+	_, ok := w.parked[key]
+	return ok
+}
+
+// run executes t then the tasks parked behind its key, until one of them fails and parks the key again.
+func (w *worker) run(t *task) {
+	key := taskKey{name: t.name, target: t.target}
+	for {
+		if w.exec(t) {
+			return
+		}
+		tasks, ok := w.parked[key]
+		if !ok {
+			return
+		}
+		if len(tasks) == 0 {
+			delete(w.parked, key)
+			return
+		}
+		t = tasks[0]
+		w.parked[key] = tasks[1:]
+	}
+}
+
+// exec runs t once and reports whether it failed and was scheduled for a retry, in which case
+// its key stays parked so that the later operations on the key wait for it instead of overtaking it.
+func (w *worker) exec(t *task) bool {
+	q := w.q
+	t.attempt++
+	err := q.run(t.ctx, t)
+	if err == nil {
+		log.ZDebug(t.ctx, "object replicated", "op", t.op, "name", t.name, "target", t.target)
+		q.decr()
+		return false
+	}
+	if t.attempt > q.maxRetries {
+		q.fail(t, err)
+		return false
+	}
+	delay := q.retryDelay << (t.attempt - 1)
+	if delay <= 0 || delay > defaultMaxRetryDelay {
+		delay = defaultMaxRetryDelay
+	}
+	log.ZWarn(t.ctx, "replicate object, retry later", err, "op", t.op, "name", t.name, "target", t.target, "attempt", t.attempt, "delay", delay)
+	if key := (taskKey{name: t.name, target: t.target}); !w.isParked(key) {
+		w.parked[key] = []*task{}
+	}
+	time.AfterFunc(delay, func() {
+		select {
+		case <-q.closed:
+		case w.retry <- t:
+		}
+	})
+	return true
+}
+
+func (q *queue) fail(t *task, err error) {
+	log.ZError(t.ctx, "replicate object failed", err, "op", t.op, "name", t.name, "target", t.target, "attempts", t.attempt)
+	if q.onFailure != nil {
+		q.onFailure(t.ctx, &Failure{Op: t.op, Name: t.name, Target: t.target, Attempts: t.attempt, Err: err})
+	}
+	q.decr()
+}
+
+func (q *queue) incr() {
+	q.lock.Lock()
+	q.pending++
+	q.lock.Unlock()
+}
+
+func (q *queue) decr() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		for _, ch := range q.waiters {
+			close(ch)
+		}
+		q.waiters = nil
+	}
+}
+
+func (q *queue) size() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.pending
+}
+
+func (q *queue) flush(ctx context.Context) error {
+	q.lock.Lock()
+	select {
+	case <-q.closed:
+		q.lock.Unlock()
+		return ErrClosed
+	default:
+	}
+	if q.pending == 0 {
+		q.lock.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	q.waiters = append(q.waiters, ch)
+	q.lock.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.closed:
+		return ErrClosed
+	case <-ch:
+		return nil
+	}
+}
+
+func (q *queue) close() {
+	q.once.Do(func() {
+		close(q.closed)
+	})
+	q.wg.Wait()
+}
@@ -0,0 +1,281 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replica wraps a primary s3.Interface so that the objects written through it are
+// copied asynchronously to one or more secondaries, for example while migrating between
+// providers. Reads fall back to the secondaries for objects missing on the primary.
+package replica
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/smartim/tools/errs"
+	"github.com/smartim/tools/s3"
+)
+
+var (
+	_ s3.Interface    = (*Replica)(nil)
+	_ s3.ObjectStream = (*Replica)(nil)
+)
+
+// New returns a Replica writing to primary and replicating to secondaries.
+// Every backend must implement s3.ObjectStream, as the content is copied through the server.
+// Objects written with SSE-C can't be read back without the key and are not replicated.
+// Call Close to stop the replication workers.
+func New(primary s3.Interface, secondaries []s3.Interface, opts ...Option) (*Replica, error) {
+	if primary == nil {
+		return nil, errs.ErrArgs.WrapMsg("primary is nil")
+	}
+	if len(secondaries) == 0 {
+		return nil, errs.ErrArgs.WrapMsg("no secondary")
+	}
+	for _, impl := range append([]s3.Interface{primary}, secondaries...) {
+		if _, ok := impl.(s3.ObjectStream); !ok {
+			return nil, errs.ErrArgs.WrapMsg("s3 engine does not support streaming", "engine", impl.Engine())
+		}
+	}
+	r := &Replica{
+		primary:     primary,
+		secondaries: secondaries,
+	}
+	r.queue = newQueue(len(secondaries), r.replicate, opts...)
+	return r, nil
+}
+
+// Replica is an s3.Interface that serves every request from the primary and replays the
+// writes on the secondaries in the background.
+type Replica struct {
+	primary     s3.Interface
+	secondaries []s3.Interface
+	queue       *queue
+}
+
+// Flush waits until every pending replication succeeded or was given up.
+func (r *Replica) Flush(ctx context.Context) error {
+	return r.queue.flush(ctx)
+}
+
+// Close stops the replication workers. Pending replications, including scheduled retries, are dropped.
+func (r *Replica) Close() {
+	r.queue.close()
+}
+
+// Pending returns the number of replications not done yet.
+func (r *Replica) Pending() int {
+	return r.queue.size()
+}
+
+func (r *Replica) Engine() string {
+	return r.primary.Engine()
+}
+
+func (r *Replica) PartLimit() (*s3.PartLimit, error) {
+	return r.primary.PartLimit()
+}
+
+func (r *Replica) InitiateMultipartUpload(ctx context.Context, name string, opt *s3.PutOption) (*s3.InitiateMultipartUploadResult, error) {
+	return r.primary.InitiateMultipartUpload(ctx, name, opt)
+}
+
+func (r *Replica) CompleteMultipartUpload(ctx context.Context, uploadID string, name string, parts []s3.Part) (*s3.CompleteMultipartUploadResult, error) {
+	res, err := r.primary.CompleteMultipartUpload(ctx, uploadID, name, parts)
+	if err != nil {
+		return nil, err
+	}
+	r.queue.add(ctx, opCopy, name, nil)
+	return res, nil
+}
+
+func (r *Replica) PartSize(ctx context.Context, size int64) (int64, error) {
+	return r.primary.PartSize(ctx, size)
+}
+
+func (r *Replica) AuthSign(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int) (*s3.AuthSignResult, error) {
+	return r.primary.AuthSign(ctx, uploadID, name, expire, partNumbers)
+}
+
+func (r *Replica) PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *s3.PutOption) (*s3.PresignedPutResult, error) {
+	return r.primary.PresignedPutObject(ctx, name, expire, opt)
+}
+
+func (r *Replica) DeleteObject(ctx context.Context, name string) error {
+	if err := r.primary.DeleteObject(ctx, name); err != nil {
+		return err
+	}
+	r.queue.add(ctx, opDelete, name, nil)
+	return nil
+}
+
+func (r *Replica) DeleteObjects(ctx context.Context, names []string) error {
+	if err := r.primary.DeleteObjects(ctx, names); err != nil {
+		return err
+	}
+	for _, name := range names {
+		r.queue.add(ctx, opDelete, name, nil)
+	}
+	return nil
+}
+
+func (r *Replica) ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*s3.ListObjectsResult, error) {
+	return r.primary.ListObjects(ctx, prefix, continuationToken, limit)
+}
+
+func (r *Replica) CopyObject(ctx context.Context, src string, dst string) (*s3.CopyObjectInfo, error) {
+	res, err := r.primary.CopyObject(ctx, src, dst)
+	if err != nil {
+		return nil, err
+	}
+	r.queue.add(ctx, opCopy, dst, nil)
+	return res, nil
+}
+
+// StatObject returns the object info from the primary, or from the first secondary holding
+// the object when the primary does not.
+func (r *Replica) StatObject(ctx context.Context, name string) (*s3.ObjectInfo, error) {
+	info, err := r.primary.StatObject(ctx, name)
+	if err == nil || !r.primary.IsNotFound(err) {
+		return info, err
+	}
+	for _, impl := range r.secondaries {
+		if info, serr := impl.StatObject(ctx, name); serr == nil {
+			return info, nil
+		}
+	}
+	return nil, err
+}
+
+func (r *Replica) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	if err := r.primary.SetObjectTags(ctx, name, tags); err != nil {
+		return err
+	}
+	r.queue.add(ctx, opTags, name, tags)
+	return nil
+}
+
+func (r *Replica) GetObjectTags(ctx context.Context, name string) (map[string]string, error) {
+	return r.primary.GetObjectTags(ctx, name)
+}
+
+// IsNotFound reports whether any of the backends recognizes err as a missing object.
+func (r *Replica) IsNotFound(err error) bool {
+	if r.primary.IsNotFound(err) {
+		return true
+	}
+	for _, impl := range r.secondaries {
+		if impl.IsNotFound(err) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Replica) AbortMultipartUpload(ctx context.Context, uploadID string, name string) error {
+	return r.primary.AbortMultipartUpload(ctx, uploadID, name)
+}
+
+func (r *Replica) ListUploadedParts(ctx context.Context, uploadID string, name string, partNumberMarker int, maxParts int) (*s3.ListUploadedPartsResult, error) {
+	return r.primary.ListUploadedParts(ctx, uploadID, name, partNumberMarker, maxParts)
+}
+
+// AccessURL returns a URL of the primary, or of the first secondary holding the object when
+// the primary does not. As presigning does not check the object exists, the primary is
+// asked with StatObject first.
+func (r *Replica) AccessURL(ctx context.Context, name string, expire time.Duration, opt *s3.AccessURLOption) (string, error) {
+	_, err := r.primary.StatObject(ctx, name)
+	if err == nil || !r.primary.IsNotFound(err) {
+		var u string
+		if u, err = r.primary.AccessURL(ctx, name, expire, opt); err == nil || !r.primary.IsNotFound(err) {
+			return u, err
+		}
+	}
+	for _, impl := range r.secondaries {
+		if _, serr := impl.StatObject(ctx, name); serr != nil {
+			continue
+		}
+		return impl.AccessURL(ctx, name, expire, opt)
+	}
+	return "", err
+}
+
+func (r *Replica) FormData(ctx context.Context, name string, size int64, contentType string, duration time.Duration) (*s3.FormData, error) {
+	return r.primary.FormData(ctx, name, size, contentType, duration)
+}
+
+// GetObject reads the object from the primary, or from the first secondary holding it when the primary does not.
+func (r *Replica) GetObject(ctx context.Context, name string, opt *s3.GetOption) (io.ReadCloser, error) {
+	reader, err := r.primary.(s3.ObjectStream).GetObject(ctx, name, opt)
+	if err == nil || !r.primary.IsNotFound(err) {
+		return reader, err
+	}
+	for _, impl := range r.secondaries {
+		if reader, serr := impl.(s3.ObjectStream).GetObject(ctx, name, opt); serr == nil {
+			return reader, nil
+		}
+	}
+	return nil, err
+}
+
+func (r *Replica) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	info, err := r.primary.(s3.ObjectStream).PutObject(ctx, name, reader, size, opt)
+	if err != nil {
+		return nil, err
+	}
+	r.queue.add(ctx, opCopy, name, nil)
+	return info, nil
+}
+
+// replicate applies a task to the secondary at index target.
+func (r *Replica) replicate(ctx context.Context, t *task) error {
+	secondary := r.secondaries[t.target]
+	switch t.op {
+	case opDelete:
+		return secondary.DeleteObject(ctx, t.name)
+	case opTags:
+		return secondary.SetObjectTags(ctx, t.name, t.tags)
+	default:
+		return r.copyObject(ctx, secondary, t.name)
+	}
+}
+
+// copyObject copies the content, metadata and tags of the named primary object to secondary.
+// An object no longer on the primary was deleted meanwhile, so there is nothing to copy.
+func (r *Replica) copyObject(ctx context.Context, secondary s3.Interface, name string) error {
+	info, err := r.primary.StatObject(ctx, name)
+	if err != nil {
+		if r.primary.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	tags, err := r.primary.GetObjectTags(ctx, name)
+	if err != nil && !r.primary.IsNotFound(err) {
+		return err
+	}
+	reader, err := r.primary.(s3.ObjectStream).GetObject(ctx, name, nil)
+	if err != nil {
+		if r.primary.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	defer reader.Close()
+	_, err = secondary.(s3.ObjectStream).PutObject(ctx, name, reader, info.Size, &s3.PutOption{
+		ContentType: info.ContentType,
+		Metadata:    info.Metadata,
+		Tags:        tags,
+	})
+	return err
+}
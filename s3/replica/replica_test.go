@@ -0,0 +1,208 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replica
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smartim/tools/s3"
+	"github.com/smartim/tools/s3/local"
+	"github.com/smartim/tools/s3/local/localtest"
+)
+
+// flakyLocal fails the first puts to exercise the retry queue.
+type flakyLocal struct {
+	*local.Local
+	failures atomic.Int32
+}
+
+func (f *flakyLocal) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	if f.failures.Add(-1) >= 0 {
+		return nil, errors.New("unavailable")
+	}
+	return f.Local.PutObject(ctx, name, reader, size, opt)
+}
+
+func TestReplica(t *testing.T) {
+	ctx := context.Background()
+	primary := localtest.New(t, "http://primary/object")
+	secondary := &flakyLocal{Local: localtest.New(t, "http://secondary/object")}
+	secondary.failures.Store(2)
+	var failures atomic.Int32
+	r, err := New(primary, []s3.Interface{secondary},
+		WithRetry(3, time.Millisecond),
+		WithKeyFilter(func(name string) bool { return !strings.HasPrefix(name, "temp/") }),
+		WithFailureHandler(func(ctx context.Context, failure *Failure) { failures.Add(1) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data := []byte("replicated content")
+	if _, err := r.PutObject(ctx, "a.txt", bytes.NewReader(data), int64(len(data)), &s3.PutOption{ContentType: "text/plain", Metadata: map[string]string{"Owner": "u1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.PutObject(ctx, "temp/b.txt", bytes.NewReader(data), int64(len(data)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CopyObject(ctx, "a.txt", "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	flushCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := r.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	if n := failures.Load(); n != 0 {
+		t.Fatalf("%d replications failed", n)
+	}
+	for _, name := range []string{"a.txt", "c.txt"} {
+		info, err := secondary.StatObject(ctx, name)
+		if err != nil {
+			t.Fatalf("%s not replicated: %v", name, err)
+		}
+		if info.Size != int64(len(data)) || info.ContentType != "text/plain" || info.Metadata["owner"] != "u1" {
+			t.Fatalf("unexpected replica of %s: %+v", name, info)
+		}
+	}
+	if _, err := secondary.StatObject(ctx, "temp/b.txt"); !secondary.IsNotFound(err) {
+		t.Fatalf("filtered key replicated: %v", err)
+	}
+
+	if err := r.DeleteObject(ctx, "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secondary.StatObject(ctx, "c.txt"); !secondary.IsNotFound(err) {
+		t.Fatalf("delete not replicated: %v", err)
+	}
+
+	// Objects only on the secondary, e.g. not migrated yet, are served from there.
+	if _, err := secondary.Local.PutObject(ctx, "old.txt", bytes.NewReader(data), int64(len(data)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.StatObject(ctx, "old.txt"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := r.AccessURL(ctx, "old.txt", time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u, "http://secondary/") {
+		t.Fatalf("unexpected access url %s", u)
+	}
+	if _, err := r.StatObject(ctx, "missing.txt"); !r.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if u, err := r.AccessURL(ctx, "a.txt", time.Minute, nil); err != nil || !strings.HasPrefix(u, "http://primary/") {
+		t.Fatalf("unexpected access url %s: %v", u, err)
+	}
+}
+
+// orderedLocal records the operations applied to it, the first put failing.
+type orderedLocal struct {
+	flakyLocal
+	lock sync.Mutex
+	ops  []string
+}
+
+func (o *orderedLocal) record(op string, name string) {
+	o.lock.Lock()
+	o.ops = append(o.ops, op+" "+name)
+	o.lock.Unlock()
+}
+
+func (o *orderedLocal) PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *s3.PutOption) (*s3.ObjectInfo, error) {
+	o.record(opCopy, name)
+	return o.flakyLocal.PutObject(ctx, name, reader, size, opt)
+}
+
+func (o *orderedLocal) SetObjectTags(ctx context.Context, name string, tags map[string]string) error {
+	o.record(opTags, name)
+	return o.flakyLocal.SetObjectTags(ctx, name, tags)
+}
+
+func TestReplicaRetryOrder(t *testing.T) {
+	ctx := context.Background()
+	secondary := &orderedLocal{flakyLocal: flakyLocal{Local: localtest.New(t, "http://secondary/object")}}
+	secondary.failures.Store(1)
+	r, err := New(localtest.New(t, "http://primary/object"), []s3.Interface{secondary}, WithWorkers(1), WithRetry(2, time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.PutObject(ctx, "a.txt", strings.NewReader("a"), 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetObjectTags(ctx, "a.txt", map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.PutObject(ctx, "b.txt", strings.NewReader("b"), 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	flushCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := r.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	// The tags wait for the retried copy instead of overtaking it, the other key does not wait.
+	secondary.lock.Lock()
+	ops := strings.Join(secondary.ops, ",")
+	secondary.lock.Unlock()
+	if ops != "copy a.txt,copy b.txt,copy a.txt,tags a.txt" {
+		t.Fatalf("operations applied as %s", ops)
+	}
+	if tags, err := secondary.GetObjectTags(ctx, "a.txt"); err != nil || tags["k"] != "v" {
+		t.Fatalf("unexpected tags %v: %v", tags, err)
+	}
+}
+
+func TestReplicaGiveUp(t *testing.T) {
+	ctx := context.Background()
+	secondary := &flakyLocal{Local: localtest.New(t, "http://secondary/object")}
+	secondary.failures.Store(100)
+	var failed atomic.Pointer[Failure]
+	r, err := New(localtest.New(t, "http://primary/object"), []s3.Interface{secondary},
+		WithRetry(2, time.Millisecond),
+		WithFailureHandler(func(ctx context.Context, failure *Failure) { failed.Store(failure) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.PutObject(ctx, "a.txt", strings.NewReader("a"), 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	flushCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := r.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	failure := failed.Load()
+	if failure == nil || failure.Name != "a.txt" || failure.Op != opCopy || failure.Attempts != 3 {
+		t.Fatalf("unexpected failure %+v", failure)
+	}
+}
@@ -16,8 +16,15 @@ package s3
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -67,6 +74,18 @@ type ObjectInfo struct {
 	Key          string    `json:"name"`
 	Size         int64     `json:"size"`
 	LastModified time.Time `json:"lastModified"`
+	// ContentType is the content type the object was stored with, filled by StatObject.
+	ContentType string `json:"contentType,omitempty"`
+	// Metadata is the user metadata set at upload time, filled by StatObject. Keys are lower case.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Tags is only filled by backends returning tags along with the object, use GetObjectTags otherwise.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+type ListObjectsResult struct {
+	Objects               []ObjectInfo `json:"objects"`
+	NextContinuationToken string       `json:"nextContinuationToken"`
+	IsTruncated           bool         `json:"isTruncated"`
 }
 
 type CopyObjectInfo struct {
@@ -137,8 +156,132 @@ type AccessURLOption struct {
 	Image       *Image `json:"image"`
 }
 
+// Server-side encryption types of Encryption.
+const (
+	// SSES3 encrypts with keys managed by the storage service.
+	SSES3 = "SSE-S3"
+	// SSEKMS encrypts with a key kept in the service's key management system.
+	SSEKMS = "SSE-KMS"
+	// SSEC encrypts with a key provided by the caller on every request.
+	SSEC = "SSE-C"
+)
+
+// ErrEncryptionNotSupported is returned by backends that can't honour the requested Encryption.
+var ErrEncryptionNotSupported = errors.New("server-side encryption not supported")
+
+// Encryption selects how the storage service encrypts an object at rest.
+type Encryption struct {
+	// Type is one of SSES3, SSEKMS or SSEC.
+	Type string `json:"type"`
+	// KMSKeyID is the key used with SSEKMS, the service default key is used when empty.
+	KMSKeyID string `json:"kmsKeyID"`
+	// CustomerKey is the 256-bit AES key used with SSEC. It is never serialized.
+	CustomerKey []byte `json:"-"`
+}
+
+// Validate checks the fields required by the encryption type.
+func (e *Encryption) Validate() error {
+	switch e.Type {
+	case SSES3, SSEKMS:
+		return nil
+	case SSEC:
+		if len(e.CustomerKey) != 32 {
+			return fmt.Errorf("SSE-C customer key must be 32 bytes, got %d", len(e.CustomerKey))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown server-side encryption type %q", e.Type)
+	}
+}
+
+// CustomerKeyBase64 returns the SSE-C key as sent in request headers.
+func (e *Encryption) CustomerKeyBase64() string {
+	return base64.StdEncoding.EncodeToString(e.CustomerKey)
+}
+
+// CustomerKeyMD5 returns the base64 MD5 digest of the SSE-C key as sent in request headers.
+func (e *Encryption) CustomerKeyMD5() string {
+	sum := md5.Sum(e.CustomerKey)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// NotSupportedEncryption returns the error of a backend that can't honour enc.
+func NotSupportedEncryption(engine string, enc *Encryption) error {
+	return fmt.Errorf("%w: %s does not support %s", ErrEncryptionNotSupported, engine, enc.Type)
+}
+
 type PutOption struct {
 	ContentType string `json:"contentType"`
+	// Encryption, if not nil, encrypts the object at rest. An SSEC object is read back with
+	// GetOption.Encryption and stat with EncryptedStatter.
+	Encryption *Encryption `json:"encryption"`
+	// Metadata is stored with the object and returned by StatObject. Keys are case-insensitive.
+	Metadata map[string]string `json:"metadata"`
+	// Tags are attached to the object, e.g. for lifecycle rules to select objects by.
+	Tags map[string]string `json:"tags"`
+}
+
+// GetEncryption returns the validated Encryption of the option, or nil if none is set.
+func (o *PutOption) GetEncryption() (*Encryption, error) {
+	if o == nil || o.Encryption == nil {
+		return nil, nil
+	}
+	if err := o.Encryption.Validate(); err != nil {
+		return nil, err
+	}
+	return o.Encryption, nil
+}
+
+type GetOption struct {
+	// Offset and Length select a byte range of the object, Length <= 0 reads to the end.
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+	// Encryption is the SSE-C key the object was written with, which is required to read it.
+	// Objects encrypted with SSES3 or SSEKMS are decrypted by the service and need no option.
+	Encryption *Encryption `json:"encryption"`
+}
+
+// GetEncryption returns the validated SSE-C Encryption of the option, or nil if none is needed.
+func (o *GetOption) GetEncryption() (*Encryption, error) {
+	if o == nil || o.Encryption == nil || o.Encryption.Type != SSEC {
+		return nil, nil
+	}
+	if err := o.Encryption.Validate(); err != nil {
+		return nil, err
+	}
+	return o.Encryption, nil
+}
+
+// Range returns the HTTP Range header value of the option, or "" for the whole object.
+func (o *GetOption) Range() string {
+	if o == nil || (o.Offset <= 0 && o.Length <= 0) {
+		return ""
+	}
+	if o.Length <= 0 {
+		return "bytes=" + strconv.FormatInt(o.Offset, 10) + "-"
+	}
+	return "bytes=" + strconv.FormatInt(o.Offset, 10) + "-" + strconv.FormatInt(o.Offset+o.Length-1, 10)
+}
+
+// EncodeTags returns tags in the URL query form used by the tagging request headers.
+func EncodeTags(tags map[string]string) string {
+	values := make(url.Values, len(tags))
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// LowerMetadata returns metadata with lower case keys, or nil if it is empty.
+func LowerMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		res[strings.ToLower(k)] = v
+	}
+	return res
 }
 
 type PresignedPutResult struct {
@@ -146,6 +289,19 @@ type PresignedPutResult struct {
 	Header http.Header `json:"header"`
 }
 
+// EncryptedPartSigner is implemented by backends that support SSE-C multipart uploads.
+// Every part of such an upload must be sent with the customer key it was initiated with,
+// so the part requests are signed together with the key headers.
+type EncryptedPartSigner interface {
+	AuthSignEncrypted(ctx context.Context, uploadID string, name string, expire time.Duration, partNumbers []int, enc *Encryption) (*AuthSignResult, error)
+}
+
+// EncryptedStatter is implemented by backends that support SSE-C, whose objects can only be
+// stat with the customer key they were written with. A nil or non SSE-C enc is a plain StatObject.
+type EncryptedStatter interface {
+	StatObjectEncrypted(ctx context.Context, name string, enc *Encryption) (*ObjectInfo, error)
+}
+
 type Interface interface {
 	Engine() string
 	PartLimit() (*PartLimit, error)
@@ -159,11 +315,21 @@ type Interface interface {
 	PresignedPutObject(ctx context.Context, name string, expire time.Duration, opt *PutOption) (*PresignedPutResult, error)
 
 	DeleteObject(ctx context.Context, name string) error
+	// DeleteObjects removes names in as few requests as the backend allows. Missing objects are not an error.
+	DeleteObjects(ctx context.Context, names []string) error
+
+	// ListObjects lists up to limit objects under prefix in key order. Pass the previous
+	// result's NextContinuationToken to fetch the following page.
+	ListObjects(ctx context.Context, prefix string, continuationToken string, limit int) (*ListObjectsResult, error)
 
 	CopyObject(ctx context.Context, src string, dst string) (*CopyObjectInfo, error)
 
 	StatObject(ctx context.Context, name string) (*ObjectInfo, error)
 
+	// SetObjectTags replaces all the tags of the object.
+	SetObjectTags(ctx context.Context, name string, tags map[string]string) error
+	GetObjectTags(ctx context.Context, name string) (map[string]string, error)
+
 	IsNotFound(err error) bool
 
 	AbortMultipartUpload(ctx context.Context, uploadID string, name string) error
@@ -173,3 +339,14 @@ type Interface interface {
 
 	FormData(ctx context.Context, name string, size int64, contentType string, duration time.Duration) (*FormData, error)
 }
+
+// ObjectStream is an optional capability of an Interface implementation for server-side
+// code that reads or writes object content directly. Probe for it with a type assertion:
+//
+//	if stream, ok := impl.(s3.ObjectStream); ok { ... }
+type ObjectStream interface {
+	GetObject(ctx context.Context, name string, opt *GetOption) (io.ReadCloser, error)
+	// PutObject uploads size bytes read from reader. A negative size means unknown,
+	// which backends that must send Content-Length up front reject.
+	PutObject(ctx context.Context, name string, reader io.Reader, size int64, opt *PutOption) (*ObjectInfo, error)
+}
@@ -0,0 +1,84 @@
+// Copyright © 2026 OpenIM open source community. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout is how long SIGTERMExit waits for the registered drainers.
+const DefaultDrainTimeout = time.Second * 30
+
+// Drainer is stopped gracefully on SIGTERM, such as an mq.DrainConsumer.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+type namedDrainer struct {
+	name    string
+	drainer Drainer
+}
+
+var (
+	drainLock    sync.Mutex
+	drainers     []namedDrainer
+	drainTimeout = DefaultDrainTimeout
+)
+
+// RegisterDrainer adds d to the drainers run by SIGTERMExit, name identifying it in errors.
+func RegisterDrainer(name string, d Drainer) {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+	drainers = append(drainers, namedDrainer{name: name, drainer: d})
+}
+
+// SetDrainTimeout sets how long SIGTERMExit waits for the drainers, DefaultDrainTimeout by default.
+func SetDrainTimeout(timeout time.Duration) {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+	drainTimeout = timeout
+}
+
+// Drain runs the registered drainers concurrently and unregisters them, so that each one runs once.
+func Drain(ctx context.Context) error {
+	drainLock.Lock()
+	list := drainers
+	drainers = nil
+	drainLock.Unlock()
+
+	errList := make([]error, len(list))
+	var wg sync.WaitGroup
+	for i, d := range list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.drainer.Drain(ctx); err != nil {
+				errList[i] = fmt.Errorf("drain %s: %w", d.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errList...)
+}
+
+func getDrainTimeout() time.Duration {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+	return drainTimeout
+}
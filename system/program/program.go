@@ -15,6 +15,7 @@
 package program
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -27,8 +28,15 @@ func ExitWithError(err error) {
 	os.Exit(-1)
 }
 
+// SIGTERMExit drains the drainers registered with RegisterDrainer, waiting for them at most
+// the drain timeout, before reporting the exit.
 func SIGTERMExit() {
 	progName := filepath.Base(os.Args[0])
+	ctx, cancel := context.WithTimeout(context.Background(), getDrainTimeout())
+	defer cancel()
+	if err := Drain(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning %s drain on SIGTERM: %v\n", progName, err)
+	}
 	fmt.Fprintf(os.Stderr, "Warning %s receive process terminal SIGTERM exit 0\n", progName)
 }
 
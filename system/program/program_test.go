@@ -15,8 +15,11 @@
 package program
 
 import (
+	"context"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // TestGetProcessName tests the GetProcessName function to ensure it returns the expected process name.
@@ -32,3 +35,32 @@ func TestGetProcessName(t *testing.T) {
 		t.Errorf("GetProcessName() = %q, want %q", got, expected)
 	}
 }
+
+type drainFunc func(ctx context.Context) error
+
+func (f drainFunc) Drain(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestDrain(t *testing.T) {
+	var drained atomic.Int32
+	RegisterDrainer("ok", drainFunc(func(ctx context.Context) error {
+		drained.Add(1)
+		return nil
+	}))
+	RegisterDrainer("slow", drainFunc(func(ctx context.Context) error {
+		drained.Add(1)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	SetDrainTimeout(time.Millisecond * 20)
+	defer SetDrainTimeout(DefaultDrainTimeout)
+	SIGTERMExit()
+	if drained.Load() != 2 {
+		t.Fatalf("%d drainers run, want 2", drained.Load())
+	}
+	// Drainers run once.
+	if err := Drain(context.Background()); err != nil || drained.Load() != 2 {
+		t.Fatalf("drainers run again: %v", err)
+	}
+}